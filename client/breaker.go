@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for queries refused without contacting the server
+// because too many recent queries failed.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// breaker fails queries fast after threshold consecutive failures within
+// window, until cooldown has passed. A query is then let through, and the
+// breaker closes again if it succeeds or reopens if it fails.
+type breaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	first     time.Time
+	openUntil time.Time
+}
+
+func newBreaker(threshold int, window, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, window: window, cooldown: cooldown, now: time.Now}
+}
+
+// allow returns an error wrapping ErrCircuitOpen if the breaker is open.
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if left := b.openUntil.Sub(b.now()); left > 0 {
+		return fmt.Errorf("%w after %d consecutive failures, not querying the server for another %s",
+			ErrCircuitOpen, b.failures, left.Round(time.Second))
+	}
+	return nil
+}
+
+// record records the outcome of a query.
+func (b *breaker) record(failed bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if !failed {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+
+	halfOpen := !b.openUntil.IsZero()
+	if b.failures == 0 || (b.window > 0 && now.Sub(b.first) > b.window && !halfOpen) {
+		b.failures, b.first = 0, now
+	}
+	b.failures++
+	if halfOpen || b.failures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
+
+// state describes the breaker as closed, open or half-open.
+func (b *breaker) state() string {
+	if b == nil {
+		return "disabled"
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch left := b.openUntil.Sub(b.now()); {
+	case b.openUntil.IsZero():
+		return fmt.Sprintf("closed (%d/%d failures)", b.failures, b.threshold)
+	case left > 0:
+		return fmt.Sprintf("open (%s left)", left.Round(time.Second))
+	default:
+		return "half-open"
+	}
+}
+
+// BreakerState describes the circuit breaker of the client: disabled,
+// closed with the count of recent failures, open with the time left, or
+// half-open while the next query decides whether it closes.
+func (c *Client) BreakerState() string {
+	return c.breaker.state()
+}
+
+// doQuery sends a query request, retrying connection errors and 502, 503 and
+// 504 responses up to the configured number of times with a jittered
+// exponential backoff. It fails fast while the circuit breaker is open.
+func (c *Client) doQuery(ctx context.Context, req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.breaker.allow(); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.httpClient.Do(req)
+		failed := err != nil || isRetryableStatus(resp.StatusCode)
+		if err != nil && ctx.Err() != nil {
+			// Cancelled by the caller, which says nothing about the server.
+			return nil, err
+		}
+		c.breaker.record(failed)
+		if !failed || attempt >= c.retries {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(retryDelay(c.retryBackoff, attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// retryDelay returns a random delay between half and all of base doubled
+// attempt times, so that clients retrying together spread out.
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	if attempt > 16 {
+		attempt = 16
+	}
+	d := base << uint(attempt)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
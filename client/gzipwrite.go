@@ -0,0 +1,97 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+// gzipWriterPool and gzipBufferPool hold the compressors and buffers of
+// compressed writes, so that a stream of batches such as an import does not
+// allocate them for every request.
+var (
+	gzipWriterPool = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	gzipBufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+)
+
+// pooledBody is a request body read from a pooled buffer. The transport
+// closes the body once it is done with it, which returns the buffer.
+type pooledBody struct {
+	*bytes.Reader
+	buf  *bytes.Buffer
+	once sync.Once
+}
+
+func (b *pooledBody) Close() error {
+	b.once.Do(func() { gzipBufferPool.Put(b.buf) })
+	return nil
+}
+
+// compress returns body gzipped in a pooled buffer.
+func compress(body []byte) (*pooledBody, error) {
+	buf := gzipBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	zw := gzipWriterPool.Get().(*gzip.Writer)
+	defer gzipWriterPool.Put(zw)
+	zw.Reset(buf)
+	if _, err := zw.Write(body); err != nil {
+		gzipBufferPool.Put(buf)
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		gzipBufferPool.Put(buf)
+		return nil, err
+	}
+	return &pooledBody{Reader: bytes.NewReader(buf.Bytes()), buf: buf}, nil
+}
+
+// sendWrite posts a write request with body to u. With GzipWrites set the
+// body is compressed, and if the server answers 415 Unsupported Media Type
+// the write is sent again uncompressed, as are all later writes of the
+// client. A warning is logged the first time.
+func (c *Client) sendWrite(u url.URL, body []byte) (*http.Response, error) {
+	if atomic.LoadInt32(&c.gzipWrites) == 1 {
+		zbody, err := compress(body)
+		if err != nil {
+			return nil, err
+		}
+		req, err := c.newWriteRequest(u, zbody)
+		if err != nil {
+			zbody.Close()
+			return nil, err
+		}
+		req.Header.Set("Content-Encoding", "gzip")
+		resp, err := c.httpClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusUnsupportedMediaType {
+			return resp, err
+		}
+		resp.Body.Close()
+		if atomic.CompareAndSwapInt32(&c.gzipWrites, 1, 0) {
+			log.Printf("WARN: %s rejected a gzip compressed write (%s), sending writes uncompressed", c.Addr(), resp.Status)
+		}
+	}
+
+	req, err := c.newWriteRequest(u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient.Do(req)
+}
+
+// newWriteRequest returns a write request with the headers every write sends.
+func (c *Client) newWriteRequest(u url.URL, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest("POST", u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "")
+	req.Header.Set("User-Agent", c.userAgent)
+	c.addHeaders(req)
+	c.addAuth(req)
+	return req, nil
+}
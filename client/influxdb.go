@@ -4,6 +4,7 @@ package client // import "github.com/influxdata/influxdb/client"
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -134,6 +135,50 @@ type Config struct {
 	UnsafeSsl        bool
 	Proxy            func(req *http.Request) (*url.URL, error)
 	TLS              *tls.Config
+
+	// Token is sent in an "Authorization: Token" header when set. It takes
+	// precedence over Username and Password.
+	Token string
+
+	// MaxResponseSize limits the decoded size of a query response in bytes.
+	// Zero means no limit.
+	MaxResponseSize int64
+
+	// AcceptGzip requests gzip compressed query responses from the server and
+	// decompresses them in the client. Uncompressed responses are still accepted.
+	AcceptGzip bool
+
+	// MaxIdleConns, MaxIdleConnsPerHost, IdleConnTimeout and DisableKeepAlives
+	// tune connection reuse in the underlying http.Transport. The zero values
+	// keep the defaults of http.Transport.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	DisableKeepAlives   bool
+
+	// Retries is how many times a query that fails to reach the server, or
+	// gets a 502, 503 or 504 response, is retried. Retries wait RetryBackoff,
+	// doubled after each attempt, with random jitter.
+	Retries      int
+	RetryBackoff time.Duration
+
+	// BreakerThreshold enables a circuit breaker: after this many consecutive
+	// failed queries within BreakerWindow, queries fail with ErrCircuitOpen
+	// without contacting the server until BreakerCooldown has passed. Zero
+	// disables the breaker, and a zero window counts failures indefinitely.
+	BreakerThreshold int
+	BreakerWindow    time.Duration
+	BreakerCooldown  time.Duration
+
+	// GzipWrites compresses the body of write requests with gzip. If the
+	// server rejects a compressed write with 415 Unsupported Media Type, the
+	// client logs a warning and sends writes uncompressed from then on.
+	GzipWrites bool
+
+	// Headers are added to every request, such as a header a gateway in front
+	// of the server routes on. The headers the client sets itself, such as
+	// Authorization and Content-Type, take precedence.
+	Headers http.Header
 }
 
 // NewConfig will create a config to be used in connecting to the client
@@ -149,9 +194,19 @@ type Client struct {
 	unixSocket string
 	username   string
 	password   string
+	token      string
+	headers    http.Header
 	httpClient *http.Client
 	userAgent  string
 	precision  string
+	acceptGzip bool
+	gzipWrites int32 // 1 while writes are compressed, accessed atomically
+
+	maxResponseSize int64
+
+	retries      int
+	retryBackoff time.Duration
+	breaker      *breaker
 }
 
 const (
@@ -177,8 +232,12 @@ func NewClient(c Config) (*Client, error) {
 	tlsConfig.InsecureSkipVerify = c.UnsafeSsl
 
 	tr := &http.Transport{
-		Proxy:           c.Proxy,
-		TLSClientConfig: tlsConfig,
+		Proxy:               c.Proxy,
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConns:        c.MaxIdleConns,
+		MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+		IdleConnTimeout:     c.IdleConnTimeout,
+		DisableKeepAlives:   c.DisableKeepAlives,
 	}
 
 	if c.UnixSocket != "" {
@@ -195,9 +254,23 @@ func NewClient(c Config) (*Client, error) {
 		unixSocket: c.UnixSocket,
 		username:   c.Username,
 		password:   c.Password,
+		token:      c.Token,
+		headers:    c.Headers.Clone(),
 		httpClient: &http.Client{Timeout: c.Timeout, Transport: tr},
 		userAgent:  c.UserAgent,
 		precision:  c.Precision,
+		acceptGzip: c.AcceptGzip,
+
+		maxResponseSize: c.MaxResponseSize,
+
+		retries:      c.Retries,
+		retryBackoff: c.RetryBackoff,
+	}
+	if c.GzipWrites {
+		client.gzipWrites = 1
+	}
+	if c.BreakerThreshold > 0 {
+		client.breaker = newBreaker(c.BreakerThreshold, c.BreakerWindow, c.BreakerCooldown)
 	}
 	if client.userAgent == "" {
 		client.userAgent = "InfluxDBClient"
@@ -211,6 +284,32 @@ func (c *Client) SetAuth(u, p string) {
 	c.password = p
 }
 
+// SetToken will update the authentication token
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// SetHeaders replaces the headers added to every request.
+func (c *Client) SetHeaders(h http.Header) {
+	c.headers = h.Clone()
+}
+
+// addHeaders adds the configured headers to req.
+func (c *Client) addHeaders(req *http.Request) {
+	for name, values := range c.headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+}
+
+// addAuth adds the configured credentials to req. A token wins over basic auth.
+func (c *Client) addAuth(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Token "+c.token)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+}
+
 // SetPrecision will update the precision
 func (c *Client) SetPrecision(precision string) {
 	c.precision = precision
@@ -224,54 +323,52 @@ func (c *Client) Query(q Query) (*Response, error) {
 // QueryContext sends a command to the server and returns the Response
 // It uses a context that can be cancelled by the command line client
 func (c *Client) QueryContext(ctx context.Context, q Query) (*Response, error) {
-	u := c.url
-	u.Path = path.Join(u.Path, "query")
-
-	values := u.Query()
-	values.Set("q", q.Command)
-	values.Set("db", q.Database)
-	if q.RetentionPolicy != "" {
-		values.Set("rp", q.RetentionPolicy)
-	}
-	if q.Chunked {
-		values.Set("chunked", "true")
-		if q.ChunkSize > 0 {
-			values.Set("chunk_size", strconv.Itoa(q.ChunkSize))
-		}
-	}
-	if q.NodeID > 0 {
-		values.Set("node_id", strconv.Itoa(q.NodeID))
-	}
-	if c.precision != "" {
-		values.Set("epoch", c.precision)
-	}
-	u.RawQuery = values.Encode()
-
-	req, err := http.NewRequest("POST", u.String(), nil)
+	req, err := c.newQueryRequest(ctx, q)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", c.userAgent)
-	if c.username != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
 
-	req = req.WithContext(ctx)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doQuery(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	wire := &countingReader{r: resp.Body}
+	var body io.Reader = wire
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gr, err := gzip.NewReader(wire)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		body = gr
+	}
+	decoded := &countingReader{r: body}
+	body = decoded
+
+	var limited *maxSizeReader
+	if c.maxResponseSize > 0 {
+		limited = newMaxSizeReader(body, c.maxResponseSize)
+		body = limited
+	}
+	// decodeErr replaces a decoding error caused by the size limit, which the
+	// decoders may have wrapped or discarded, with ErrResponseTooLarge.
+	decodeErr := func(err error) error {
+		if limited != nil && limited.exceeded() {
+			return fmt.Errorf("%s of %d bytes", ErrResponseTooLarge, c.maxResponseSize)
+		}
+		return err
+	}
+
 	var response Response
 	if q.Chunked {
-		cr := NewChunkedResponse(resp.Body)
+		cr := NewChunkedResponse(body)
 		for {
 			r, err := cr.NextResponse()
 			if err != nil {
 				// If we got an error while decoding the response, send that back.
-				return nil, err
+				return nil, decodeErr(err)
 			}
 
 			if r == nil {
@@ -285,15 +382,16 @@ func (c *Client) QueryContext(ctx context.Context, q Query) (*Response, error) {
 			}
 		}
 	} else {
-		dec := json.NewDecoder(resp.Body)
+		dec := json.NewDecoder(body)
 		dec.UseNumber()
 		if err := dec.Decode(&response); err != nil {
 			// Ignore EOF errors if we got an invalid status code.
 			if !(err == io.EOF && resp.StatusCode != http.StatusOK) {
-				return nil, err
+				return nil, decodeErr(err)
 			}
 		}
 	}
+	response.Transfer = TransferStats{WireBytes: wire.n, DecodedBytes: decoded.n}
 
 	// If we don't have an error in our json response, and didn't get StatusOK,
 	// then send back an error.
@@ -303,6 +401,96 @@ func (c *Client) QueryContext(ctx context.Context, q Query) (*Response, error) {
 	return &response, nil
 }
 
+// newQueryRequest builds the HTTP request for a query.
+func (c *Client) newQueryRequest(ctx context.Context, q Query) (*http.Request, error) {
+	u := c.url
+	u.Path = path.Join(u.Path, "query")
+
+	values := u.Query()
+	values.Set("q", q.Command)
+	values.Set("db", q.Database)
+	if q.RetentionPolicy != "" {
+		values.Set("rp", q.RetentionPolicy)
+	}
+	if q.Chunked {
+		values.Set("chunked", "true")
+		if q.ChunkSize > 0 {
+			values.Set("chunk_size", strconv.Itoa(q.ChunkSize))
+		}
+	}
+	if q.NodeID > 0 {
+		values.Set("node_id", strconv.Itoa(q.NodeID))
+	}
+	if c.precision != "" {
+		values.Set("epoch", c.precision)
+	}
+	u.RawQuery = values.Encode()
+
+	req, err := http.NewRequest("POST", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	c.addHeaders(req)
+	c.addAuth(req)
+	if c.acceptGzip {
+		// Setting the header ourselves disables the transparent decompression
+		// in the transport so the compressed size can be measured.
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	return req.WithContext(ctx), nil
+}
+
+// QueryEach runs q as a chunked query and calls fn with each chunk of the
+// response as it is decoded, so the whole response is never held in memory.
+// It stops at the first error returned by fn or reported by the server.
+// MaxResponseSize does not apply to streamed responses.
+func (c *Client) QueryEach(ctx context.Context, q Query, fn func(*Response) error) error {
+	q.Chunked = true
+	req, err := c.newQueryRequest(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doQuery(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		body = gr
+	}
+
+	cr := NewChunkedResponse(body)
+	for {
+		r, err := cr.NextResponse()
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			break
+		}
+		if err := r.Error(); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received status code %d from server", resp.StatusCode)
+	}
+	return nil
+}
+
 // Write takes BatchPoints and allows for writing of multiple points with defaults
 // If successful, error is nil and Response is nil
 // If an error occurs, Response may contain additional information if populated.
@@ -338,29 +526,19 @@ func (c *Client) Write(bp BatchPoints) (*Response, error) {
 		}
 	}
 
-	req, err := http.NewRequest("POST", u.String(), &b)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "")
-	req.Header.Set("User-Agent", c.userAgent)
-	if c.username != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
-
 	precision := bp.Precision
 	if precision == "" {
 		precision = c.precision
 	}
 
-	params := req.URL.Query()
+	params := u.Query()
 	params.Set("db", bp.Database)
 	params.Set("rp", bp.RetentionPolicy)
 	params.Set("precision", precision)
 	params.Set("consistency", bp.WriteConsistency)
-	req.URL.RawQuery = params.Encode()
+	u.RawQuery = params.Encode()
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.sendWrite(u, b.Bytes())
 	if err != nil {
 		return nil, err
 	}
@@ -388,25 +566,14 @@ func (c *Client) WriteLineProtocol(data, database, retentionPolicy, precision, w
 	u := c.url
 	u.Path = path.Join(u.Path, "write")
 
-	r := strings.NewReader(data)
-
-	req, err := http.NewRequest("POST", u.String(), r)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "")
-	req.Header.Set("User-Agent", c.userAgent)
-	if c.username != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
-	params := req.URL.Query()
+	params := u.Query()
 	params.Set("db", database)
 	params.Set("rp", retentionPolicy)
 	params.Set("precision", precision)
 	params.Set("consistency", writeConsistency)
-	req.URL.RawQuery = params.Encode()
+	u.RawQuery = params.Encode()
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.sendWrite(u, []byte(data))
 	if err != nil {
 		return nil, err
 	}
@@ -440,9 +607,8 @@ func (c *Client) Ping() (time.Duration, string, error) {
 		return 0, "", err
 	}
 	req.Header.Set("User-Agent", c.userAgent)
-	if c.username != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
+	c.addHeaders(req)
+	c.addAuth(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -514,6 +680,19 @@ func (r *Result) UnmarshalJSON(b []byte) error {
 type Response struct {
 	Results []Result
 	Err     error
+
+	// Transfer holds the number of bytes received for the response. It is
+	// only populated by QueryContext and is not encoded.
+	Transfer TransferStats
+}
+
+// TransferStats records the number of bytes received for a query response.
+type TransferStats struct {
+	// WireBytes is the number of bytes read from the connection.
+	WireBytes int64
+
+	// DecodedBytes is the number of bytes after decompression.
+	DecodedBytes int64
 }
 
 // MarshalJSON encodes the response into JSON.
@@ -582,6 +761,48 @@ func (r *duplexReader) Read(p []byte) (n int, err error) {
 	return n, err
 }
 
+// countingReader counts the number of bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// ErrResponseTooLarge is returned when a query response is larger than the
+// configured MaxResponseSize.
+var ErrResponseTooLarge = errors.New("response exceeded max size")
+
+// maxSizeReader reads up to max bytes and then fails with
+// ErrResponseTooLarge if the underlying reader has more data.
+type maxSizeReader struct {
+	r   io.Reader // limited to max+1 bytes to detect a larger response
+	max int64
+	n   int64
+}
+
+func newMaxSizeReader(r io.Reader, max int64) *maxSizeReader {
+	return &maxSizeReader{r: io.LimitReader(r, max+1), max: max}
+}
+
+func (r *maxSizeReader) Read(p []byte) (n int, err error) {
+	if r.exceeded() {
+		return 0, ErrResponseTooLarge
+	}
+	n, err = r.r.Read(p)
+	r.n += int64(n)
+	if r.exceeded() {
+		return n - int(r.n-r.max), ErrResponseTooLarge
+	}
+	return n, err
+}
+
+func (r *maxSizeReader) exceeded() bool { return r.n > r.max }
+
 // ChunkedResponse represents a response from the server that
 // uses chunking to stream the output.
 type ChunkedResponse struct {
@@ -819,11 +1040,18 @@ func (bp *BatchPoints) UnmarshalJSON(b []byte) error {
 // utility functions
 
 // Addr provides the current url as a string of the server the client is connected to.
+// Any user info in the url is left out, so that Addr can be printed without
+// revealing credentials.
 func (c *Client) Addr() string {
 	if c.unixSocket != "" {
 		return c.unixSocket
 	}
-	return c.url.String()
+	u := c.url
+	u.User = nil
+	if i := strings.LastIndex(u.Host, "@"); i >= 0 {
+		u.Host = u.Host[i+1:]
+	}
+	return u.String()
 }
 
 // checkPointTypes ensures no unsupported types are submitted to influxdb, returning error if they are found.
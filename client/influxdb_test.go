@@ -1,17 +1,20 @@
 package client_test
 
 import (
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -148,6 +151,54 @@ func TestClient_Ping(t *testing.T) {
 	}
 }
 
+func TestClient_Addr_UserInfo(t *testing.T) {
+	u := url.URL{Scheme: "http", Host: "localhost:8086", User: url.UserPassword("admin", "s3cr3t")}
+	c, err := client.NewClient(client.Config{URL: u})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if got, exp := c.Addr(), "http://localhost:8086"; got != exp {
+		t.Fatalf("unexpected addr.  expected %s,  actual %s", exp, got)
+	}
+}
+
+func TestClient_QueryContext_Timing(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		var data client.Response
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(data)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u, UnsafeSsl: true})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	ctx, timing := client.WithTiming(context.Background())
+	if _, err := c.QueryContext(ctx, client.Query{}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	tm := timing()
+	if tm.Connect <= 0 || tm.TLS <= 0 || tm.Reused {
+		t.Fatalf("expected a new TLS connection to be timed.  actual %+v", tm)
+	}
+	if tm.Wait < 20*time.Millisecond || tm.FirstByte < tm.Wait+tm.Connect {
+		t.Fatalf("unexpected wait.  actual %+v", tm)
+	}
+
+	// A second request reuses the connection.
+	ctx, timing = client.WithTiming(context.Background())
+	if _, err := c.QueryContext(ctx, client.Query{}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if tm := timing(); !tm.Reused || tm.Connect != 0 || tm.TLS != 0 {
+		t.Fatalf("expected a reused connection.  actual %+v", tm)
+	}
+}
+
 func TestClient_Query(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var data client.Response
@@ -170,6 +221,136 @@ func TestClient_Query(t *testing.T) {
 	}
 }
 
+func TestClient_Query_AcceptGzip(t *testing.T) {
+	body := `{"results":[{"series":[{"name":"cpu","columns":["time","value"],"values":[` +
+		strings.Repeat(`["2019-01-01T00:00:00Z",1],`, 100) + `["2019-01-01T00:00:00Z",1]]}]}]}`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			w.WriteHeader(http.StatusOK)
+			_, _ = io.WriteString(w, body)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusOK)
+		gw := gzip.NewWriter(w)
+		_, _ = io.WriteString(gw, body)
+		_ = gw.Close()
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	for _, acceptGzip := range []bool{true, false} {
+		c, err := client.NewClient(client.Config{URL: *u, AcceptGzip: acceptGzip})
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+
+		resp, err := c.Query(client.Query{})
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if got, exp := len(resp.Results[0].Series[0].Values), 101; got != exp {
+			t.Fatalf("unexpected number of values: got %d, exp %d", got, exp)
+		}
+		if got, exp := resp.Transfer.DecodedBytes, int64(len(body)); got != exp {
+			t.Fatalf("unexpected decoded bytes: got %d, exp %d", got, exp)
+		}
+		if compressed := resp.Transfer.WireBytes < resp.Transfer.DecodedBytes; compressed != acceptGzip {
+			t.Fatalf("unexpected transfer stats with accept gzip %v: %+v", acceptGzip, resp.Transfer)
+		}
+	}
+}
+
+func TestClient_DisableKeepAlives(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !r.Close {
+			t.Error("expected the request to close the connection")
+		}
+		var data client.Response
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(data)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	config := client.Config{URL: *u, DisableKeepAlives: true}
+	c, err := client.NewClient(config)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	if _, err := c.Query(client.Query{}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestClient_Token(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, exp := r.Header.Get("Authorization"), "Token secret"; got != exp {
+			t.Errorf("unexpected Authorization header: %s != %s", exp, got)
+		}
+		var data client.Response
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(data)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	config := client.Config{URL: *u, Username: "user", Password: "pass", Token: "secret"}
+	c, err := client.NewClient(config)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	if _, err := c.Query(client.Query{}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestClient_Headers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, exp := r.Header.Get("X-Tenant"), "acme"; got != exp {
+			t.Errorf("unexpected X-Tenant header: %s != %s", exp, got)
+		}
+		if got, exp := r.Header.Get("Authorization"), "Token secret"; got != exp {
+			t.Errorf("unexpected Authorization header: %s != %s", exp, got)
+		}
+		w.Header().Set("X-Influxdb-Version", "x.x")
+		if r.URL.Path == "/ping" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var data client.Response
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(data)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	headers := http.Header{}
+	headers.Set("X-Tenant", "acme")
+	headers.Set("Authorization", "Bearer ignored")
+	config := client.Config{URL: *u, Token: "secret", Headers: headers}
+	c, err := client.NewClient(config)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	// Changing the config after the client is built must not affect it.
+	headers.Set("X-Tenant", "other")
+
+	if _, err := c.Query(client.Query{}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, _, err := c.Ping(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Write(client.BatchPoints{}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
 func TestClient_Query_RP(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		params := r.URL.Query()
@@ -1074,3 +1255,190 @@ func TestSplitPath(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_Query_MaxResponseSize(t *testing.T) {
+	body := `{"results":[{"series":[{"name":"cpu","columns":["time","value"],"values":[` +
+		strings.Repeat(`["2019-01-01T00:00:00Z",1],`, 100) + `["2019-01-01T00:00:00Z",1]]}]}]}`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, body)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	for _, tt := range []struct {
+		max     int64
+		chunked bool
+		fail    bool
+	}{
+		{max: 0},
+		{max: int64(len(body))},
+		{max: int64(len(body)) - 1, fail: true},
+		{max: 100, fail: true},
+		{max: 100, chunked: true, fail: true},
+	} {
+		c, err := client.NewClient(client.Config{URL: *u, MaxResponseSize: tt.max})
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+
+		_, err = c.Query(client.Query{Chunked: tt.chunked})
+		if !tt.fail {
+			if err != nil {
+				t.Fatalf("max %d: unexpected error: %s", tt.max, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), client.ErrResponseTooLarge.Error()) {
+			t.Fatalf("max %d chunked %v: expected a response size error, got %v", tt.max, tt.chunked, err)
+		}
+	}
+}
+
+func TestClient_QueryEach(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "true" {
+			t.Error("expected a chunked query")
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"results":[{"series":[{"name":"cpu","columns":["value"],"values":[[1]]}],"partial":true}]}`+"\n")
+		_, _ = io.WriteString(w, `{"results":[{"series":[{"name":"cpu","columns":["value"],"values":[[2]]}]}]}`+"\n")
+		_, _ = io.WriteString(w, `{"results":[{"error":"boom"}]}`+"\n")
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	chunks := 0
+	err = c.QueryEach(context.Background(), client.Query{}, func(r *client.Response) error {
+		chunks++
+		return nil
+	})
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chunks != 2 {
+		t.Fatalf("unexpected number of chunks: %d", chunks)
+	}
+}
+
+func TestClient_WriteLineProtocol_Gzip(t *testing.T) {
+	var rejectGzip int32
+	var compressed, plain int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			if atomic.LoadInt32(&rejectGzip) == 1 {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			atomic.AddInt32(&compressed, 1)
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("invalid gzip body: %s", err)
+				return
+			}
+			body = gr
+		} else {
+			atomic.AddInt32(&plain, 1)
+		}
+		in, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		} else if have, want := string(in), "cpu value=1 0\n"; have != want {
+			t.Errorf("unexpected write protocol: %q != %q", have, want)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u, GzipWrites: true})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.WriteLineProtocol("cpu value=1 0\n", "db", "", "", ""); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if n, m := atomic.LoadInt32(&compressed), atomic.LoadInt32(&plain); n != 3 || m != 0 {
+		t.Fatalf("unexpected writes: %d compressed, %d plain", n, m)
+	}
+
+	// A server that rejects compressed writes gets the write again
+	// uncompressed, and no compressed writes after that.
+	atomic.StoreInt32(&rejectGzip, 1)
+	for i := 0; i < 2; i++ {
+		if _, err := c.WriteLineProtocol("cpu value=1 0\n", "db", "", "", ""); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if n := atomic.LoadInt32(&plain); n != 2 {
+		t.Fatalf("unexpected number of plain writes: %d", n)
+	}
+}
+
+func TestClient_Query_Retry(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, `{"results":[{}]}`)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u, Retries: 2, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Query(client.Query{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("unexpected number of requests: %d", n)
+	}
+}
+
+func TestClient_Query_CircuitBreaker(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{
+		URL:              *u,
+		BreakerThreshold: 2,
+		BreakerWindow:    time.Minute,
+		BreakerCooldown:  time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Query(client.Query{}); err == nil || errors.Is(err, client.ErrCircuitOpen) {
+			t.Fatalf("query %d: expected a status code error, got %v", i, err)
+		}
+	}
+	if _, err := c.Query(client.Query{}); !errors.Is(err, client.ErrCircuitOpen) {
+		t.Fatalf("expected the breaker to be open, got %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("unexpected number of requests: %d", n)
+	}
+	if state := c.BreakerState(); !strings.HasPrefix(state, "open") {
+		t.Fatalf("unexpected breaker state: %s", state)
+	}
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTiming is how long the phases of a request took, so that a slow
+// network can be told apart from a slow server. A phase the request did not
+// go through, such as DNS on a reused connection, is zero.
+type RequestTiming struct {
+	// DNS is the time spent looking up the host.
+	DNS time.Duration
+
+	// Connect is the time spent opening the TCP connection.
+	Connect time.Duration
+
+	// TLS is the time spent on the TLS handshake.
+	TLS time.Duration
+
+	// Wait is the time from sending the request to the first byte of the
+	// response, which is mostly the server running the query.
+	Wait time.Duration
+
+	// FirstByte is the time from the start of the request to the first
+	// byte of the response.
+	FirstByte time.Duration
+
+	// Reused is set if the request went over an idle connection.
+	Reused bool
+}
+
+// timingRecorder records a RequestTiming from the events of an
+// httptrace.ClientTrace, which may come from several goroutines.
+type timingRecorder struct {
+	mu sync.Mutex
+	RequestTiming
+
+	start, dnsStart, connectStart, tlsStart, wroteRequest time.Time
+}
+
+// WithTiming returns a context that records the phases of the requests made
+// with it, and a function returning them. If a request is retried, each phase
+// is timed by the last attempt that reached it.
+func WithTiming(ctx context.Context) (context.Context, func() RequestTiming) {
+	t := &timingRecorder{start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			t.start = time.Now()
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.Reused = info.Reused
+			t.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.DNS = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			t.connectStart = time.Now()
+			t.mu.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			t.Connect = time.Since(t.connectStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.TLS = time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			t.wroteRequest = time.Now()
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.Wait = time.Since(t.wroteRequest)
+			t.FirstByte = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	}
+	timing := func() RequestTiming {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.RequestTiming
+	}
+	return httptrace.WithClientTrace(ctx, trace), timing
+}
@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// arrowKind is the inferred type of a result column.
+type arrowKind int
+
+const (
+	arrowNull arrowKind = iota
+	arrowBool
+	arrowInt
+	arrowFloat
+	arrowTime
+	arrowString
+)
+
+// arrowColumn is a column of the flattened response.
+type arrowColumn struct {
+	name   string
+	values []interface{}
+}
+
+// arrow runs a query and writes the result to an Arrow IPC (Feather v2) file.
+// The command has the form "arrow <path> <query>".
+func (c *CommandLine) arrow(cmd string) error {
+	args := strings.SplitN(strings.TrimSpace(cmd), " ", 3)
+	if len(args) != 3 || strings.TrimSpace(args[2]) == "" {
+		fmt.Println("Usage: arrow <path> <query>")
+		return nil
+	}
+	path, query := args[1], strings.TrimSpace(args[2])
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	response, err := c.Client.QueryContext(ctx, c.query(query))
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	if err := response.Error(); err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	n, err := writeArrow(response, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	fmt.Printf("wrote %d rows to %s\n", n, path)
+	return nil
+}
+
+// writeArrow writes the series of response as a single record batch in the
+// Arrow IPC file format and returns the number of rows written.
+//
+// The series are flattened into one table with a "name" column for the
+// measurement, a column for each tag key, and the union of the result columns.
+// Missing values are null. Column types are inferred from the values:
+//
+//   - booleans become bool
+//   - integers become int64, or float64 if the column also has floats
+//   - RFC3339 strings in the "time" column become nanosecond timestamps
+//   - other strings, and columns mixing any other types, become utf8
+//   - columns without any values become utf8
+func writeArrow(response *client.Response, w io.WriteSeeker) (int, error) {
+	columns, nrows := flattenResponse(response)
+	if nrows == 0 {
+		return 0, errors.New("query returned no rows")
+	}
+
+	fields := make([]arrow.Field, len(columns))
+	kinds := make([]arrowKind, len(columns))
+	for i, col := range columns {
+		kinds[i] = inferArrowKind(col)
+		fields[i] = arrow.Field{Name: col.name, Type: arrowType(kinds[i]), Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	mem := memory.NewGoAllocator()
+	arrays := make([]array.Interface, len(columns))
+	for i, col := range columns {
+		b := newArrowBuilder(mem, kinds[i])
+		appendArrowValues(b, kinds[i], col.values)
+		arrays[i] = b.NewArray()
+		b.Release()
+		defer arrays[i].Release()
+	}
+	rec := array.NewRecord(schema, arrays, int64(nrows))
+	defer rec.Release()
+
+	fw, err := ipc.NewFileWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	if err != nil {
+		return 0, err
+	}
+	if err := fw.Write(rec); err != nil {
+		fw.Close()
+		return 0, err
+	}
+	if err := fw.Close(); err != nil {
+		return 0, err
+	}
+	return nrows, nil
+}
+
+// flattenResponse turns the series of response into columns of equal length.
+func flattenResponse(response *client.Response) ([]*arrowColumn, int) {
+	var tagKeys []string
+	seenTags := make(map[string]bool)
+	var valueColumns []string
+	seenColumns := make(map[string]bool)
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for k := range row.Tags {
+				if !seenTags[k] {
+					seenTags[k] = true
+					tagKeys = append(tagKeys, k)
+				}
+			}
+			for _, name := range row.Columns {
+				if !seenColumns[name] {
+					seenColumns[name] = true
+					valueColumns = append(valueColumns, name)
+				}
+			}
+		}
+	}
+	sort.Strings(tagKeys)
+
+	columns := []*arrowColumn{{name: "name"}}
+	index := make(map[string]*arrowColumn)
+	for _, k := range tagKeys {
+		col := &arrowColumn{name: k}
+		columns = append(columns, col)
+		index[k] = col
+	}
+	for _, name := range valueColumns {
+		// A value column with the same name as a tag key shares its column.
+		if _, ok := index[name]; ok {
+			continue
+		}
+		col := &arrowColumn{name: name}
+		columns = append(columns, col)
+		index[name] = col
+	}
+
+	var nrows int
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, values := range row.Values {
+				nrows++
+				for _, col := range columns {
+					col.values = append(col.values, nil)
+				}
+				last := nrows - 1
+				columns[0].values[last] = row.Name
+				for k, v := range row.Tags {
+					index[k].values[last] = v
+				}
+				for i, name := range row.Columns {
+					if i < len(values) && values[i] != nil {
+						index[name].values[last] = values[i]
+					}
+				}
+			}
+		}
+	}
+	return columns, nrows
+}
+
+// inferArrowKind returns the type that can hold every value of col.
+func inferArrowKind(col *arrowColumn) arrowKind {
+	kind := arrowNull
+	for _, v := range col.values {
+		k := valueKind(v)
+		if k == arrowTime && col.name != "time" {
+			k = arrowString
+		}
+		switch {
+		case k == arrowNull || k == kind:
+		case kind == arrowNull:
+			kind = k
+		case (kind == arrowInt && k == arrowFloat) || (kind == arrowFloat && k == arrowInt):
+			kind = arrowFloat
+		default:
+			return arrowString
+		}
+	}
+	if kind == arrowNull {
+		return arrowString
+	}
+	return kind
+}
+
+// valueKind returns the type of a single decoded value.
+func valueKind(v interface{}) arrowKind {
+	switch t := v.(type) {
+	case nil:
+		return arrowNull
+	case bool:
+		return arrowBool
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return arrowInt
+		}
+		if _, err := t.Float64(); err == nil {
+			return arrowFloat
+		}
+		return arrowString
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		return arrowInt
+	case float32, float64:
+		return arrowFloat
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return arrowTime
+		}
+		return arrowString
+	default:
+		return arrowString
+	}
+}
+
+// arrowType returns the Arrow data type used for kind.
+func arrowType(kind arrowKind) arrow.DataType {
+	switch kind {
+	case arrowBool:
+		return arrow.FixedWidthTypes.Boolean
+	case arrowInt:
+		return arrow.PrimitiveTypes.Int64
+	case arrowFloat:
+		return arrow.PrimitiveTypes.Float64
+	case arrowTime:
+		return arrow.FixedWidthTypes.Timestamp_ns
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// newArrowBuilder returns a builder for kind.
+func newArrowBuilder(mem memory.Allocator, kind arrowKind) array.Builder {
+	switch kind {
+	case arrowBool:
+		return array.NewBooleanBuilder(mem)
+	case arrowInt:
+		return array.NewInt64Builder(mem)
+	case arrowFloat:
+		return array.NewFloat64Builder(mem)
+	case arrowTime:
+		return array.NewTimestampBuilder(mem, arrowType(kind).(*arrow.TimestampType))
+	default:
+		return array.NewStringBuilder(mem)
+	}
+}
+
+// appendArrowValues appends values to b, which was created for kind.
+func appendArrowValues(b array.Builder, kind arrowKind, values []interface{}) {
+	for _, v := range values {
+		if v == nil {
+			b.AppendNull()
+			continue
+		}
+		switch kind {
+		case arrowBool:
+			b.(*array.BooleanBuilder).Append(v.(bool))
+		case arrowInt:
+			b.(*array.Int64Builder).Append(toInt64(v))
+		case arrowFloat:
+			b.(*array.Float64Builder).Append(toFloat64(v))
+		case arrowTime:
+			ts, _ := time.Parse(time.RFC3339Nano, v.(string))
+			b.(*array.TimestampBuilder).Append(arrow.Timestamp(ts.UnixNano()))
+		default:
+			b.(*array.StringBuilder).Append(interfaceToString(v))
+		}
+	}
+}
+
+func toInt64(v interface{}) int64 {
+	switch t := v.(type) {
+	case json.Number:
+		n, _ := t.Int64()
+		return n
+	case int:
+		return int64(t)
+	case int8:
+		return int64(t)
+	case int16:
+		return int64(t)
+	case int32:
+		return int64(t)
+	case int64:
+		return t
+	case uint8:
+		return int64(t)
+	case uint16:
+		return int64(t)
+	case uint32:
+		return int64(t)
+	}
+	return 0
+}
+
+func toFloat64(v interface{}) float64 {
+	switch t := v.(type) {
+	case json.Number:
+		f, _ := strconv.ParseFloat(t.String(), 64)
+		return f
+	case float32:
+		return float64(t)
+	case float64:
+		return t
+	}
+	return float64(toInt64(v))
+}
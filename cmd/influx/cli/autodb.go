@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// userDatabases returns the databases of SHOW DATABASES other than _internal,
+// which an INSERT is never meant to go to.
+func (c *CommandLine) userDatabases() ([]string, error) {
+	names, err := c.listDatabases()
+	if err != nil {
+		return nil, err
+	}
+	dbs := names[:0]
+	for _, name := range names {
+		if name != "_internal" {
+			dbs = append(dbs, name)
+		}
+	}
+	return dbs, nil
+}
+
+// autoSelectDatabase sets the database of an INSERT without one when
+// AutoDatabase is set and the server has exactly one database. It selects
+// it as use would, so later statements go to the same database.
+func (c *CommandLine) autoSelectDatabase(bp *client.BatchPoints) {
+	if !c.AutoDatabase || bp.Database != "" {
+		return
+	}
+	dbs, err := c.userDatabases()
+	if err != nil || len(dbs) != 1 {
+		return
+	}
+	c.Database = dbs[0]
+	bp.Database = dbs[0]
+	fmt.Printf("Using database %s, the only database on the server\n", dbs[0])
+}
+
+// databaseHint prints how to set the database after an INSERT without one
+// failed, with the databases of the server if they can be listed.
+func (c *CommandLine) databaseHint() {
+	fmt.Println("Note: error may be due to not setting a database or retention policy.")
+	fmt.Println(`Please set a database with the command "use <database>" or`)
+	fmt.Println("INSERT INTO <database>.<retention-policy> <point>")
+
+	dbs, err := c.userDatabases()
+	switch {
+	case err != nil:
+	case len(dbs) == 0:
+		fmt.Println(`No databases exist yet. Create one with "CREATE DATABASE <database>"`)
+	case len(dbs) == 1:
+		fmt.Printf("Available database: %s. Start the shell with -auto-db to select it automatically\n", dbs[0])
+	default:
+		fmt.Printf("Available databases: %s\n", strings.Join(dbs, ", "))
+	}
+}
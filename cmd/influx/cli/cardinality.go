@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxql"
+)
+
+// cardinality handles "cardinality [exact] [measurement]", printing the
+// estimated series cardinality of the current database, or of one of its
+// measurements, and with exact also the exact count, which the server has
+// to compute by reading every series.
+func (c *CommandLine) cardinality(cmd string) error {
+	arg := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("cardinality"):]), ";"))
+	var exact bool
+	if fields := strings.Fields(arg); len(fields) > 0 && strings.EqualFold(fields[0], "exact") {
+		exact = true
+		arg = strings.TrimSpace(arg[len(fields[0]):])
+	}
+	if c.Database == "" {
+		fmt.Println(`Please set a database with the command "use <database>" first.`)
+		return nil
+	}
+	// The measurement may be quoted, as it is in queries.
+	var from string
+	if name := strings.Trim(arg, `"`); name != "" {
+		from = " FROM " + influxql.QuoteIdent(name)
+	}
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	run := func(stmt string) (int64, error) {
+		response, err := c.Client.QueryContext(ctx, c.query(stmt+from))
+		if err == nil {
+			err = response.Error()
+		}
+		if err != nil {
+			err = c.queryContextErr(ctx, err)
+			fmt.Printf("%s %s\n", c.errPrefix(), err)
+			return 0, err
+		}
+		return cardinalityCount(response), nil
+	}
+
+	n, err := run("SHOW SERIES CARDINALITY")
+	if err != nil {
+		return err
+	}
+	w := c.output()
+	fmt.Fprintf(w, "estimated series cardinality: %d\n", n)
+	if !exact {
+		return nil
+	}
+
+	fmt.Println("WARN: the exact cardinality reads every series and can be expensive on large databases")
+	if n, err = run("SHOW SERIES EXACT CARDINALITY"); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "exact series cardinality: %d\n", n)
+	return nil
+}
+
+// cardinalityCount returns the sum of the counts of a SHOW SERIES
+// CARDINALITY response, which has one series per measurement for an exact
+// count.
+func cardinalityCount(response *client.Response) int64 {
+	var sum int64
+	forEachRow(response, func(row models.Row) {
+		for _, v := range row.Values {
+			if len(v) == 0 {
+				continue
+			}
+			switch n := v[0].(type) {
+			case json.Number:
+				i, _ := n.Int64()
+				sum += i
+			case float64:
+				sum += int64(n)
+			case int64:
+				sum += n
+			}
+		}
+	})
+	return sum
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxql"
+)
+
+// cleanup handles "cleanup [-force] <measurement> where <predicate>",
+// previewing how many series and points of a measurement match a predicate
+// and, once confirmed, removing them. A predicate on tags drops the matching
+// series with DROP SERIES, and one with a time condition deletes the points
+// in that time range with DELETE. Without -force a WHERE clause is required,
+// so that a whole measurement is not removed by accident.
+func (c *CommandLine) cleanup(cmd string) error {
+	arg := strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("cleanup"):]), ";")
+	var force bool
+	if fields := strings.Fields(arg); len(fields) > 0 && strings.EqualFold(fields[0], "-force") {
+		force = true
+		arg = strings.TrimSpace(arg[len(fields[0]):])
+	}
+	if arg == "" {
+		fmt.Println("Usage: cleanup [-force] <measurement> where <predicate>")
+		return nil
+	}
+	if c.Database == "" {
+		fmt.Println(`Please set a database with the command "use <database>" first.`)
+		return nil
+	}
+
+	// Parsing the arguments as a DELETE takes care of quoted and regex
+	// measurements and of the syntax of the predicate.
+	parsed, err := influxql.ParseStatement("DELETE FROM " + arg)
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	del := parsed.(*influxql.DeleteSeriesStatement)
+	if del.Condition == nil && !force {
+		fmt.Println("cleanup without a WHERE clause removes every series of the measurement; use cleanup -force to do so")
+		return nil
+	}
+	var stmt influxql.Statement = &influxql.DropSeriesStatement{Sources: del.Sources, Condition: del.Condition}
+	if del.Condition != nil && influxql.HasTimeExpr(del.Condition) {
+		stmt = del
+	}
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	preview := "SELECT count(*) FROM " + del.Sources.String()
+	if del.Condition != nil {
+		preview += " WHERE " + del.Condition.String()
+	}
+	response, err := c.Client.QueryContext(ctx, c.query(preview+" GROUP BY *"))
+	if err == nil {
+		err = response.Error()
+	}
+	if err != nil {
+		err = c.queryContextErr(ctx, err)
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	series, points := cleanupCount(response)
+	if series == 0 {
+		fmt.Println("no series match, nothing to clean up")
+		return nil
+	}
+
+	fmt.Printf("%s\nmatches %d series and at least %d points\n", stmt, series, points)
+	if c.Line == nil {
+		fmt.Println("cleanup asks for confirmation and only runs in the interactive shell")
+		return nil
+	}
+	answer, err := c.Line.Prompt("Remove them? [y/N] ")
+	if err != nil {
+		fmt.Printf("Unable to process input: %s\n", err)
+		return err
+	}
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		fmt.Println("cleanup canceled")
+		return nil
+	}
+
+	response, err = c.Client.QueryContext(ctx, c.query(stmt.String()))
+	if err == nil {
+		err = response.Error()
+	}
+	if err != nil {
+		err = c.queryContextErr(ctx, err)
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	if _, ok := stmt.(*influxql.DropSeriesStatement); ok {
+		fmt.Fprintf(c.output(), "dropped %d series\n", series)
+	} else {
+		fmt.Fprintf(c.output(), "deleted the matching points of %d series\n", series)
+	}
+	return nil
+}
+
+// cleanupCount returns the number of series of a SELECT COUNT(*) ... GROUP
+// BY * response and the sum of their point counts.
+func cleanupCount(response *client.Response) (series, points int64) {
+	forEachRow(response, func(row models.Row) {
+		series++
+		points += pointCount(&client.Response{Results: []client.Result{{Series: []models.Row{row}}}})
+	})
+	return series, points
+}
@@ -4,22 +4,23 @@ package cli // import "github.com/influxdata/influxdb/cmd/influx/cli"
 import (
 	"bytes"
 	"context"
-	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"reflect"
+	"regexp"
 	"runtime"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"text/tabwriter"
 	"time"
@@ -36,11 +37,77 @@ import (
 // ErrBlankCommand is returned when a parsed command is empty.
 var ErrBlankCommand = errors.New("empty input")
 
+// errAborted is returned when a query is interrupted by the user.
+var errAborted = errors.New("aborted by user")
+
+// errNoStatement is returned by Run when -execute contains only whitespace
+// and comments.
+var errNoStatement = errors.New("no statement provided to -execute")
+
+// Exit codes used by the influx command to report the kind of failure.
+const (
+	ExitSuccess      = 0 // The command completed successfully.
+	ExitError        = 1 // An unclassified error occurred.
+	ExitConnectError = 2 // The server could not be reached.
+	ExitAuthError    = 3 // The server rejected the credentials.
+	ExitQueryError   = 4 // A query or write returned an error.
+	ExitParseError   = 5 // A statement could not be parsed.
+	ExitImportError  = 6 // The import did not complete.
+)
+
+// Error is an error returned by Run that carries the exit code the influx
+// command should terminate with.
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error { return e.Err }
+
+// ExitCode returns the exit code for an error returned by Run.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ExitError
+}
+
+// queryError classifies an error returned while executing a statement.
+func queryError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	var perr *influxql.ParseError
+	msg := strings.ToLower(err.Error())
+	switch {
+	case errors.As(err, &perr), strings.Contains(msg, "error parsing query"):
+		return &Error{Code: ExitParseError, Err: err}
+	case strings.Contains(msg, "authorization failed"),
+		strings.Contains(msg, "not authorized"),
+		strings.Contains(msg, "unable to parse authentication credentials"),
+		strings.Contains(msg, "received status code 401"):
+		return &Error{Code: ExitAuthError, Err: err}
+	}
+	return &Error{Code: ExitQueryError, Err: err}
+}
+
 // CommandLine holds CLI configuration and state.
 type CommandLine struct {
 	Line            *liner.State
 	URL             url.URL
 	Host            string
+	Hosts           []string // hosts tried in order for failover, set by -hosts, used instead of Host and Port; with KeepAlive set, a dropped connection moves on to the next one
 	Port            int
 	PathPrefix      string
 	Database        string
@@ -49,19 +116,86 @@ type CommandLine struct {
 	RetentionPolicy string
 	ClientVersion   string
 	ServerVersion   string
-	Pretty          bool   // controls pretty print for json
-	Format          string // controls the output format.  Valid values are json, csv, or column
+	Pretty          bool      // selects the pretty JSONStyle when Run starts, then follows it
+	JSONStyle       JSONStyle // controls the json rendering
+	Format          string    // controls the output format.  Valid values are json, ndjson, csv, column, markdown, or promql-style
 	Execute         string
+	ExecuteSet      bool // -execute was given, so an empty Execute is an error rather than interactive mode
 	ShowVersion     bool
 	Import          bool
 	Chunked         bool
 	ChunkSize       int
 	NodeID          int
+	Stats           bool          // controls printing of response transfer statistics
+	VerboseTiming   bool          // prints the DNS, connect, TLS and server time of each InfluxQL query
+	Quiet           bool          // suppresses the elapsed footer, stats and the startup banner
+	Expanded        bool          // prints each row of the column format as a record, toggled by \x
+	TimeFormat      string        // Go layout the time column is printed with, set by timefmt
+	NullString      string        // printed for null values in the column and csv formats, set by null-string
+	FloatFormat     string        // fmt verb floats are printed with in the column, csv and markdown formats, set by floatfmt
+	CreateDatabase  bool          // create the target database of INSERT statements and imports if it is missing
+	AutoDatabase    bool          // send an INSERT without a database to the only database of the server
+	CheckFieldTypes bool          // warn before inserting a field with a type other than the server's
+	SkipDBCheck     bool          // use a database or retention policy even if its existence cannot be verified
+	Pager           bool          // pipe interactive output through $PAGER
+	Prompt          string        // prompt template, see DefaultPrompt and SetPrompt
+	ContinueOnError bool          // keep running -execute or stdin statements after one fails
+	Debug           bool          // include the stack when reporting a panic while running a command
+	PasswordFile    string        // file the password is read from, set by -password-file
+	DiffHost        string        // second server queried by the diff command
+	DiffTolerance   float64       // largest difference between numbers that diff treats as equal
+	S3Endpoint      string        // S3-compatible storage written to by output s3://, instead of AWS_ENDPOINT_URL
+	S3Region        string        // region of the S3 bucket written to by output s3://, instead of AWS_REGION
+	KeepAlive       time.Duration // interval of the keepalive pings in interactive mode, 0 disables them
+	IdleTimeout     time.Duration // how long the interactive shell waits for a command before exiting, 0 means forever
+	QueryTimeout    time.Duration // how long a query may run before it is canceled, 0 means no limit
+	SlowLog         time.Duration // queries taking longer are reported on stderr, 0 disables it
+	ConnectRetries  int           // how many more times Run tries the initial connection after it fails
+	ConnectInterval time.Duration // delay between the attempts of the initial connection
 	Quit            chan struct{}
 	IgnoreSignals   bool // Ignore signals normally caught by this process (used primarily for testing)
 	ForceTTY        bool // Force the CLI to act as if it were connected to a TTY
+	Color           bool // controls ANSI color output, decided in Run from the TTY and environment
 	osSignals       chan os.Signal
 	historyFilePath string
+	lastQuery       string        // the last query run, used to seed the editor
+	tee             *teeFile      // receives a copy of query output, set by the tee command
+	outputFiles     []*outputFile // receive query results in their own formats, set by the output command
+	stdout          io.Writer     // replaces os.Stdout as the query output, used by tests
+
+	// fieldTypeCache holds the field types of the measurements inserted into
+	// while CheckFieldTypes is set.
+	fieldTypeCache map[fieldTypeKey]map[string]string
+
+	// Keepalive state. clientMu guards replacing Client while the keepalive
+	// goroutine pings, busy is set while a command runs and reconnect when a
+	// ping failed.
+	keepAlive *keepAlive
+	clientMu  sync.Mutex
+	busy      int32
+	reconnect int32
+
+	// chunkingUnsupported is set when the server is too old for chunked
+	// responses, in which case chunking is disabled regardless of Chunked.
+	chunkingUnsupported bool
+	chunkingWarned      bool
+
+	// activeHost is the index in Hosts of the host connected to.
+	activeHost int
+
+	// urlDatabaseChecked is set once Connect has looked for a database in
+	// the URL, which only the initial connection does.
+	urlDatabaseChecked bool
+
+	// fluxSession runs the lines entered after "language flux".
+	fluxSession *fluxSession
+
+	// timeRange is the time condition set by "range", added to SELECT
+	// statements without one.
+	timeRange influxql.Expr
+
+	// startup holds the settings when Run started, restored by "clear all".
+	startup *sessionSettings
 
 	Client         *client.Client
 	ClientConfig   client.Config // Client config options.
@@ -78,14 +212,151 @@ func New(version string) *CommandLine {
 	}
 }
 
+// executeStatements runs each statement through the CLI's parser. It stops at
+// the first failing statement unless ContinueOnError is set, in which case the
+// remaining statements still run and the first error is returned.
+func (c *CommandLine) executeStatements(stmts []string) error {
+	var firstErr error
+	for _, stmt := range stmts {
+		if err := c.runCommand(stmt); err != nil {
+			if !c.ContinueOnError {
+				return queryError(err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return queryError(firstErr)
+}
+
+// splitStatements splits a script into the statements separated by
+// semicolons, ignoring semicolons in quoted strings, identifiers and
+// comments. Comment lines leading a statement are removed so CLI commands
+// such as "use" are still recognized, and empty statements are dropped.
+func splitStatements(script string) []string {
+	var (
+		stmts []string
+		start int
+		quote rune
+	)
+	add := func(stmt string) {
+		if stmt = trimLeadingComments(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	for i := 0; i < len(script); i++ {
+		ch := script[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if rune(ch) == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = rune(ch)
+		case ch == '-' && strings.HasPrefix(script[i:], "--"):
+			if n := strings.IndexByte(script[i:], '\n'); n >= 0 {
+				i += n
+			} else {
+				i = len(script)
+			}
+		case ch == ';':
+			add(script[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(script) {
+		add(script[start:])
+	}
+	return stmts
+}
+
+// trimLeadingComments removes the blank and comment lines at the start of
+// stmt and trims the surrounding whitespace.
+func trimLeadingComments(stmt string) string {
+	for {
+		stmt = strings.TrimSpace(stmt)
+		if !strings.HasPrefix(stmt, "--") {
+			return stmt
+		}
+		n := strings.IndexByte(stmt, '\n')
+		if n < 0 {
+			return ""
+		}
+		stmt = stmt[n+1:]
+	}
+}
+
+// executeIsEmpty reports whether Execute has nothing to run besides
+// whitespace and comments.
+func (c *CommandLine) executeIsEmpty() bool {
+	if c.Type == QueryLanguageFlux {
+		for _, line := range strings.Split(c.Execute, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "//") {
+				return false
+			}
+		}
+		return true
+	}
+	return len(splitStatements(c.Execute)) == 0
+}
+
+// readPasswordFile returns the password stored in the file at path, without
+// the trailing newline. Unlike a missing flag, an unreadable file is an error.
+func readPasswordFile(path string) (string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read password file: %s", err)
+	}
+	return strings.TrimRight(string(buf), "\r\n"), nil
+}
+
 // Run executes the CLI.
 func (c *CommandLine) Run() error {
+	if (c.Execute != "" || c.ExecuteSet) && c.executeIsEmpty() {
+		return &Error{Code: ExitError, Err: errNoStatement}
+	}
+
+	// Credentials given with the host, as in -host user:password@host, are
+	// used like -username and -password and kept out of the URL.
+	if username, password, host, ok := splitUserInfo(c.Host); ok {
+		c.Host = host
+		c.ClientConfig.Username, c.ClientConfig.Password = username, password
+	}
+
 	hasTTY := c.ForceTTY || terminal.IsTerminal(int(os.Stdin.Fd()))
+	if c.Pretty {
+		c.JSONStyle = JSONStylePretty
+	}
+	c.Color = colorEnabled(terminal.IsTerminal(int(os.Stdout.Fd())))
+
+	// Read the password from a file given as -password-file or -password @path.
+	passwordFile := c.PasswordFile
+	if strings.HasPrefix(c.ClientConfig.Password, "@") {
+		if passwordFile != "" {
+			return errors.New("-password @path and -password-file cannot both be set")
+		}
+		passwordFile = c.ClientConfig.Password[1:]
+	} else if passwordFile != "" && c.ClientConfig.Password != "" {
+		return errors.New("-password and -password-file cannot both be set")
+	}
+	if passwordFile != "" {
+		p, err := readPasswordFile(passwordFile)
+		if err != nil {
+			return err
+		}
+		c.ClientConfig.Password = p
+	}
 
 	var promptForPassword bool
 	// determine if they set the password flag but provided no value
 	for _, v := range os.Args {
 		v = strings.ToLower(v)
+		if strings.HasPrefix(strings.TrimLeft(v, "-"), "password-file") {
+			continue
+		}
 		if (strings.HasPrefix(v, "-password") || strings.HasPrefix(v, "--password")) && c.ClientConfig.Password == "" {
 			promptForPassword = true
 			break
@@ -117,6 +388,21 @@ func (c *CommandLine) Run() error {
 		c.ClientConfig.Password = os.Getenv("INFLUX_PASSWORD")
 	}
 
+	// Fall back to the netrc file when no credentials were supplied.
+	if c.ClientConfig.Username == "" && c.ClientConfig.Password == "" {
+		login, password, ok, err := readNetrc(netrcPath(), c.Host)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARN: ignoring netrc file: %s\n", err)
+		} else if ok {
+			c.ClientConfig.Username = login
+			c.ClientConfig.Password = password
+		}
+	}
+
+	if c.ClientConfig.Token != "" && c.ClientConfig.Username != "" {
+		fmt.Fprintln(os.Stderr, "NOTE: both a token and a username were supplied; authenticating with the token.")
+	}
+
 	addr := fmt.Sprintf("%s:%d/%s", c.Host, c.Port, c.PathPrefix)
 	url, err := client.ParseConnectionString(addr, c.Ssl)
 	if err != nil {
@@ -125,7 +411,7 @@ func (c *CommandLine) Run() error {
 
 	c.URL = url
 
-	if err := c.Connect(""); err != nil {
+	if err := c.connectRetrying(); err != nil {
 		msg := "Please check your connection settings and ensure 'influxd' is running."
 		if !c.Ssl && strings.Contains(err.Error(), "malformed HTTP response") {
 			// Attempt to connect with SSL and disable secure SSL for this test.
@@ -145,27 +431,27 @@ func (c *CommandLine) Run() error {
 			}
 			c.ClientConfig.UnsafeSsl = false
 		}
-		return fmt.Errorf("Failed to connect to %s: %s\n%s", c.Client.Addr(), err.Error(), msg)
+		return &Error{Code: ExitConnectError, Err: fmt.Errorf("Failed to connect to %s: %s\n%s", c.Client.Addr(), err.Error(), msg)}
 	}
 
 	// Modify precision.
 	c.SetPrecision(c.ClientConfig.Precision)
+	c.saveSettings()
+
+	// Flush and close the files written by tee and output, which are also
+	// closed on exit in interactive mode.
+	defer c.closeTee()
+	defer c.closeOutputFiles()
 
 	if c.Execute != "" {
 		switch c.Type {
 		case QueryLanguageFlux:
-			return c.ExecuteFluxQuery(c.Execute)
+			return queryError(c.ExecuteFluxQuery(c.Execute))
 		default:
 			// Make the non-interactive mode send everything through the CLI's parser
 			// the same way the interactive mode works
-			lines := strings.Split(c.Execute, "\n")
-			for _, line := range lines {
-				if err := c.ParseCommand(line); err != nil {
-					return err
-				}
-			}
+			return c.executeStatements(strings.Split(c.Execute, "\n"))
 		}
-		return nil
 	}
 
 	if c.Import {
@@ -174,11 +460,11 @@ func (c *CommandLine) Run() error {
 		config := c.ImporterConfig
 		config.Config = c.ClientConfig
 		config.URL = c.URL
+		config.CreateDatabase = c.CreateDatabase
 
 		i := v8.NewImporter(config)
 		if err := i.Import(); err != nil {
-			err = fmt.Errorf("ERROR: %s", err)
-			return err
+			return &Error{Code: ExitImportError, Err: fmt.Errorf("ERROR: %s", err)}
 		}
 		return nil
 	}
@@ -191,9 +477,10 @@ func (c *CommandLine) Run() error {
 
 		switch c.Type {
 		case QueryLanguageFlux:
-			return c.ExecuteFluxQuery(string(cmd))
+			return queryError(c.ExecuteFluxQuery(string(cmd)))
 		default:
-			return c.ExecuteQuery(string(cmd))
+			c.applyDirectives(string(cmd))
+			return c.executeStatements(splitStatements(string(cmd)))
 		}
 	}
 
@@ -205,19 +492,24 @@ func (c *CommandLine) Run() error {
 	if len(c.ServerVersion) == 0 {
 		fmt.Printf("WARN: Connected to %s, but found no server version.\n", c.Client.Addr())
 		fmt.Printf("Are you sure an InfluxDB server is listening at the given address?\n")
-	} else {
+	} else if !c.Quiet {
 		fmt.Printf("Connected to %s version %s\n", c.Client.Addr(), c.ServerVersion)
 	}
 
-	c.Version()
+	if !c.Quiet {
+		c.Version()
+	}
+	c.warnUnknownPlaceholders(c.Prompt)
 
 	if c.Type == QueryLanguageFlux {
-		repl, err := getFluxREPL(c.URL, c.ClientConfig.Username, c.ClientConfig.Password)
+		repl, err := newFluxREPL(context.Background(), c.URL, c.ClientConfig.Username, c.ClientConfig.Password)
 		if err != nil {
 			return err
 		}
+		// The REPL returns when the user quits. Exiting the process is left
+		// to the caller so the shell can be embedded.
 		repl.Run()
-		os.Exit(0)
+		return nil
 	}
 
 	c.Line = liner.NewLiner()
@@ -252,6 +544,10 @@ func (c *CommandLine) Run() error {
 
 // mainLoop runs the main prompt loop for the CLI.
 func (c *CommandLine) mainLoop() error {
+	c.startKeepAlive()
+	// next is a line read while collecting a paste, run before prompting
+	// again.
+	var next string
 	for {
 		select {
 		case <-c.osSignals:
@@ -261,15 +557,32 @@ func (c *CommandLine) mainLoop() error {
 			c.exit()
 			return nil
 		default:
-			l, e := c.Line.Prompt("> ")
-			if e == io.EOF {
+			var l string
+			var e error
+			if next != "" {
+				l, next = next, ""
+			} else {
+				l, e = c.readLine()
+			}
+			if e == nil && isInsert(l) {
+				l, next, e = c.collectPaste(l)
+			}
+			if e == errIdleTimeout {
+				fmt.Printf("\nNo command entered for %s, exiting.\n", c.IdleTimeout)
+				c.exit()
+				return nil
+			} else if e == io.EOF {
 				// Instead of die, register that someone exited the program gracefully
 				l = "exit"
 			} else if e != nil {
 				c.exit()
 				return e
 			}
-			if err := c.ParseCommand(l); err != ErrBlankCommand && !strings.HasPrefix(strings.TrimSpace(l), "auth") {
+			c.reconnectIfNeeded()
+			atomic.StoreInt32(&c.busy, 1)
+			err := c.runCommand(l)
+			atomic.StoreInt32(&c.busy, 0)
+			if err != ErrBlankCommand && !isCredentialCommand(l) {
 				l = influxql.Sanitize(l)
 				c.Line.AppendHistory(l)
 				c.saveHistory()
@@ -284,6 +597,14 @@ func (c *CommandLine) ParseCommand(cmd string) error {
 	lcmd := strings.TrimSpace(strings.ToLower(cmd))
 	tokens := strings.Fields(lcmd)
 
+	if len(tokens) > 0 && strings.HasPrefix(tokens[0], `\`) {
+		return c.metaCommand(cmd)
+	}
+
+	if c.Type == QueryLanguageFlux && len(tokens) > 0 && !fluxModeCommands[tokens[0]] {
+		return c.executeFluxLine(cmd)
+	}
+
 	if len(tokens) > 0 {
 		switch tokens[0] {
 		case "exit", "quit":
@@ -294,18 +615,79 @@ func (c *CommandLine) ParseCommand(cmd string) error {
 			return c.Connect(cmd)
 		case "auth":
 			c.SetAuth(cmd)
+		case "token":
+			c.SetToken(cmd)
+		case "header":
+			c.header(cmd)
 		case "help":
 			c.help()
+		case "language":
+			return c.setLanguage(cmd)
 		case "history":
-			c.history()
+			return c.history(cmd)
+		case "edit":
+			return c.edit()
+		case "arrow":
+			return c.arrow(cmd)
+		case "tee":
+			return c.setTee(cmd)
+		case "output":
+			return c.setOutput(cmd)
+		case "fieldtypes":
+			return c.fieldTypes(cmd)
+		case "prompt":
+			c.SetPrompt(cmd)
+		case "count":
+			return c.count(cmd)
+		case "cardinality":
+			return c.cardinality(cmd)
+		case "cleanup":
+			return c.cleanup(cmd)
+		case "for-each-db":
+			return c.forEachDB(cmd)
+		case "diff":
+			return c.diff(cmd)
+		case "explain":
+			return c.explain(cmd)
+		case "repeat":
+			return c.repeat(cmd)
+		case "sparkline":
+			return c.sparkline(cmd)
+		case "schema":
+			return c.schema(cmd)
+		case "decompress":
+			return c.decompress(cmd)
+		case "export":
+			if f := strings.Fields(cmd); len(f) > 1 && strings.EqualFold(f[1], "db") {
+				return c.exportDB(cmd)
+			}
+			return c.exportBy(cmd)
+		case "keepalive":
+			c.setKeepAlive(cmd)
+		case "timeout":
+			c.setTimeout(cmd)
+		case "timing":
+			c.setTiming(cmd)
+		case "slowlog":
+			c.setSlowLog(cmd)
 		case "format":
 			c.SetFormat(cmd)
+		case "timefmt":
+			c.setTimeFormat(cmd)
+		case "range":
+			c.setTimeRange(cmd)
+		case "floatfmt":
+			c.setFloatFormat(cmd)
+		case "null-string":
+			c.setNullString(cmd)
 		case "precision":
 			c.SetPrecision(cmd)
 		case "consistency":
 			c.SetWriteConsistency(cmd)
+		case "status":
+			return c.status()
 		case "settings":
-			c.Settings()
+			return c.settings(cmd)
 		case "chunked":
 			c.Chunked = !c.Chunked
 			if c.Chunked {
@@ -315,13 +697,41 @@ func (c *CommandLine) ParseCommand(cmd string) error {
 			}
 		case "chunk":
 			c.SetChunkSize(cmd)
+		case "quiet":
+			c.Quiet = !c.Quiet
+			if c.Quiet {
+				fmt.Println("quiet output enabled")
+			} else {
+				fmt.Println("quiet output disabled")
+			}
+		case "stats":
+			if len(tokens) > 1 && tokens[1] == "json" {
+				return c.statsJSON()
+			}
+			c.Stats = !c.Stats
+			if c.Stats {
+				fmt.Println("response stats enabled")
+			} else {
+				fmt.Println("response stats disabled")
+			}
 		case "pretty":
-			c.Pretty = !c.Pretty
-			if c.Pretty {
+			if c.JSONStyle == JSONStylePretty {
+				c.setJSONStyle(JSONStyleNormal)
+				fmt.Println("Pretty print disabled")
+			} else {
+				c.setJSONStyle(JSONStylePretty)
 				fmt.Println("Pretty print enabled")
+			}
+		case "compact":
+			if c.JSONStyle == JSONStyleCompact {
+				c.setJSONStyle(JSONStyleNormal)
+				fmt.Println("Compact json disabled")
 			} else {
-				fmt.Println("Pretty print disabled")
+				c.setJSONStyle(JSONStyleCompact)
+				fmt.Println("Compact json enabled")
 			}
+		case "pager":
+			c.setPager(cmd)
 		case "use":
 			c.use(cmd)
 		case "node":
@@ -339,19 +749,78 @@ func (c *CommandLine) ParseCommand(cmd string) error {
 	return ErrBlankCommand
 }
 
+// connectRetrying makes the initial connection of Run, trying again
+// ConnectRetries times, ConnectInterval apart, while the server cannot be
+// reached, as it may still be starting. Other errors, such as a failed
+// authentication or a TLS mismatch, are returned at once. With Hosts set,
+// every attempt tries each of them. An interrupt while waiting to retry
+// stops the attempts.
+func (c *CommandLine) connectRetrying() error {
+	err := c.connectHosts(0)
+	for i := 1; err != nil && isDialError(err) && i <= c.ConnectRetries; i++ {
+		fmt.Fprintf(os.Stderr, "Failed to connect: %s; retrying in %s (%d/%d)\n", err, c.ConnectInterval, i, c.ConnectRetries)
+		if !c.waitInterruptible(c.ConnectInterval) {
+			return errAborted
+		}
+		err = c.connectHosts(0)
+	}
+	return err
+}
+
+// waitInterruptible waits d, returning false if it was interrupted first.
+// Run registers for the interrupts only once connected, so they are
+// registered for the wait alone.
+func (c *CommandLine) waitInterruptible(d time.Duration) bool {
+	if c.IgnoreSignals {
+		time.Sleep(d)
+		return true
+	}
+	signal.Notify(c.osSignals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(c.osSignals)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.osSignals:
+		return false
+	}
+}
+
+// isDialError reports whether err is a failure to open a connection to the
+// server, such as a refused connection.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
 // Connect connects to a server.
 func (c *CommandLine) Connect(cmd string) error {
-	// normalize cmd
-	cmd = strings.ToLower(cmd)
-
 	ClientConfig := c.ClientConfig
 
-	// Remove the "connect" keyword if it exists
-	addr := strings.TrimSpace(strings.Replace(cmd, "connect", "", -1))
+	// Remove the "connect" keyword if it exists. The rest of the command is
+	// not lowercased as it may hold credentials.
+	addr := strings.TrimSpace(cmd)
+	if len(addr) >= len("connect") && strings.EqualFold(addr[:len("connect")], "connect") {
+		addr = strings.TrimSpace(addr[len("connect"):])
+	}
+	ssl := c.Ssl
+	switch lower := strings.ToLower(addr); {
+	case strings.HasPrefix(lower, "http://"):
+		addr, ssl = addr[len("http://"):], false
+	case strings.HasPrefix(lower, "https://"):
+		addr, ssl = addr[len("https://"):], true
+	}
+	credentials := false
+	if username, password, host, ok := splitUserInfo(addr); ok {
+		ClientConfig.Username, ClientConfig.Password = username, password
+		addr = host
+		credentials = true
+	}
 	if addr == "" {
 		ClientConfig.URL = c.URL
 	} else {
-		url, err := client.ParseConnectionString(addr, c.Ssl)
+		url, err := client.ParseConnectionString(addr, ssl)
 		if err != nil {
 			return err
 		}
@@ -366,7 +835,10 @@ func (c *CommandLine) Connect(cmd string) error {
 	if err != nil {
 		return fmt.Errorf("Could not create client %s", err)
 	}
+	c.clientMu.Lock()
 	c.Client = client
+	c.clientMu.Unlock()
+	c.fieldTypeCache = nil
 
 	_, v, err := c.Client.Ping()
 	if err != nil {
@@ -374,12 +846,100 @@ func (c *CommandLine) Connect(cmd string) error {
 	}
 	c.ServerVersion = v
 
+	c.chunkingUnsupported = !chunkingSupported(v)
+	if c.chunkingUnsupported && c.Chunked && !c.chunkingWarned {
+		fmt.Printf("WARN: server version %s does not support chunked responses; chunking disabled\n", v)
+		c.chunkingWarned = true
+	}
+
+	// The last segment of the path may name a database rather than be part
+	// of a proxy prefix. An explicit database is kept. Only the initial
+	// connection checks, so that reconnecting and failing over do not query
+	// the server again.
+	if !c.urlDatabaseChecked {
+		c.urlDatabaseChecked = true
+		if db, dbClient, u, ok := c.databaseFromURL(ClientConfig); ok {
+			c.clientMu.Lock()
+			c.Client = dbClient
+			c.clientMu.Unlock()
+			ClientConfig.URL = u
+			if c.Database == "" {
+				c.Database = db
+				fmt.Printf("Using database %s from the URL\n", db)
+			}
+		}
+	}
+
 	// Update the command with the current connection information
 	c.URL = ClientConfig.URL
+	if credentials {
+		c.ClientConfig.Username = ClientConfig.Username
+		c.ClientConfig.Password = ClientConfig.Password
+	}
 
 	return nil
 }
 
+// databaseFromURL reports whether the last segment of the path of
+// config.URL is a database of the server at the rest of the path. If it is,
+// the database is returned with a client of the URL without it. The query
+// can be interrupted and is limited by QueryTimeout.
+func (c *CommandLine) databaseFromURL(config client.Config) (string, *client.Client, url.URL, bool) {
+	path := strings.Trim(config.URL.Path, "/")
+	if path == "" {
+		return "", nil, url.URL{}, false
+	}
+	i := strings.LastIndex(path, "/")
+	db := path[i+1:]
+	config.URL.Path = path[:i+1]
+
+	cl, err := client.NewClient(config)
+	if err != nil {
+		return "", nil, url.URL{}, false
+	}
+	ctx, cancel := c.queryContext(context.Background())
+	defer cancel()
+	response, err := cl.QueryContext(ctx, client.Query{Command: "SHOW DATABASES"})
+	if err != nil || response.Error() != nil {
+		return "", nil, url.URL{}, false
+	}
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, values := range row.Values {
+				if len(values) > 0 && values[0] == db {
+					return db, cl, config.URL, true
+				}
+			}
+		}
+	}
+	return "", nil, url.URL{}, false
+}
+
+// splitUserInfo splits "user:password@host" into its parts. The host starts
+// after the last "@", so the password may contain "@" and ":". Percent-encoded
+// characters in the user info are decoded. ok is false if there is no user info.
+func splitUserInfo(addr string) (username, password, host string, ok bool) {
+	i := strings.LastIndex(addr, "@")
+	if i < 0 {
+		return "", "", addr, false
+	}
+	userinfo, host := addr[:i], addr[i+1:]
+	username = userinfo
+	if j := strings.Index(userinfo, ":"); j >= 0 {
+		username, password = userinfo[:j], userinfo[j+1:]
+	}
+	return unescapeUserInfo(username), unescapeUserInfo(password), host, true
+}
+
+// unescapeUserInfo decodes percent-encoded characters in s, returning s
+// unchanged if it is not validly encoded.
+func unescapeUserInfo(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
 // SetAuth sets client authentication credentials.
 func (c *CommandLine) SetAuth(cmd string) {
 	// If they pass in the entire command, we should parse it
@@ -413,6 +973,54 @@ func (c *CommandLine) SetAuth(cmd string) {
 	c.Client.SetAuth(c.ClientConfig.Username, c.ClientConfig.Password)
 }
 
+// SetToken sets the token sent in the Authorization header. Without an
+// argument the token is prompted for so it stays out of the history.
+func (c *CommandLine) SetToken(cmd string) {
+	args := strings.Fields(cmd)
+	if len(args) == 2 {
+		c.ClientConfig.Token = args[1]
+	} else {
+		t, e := c.Line.PasswordPrompt("token: ")
+		if e != nil {
+			fmt.Printf("Unable to process input: %s", e)
+			return
+		}
+		c.ClientConfig.Token = strings.TrimSpace(t)
+	}
+
+	if c.ClientConfig.Token != "" && c.ClientConfig.Username != "" {
+		fmt.Println("NOTE: a username is also set; authenticating with the token.")
+	}
+
+	// Update the client as well
+	c.Client.SetToken(c.ClientConfig.Token)
+}
+
+// isCredentialCommand returns true if cmd may contain credentials and must
+// not be saved to the history.
+func isCredentialCommand(cmd string) bool {
+	cmd = strings.TrimSpace(cmd)
+	lcmd := strings.ToLower(cmd)
+	return strings.HasPrefix(cmd, "auth") || strings.HasPrefix(lcmd, "token") ||
+		(strings.HasPrefix(lcmd, "connect") && strings.Contains(cmd, "@"))
+}
+
+// maxResponseSize formats the response size limit for the settings.
+func maxResponseSize(n int64) string {
+	if n <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// maskToken hides everything but the presence of a token.
+func maskToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	return "********"
+}
+
 func (c *CommandLine) clear(cmd string) {
 	args := strings.Split(strings.TrimSuffix(strings.TrimSpace(cmd), ";"), " ")
 	v := strings.ToLower(strings.Join(args[1:], " "))
@@ -425,6 +1033,13 @@ func (c *CommandLine) clear(cmd string) {
 		c.RetentionPolicy = ""
 		fmt.Println("retention policy context cleared")
 		return
+	case "all":
+		c.Database = ""
+		c.RetentionPolicy = ""
+		c.NodeID = 0
+		c.restoreSettings()
+		fmt.Println("database, retention policy and node id cleared; session settings reset to their values at startup")
+		return
 	default:
 		if len(args) > 1 {
 			fmt.Printf("invalid command %q.\n", v)
@@ -437,10 +1052,99 @@ func (c *CommandLine) clear(cmd string) {
     # Clear the retention policy context
     clear retention policy
     clear rp
+
+    # Clear all of the above and the node id, and reset to their values at
+    # startup the format, precision, consistency, pretty/compact, chunked,
+    # chunk size, stats, quiet, timing, timeout, slowlog, timefmt, floatfmt,
+    # null-string, range, language, prompt, pager and \x settings. Files
+    # opened by tee and output, keepalive and the connection are kept.
+    clear all
 		`)
 	}
 }
 
+// sessionSettings are the settings changed by the shell commands, which
+// "clear all" resets.
+type sessionSettings struct {
+	format        string
+	precision     string
+	consistency   string
+	jsonStyle     JSONStyle
+	chunked       bool
+	chunkSize     int
+	stats         bool
+	quiet         bool
+	verboseTiming bool
+	queryTimeout  time.Duration
+	slowLog       time.Duration
+	timeFormat    string
+	floatFormat   string
+	nullString    string
+	timeRange     influxql.Expr
+	lang          QueryLanguage
+	prompt        string
+	pager         bool
+	expanded      bool
+}
+
+// saveSettings records the current settings for restoreSettings.
+func (c *CommandLine) saveSettings() {
+	c.startup = &sessionSettings{
+		format:        c.Format,
+		precision:     c.ClientConfig.Precision,
+		consistency:   c.ClientConfig.WriteConsistency,
+		jsonStyle:     c.JSONStyle,
+		chunked:       c.Chunked,
+		chunkSize:     c.ChunkSize,
+		stats:         c.Stats,
+		quiet:         c.Quiet,
+		verboseTiming: c.VerboseTiming,
+		queryTimeout:  c.QueryTimeout,
+		slowLog:       c.SlowLog,
+		timeFormat:    c.TimeFormat,
+		floatFormat:   c.FloatFormat,
+		nullString:    c.NullString,
+		timeRange:     c.timeRange,
+		lang:          c.Type,
+		prompt:        c.Prompt,
+		pager:         c.Pager,
+		expanded:      c.Expanded,
+	}
+}
+
+// restoreSettings restores the settings recorded by saveSettings, if any.
+func (c *CommandLine) restoreSettings() {
+	s := c.startup
+	if s == nil {
+		return
+	}
+	c.Format = s.format
+	c.ClientConfig.Precision = s.precision
+	if c.Client != nil {
+		c.Client.SetPrecision(s.precision)
+	}
+	c.ClientConfig.WriteConsistency = s.consistency
+	c.setJSONStyle(s.jsonStyle)
+	c.Chunked = s.chunked
+	c.ChunkSize = s.chunkSize
+	c.Stats = s.stats
+	c.Quiet = s.quiet
+	c.VerboseTiming = s.verboseTiming
+	c.QueryTimeout = s.queryTimeout
+	c.SlowLog = s.slowLog
+	c.TimeFormat = s.timeFormat
+	c.FloatFormat = s.floatFormat
+	c.NullString = s.nullString
+	c.timeRange = s.timeRange
+	if c.Type != s.lang {
+		c.Type = s.lang
+		c.fluxSession = nil
+	}
+	c.Prompt = s.prompt
+	c.Pager = s.pager
+	c.Expanded = s.expanded
+}
+
 func (c *CommandLine) use(cmd string) {
 	args := strings.SplitAfterN(strings.TrimSuffix(strings.TrimSpace(cmd), ";"), " ", 2)
 	if len(args) != 2 {
@@ -451,7 +1155,10 @@ func (c *CommandLine) use(cmd string) {
 	stmt := args[1]
 	db, rp, err := parseDatabaseAndRetentionPolicy([]byte(stmt))
 	if err != nil {
-		fmt.Printf("Unable to parse database or retention policy from %s", stmt)
+		fmt.Printf("Unable to parse database or retention policy: %s\n", err)
+		return
+	} else if db == "" {
+		fmt.Printf("Missing database name in %s\n", strings.TrimSpace(stmt))
 		return
 	}
 
@@ -476,10 +1183,14 @@ func (c *CommandLine) databaseExists(db string) bool {
 	// Validate if specified database exists
 	response, err := c.Client.Query(client.Query{Command: "SHOW DATABASES"})
 	if err != nil {
+		if c.SkipDBCheck {
+			fmt.Printf("WARN: unable to verify database %s exists: %s\n", db, err)
+			return true
+		}
 		fmt.Printf("ERR: %s\n", err)
 		return false
 	} else if err := response.Error(); err != nil {
-		if c.ClientConfig.Username == "" {
+		if c.ClientConfig.Username == "" && !c.SkipDBCheck {
 			fmt.Printf("ERR: %s\n", err)
 			return false
 		}
@@ -513,14 +1224,88 @@ func (c *CommandLine) databaseExists(db string) bool {
 	return true
 }
 
+// listDatabases returns the names of the databases returned by SHOW DATABASES.
+func (c *CommandLine) listDatabases() ([]string, error) {
+	response, err := c.Client.Query(client.Query{Command: "SHOW DATABASES"})
+	if err != nil {
+		return nil, err
+	} else if err := response.Error(); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			if row.Name != "databases" {
+				continue
+			}
+			for _, values := range row.Values {
+				for _, v := range values {
+					if name, ok := v.(string); ok {
+						names = append(names, name)
+					}
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// ensureDatabase creates db if it does not exist yet, CREATE DATABASE doing
+// nothing for an existing database. If rp is set and db has no such retention
+// policy, rp is created with an infinite duration.
+func (c *CommandLine) ensureDatabase(db, rp string) error {
+	query := func(stmt string) (*client.Response, error) {
+		response, err := c.Client.Query(client.Query{Command: stmt})
+		if err != nil {
+			return nil, err
+		} else if err := response.Error(); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+
+	if _, err := query(fmt.Sprintf("CREATE DATABASE %s", influxql.QuoteIdent(db))); err != nil {
+		return fmt.Errorf("unable to create database %s: %s", db, err)
+	}
+	if rp == "" {
+		return nil
+	}
+
+	response, err := query(fmt.Sprintf("SHOW RETENTION POLICIES ON %s", influxql.QuoteIdent(db)))
+	if err != nil {
+		return fmt.Errorf("unable to check if retention policy %s exists: %s", rp, err)
+	}
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, values := range row.Values {
+				if len(values) > 0 && values[0] == rp {
+					return nil
+				}
+			}
+		}
+	}
+
+	stmt := fmt.Sprintf("CREATE RETENTION POLICY %s ON %s DURATION INF REPLICATION 1", influxql.QuoteIdent(rp), influxql.QuoteIdent(db))
+	if _, err := query(stmt); err != nil {
+		return fmt.Errorf("unable to create retention policy %s on database %s: %s", rp, db, err)
+	}
+	fmt.Printf("Created retention policy %s on database %s\n", rp, db)
+	return nil
+}
+
 func (c *CommandLine) retentionPolicyExists(db, rp string) bool {
 	// Validate if specified database exists
 	response, err := c.Client.Query(client.Query{Command: fmt.Sprintf("SHOW RETENTION POLICIES ON %q", db)})
 	if err != nil {
+		if c.SkipDBCheck {
+			fmt.Printf("WARN: unable to verify retention policy %s exists: %s\n", rp, err)
+			return true
+		}
 		fmt.Printf("ERR: %s\n", err)
 		return false
 	} else if err := response.Error(); err != nil {
-		if c.ClientConfig.Username == "" {
+		if c.ClientConfig.Username == "" && !c.SkipDBCheck {
 			fmt.Printf("ERR: %s\n", err)
 			return false
 		}
@@ -552,8 +1337,9 @@ func (c *CommandLine) retentionPolicyExists(db, rp string) bool {
 }
 
 func (c *CommandLine) node(cmd string) {
-	args := strings.Split(strings.TrimSuffix(strings.TrimSpace(cmd), ";"), " ")
-	if len(args) != 2 {
+	args := strings.Fields(strings.TrimSuffix(strings.TrimSpace(cmd), ";"))
+	verify := len(args) == 3 && strings.EqualFold(args[2], "verify")
+	if len(args) != 2 && !verify {
 		fmt.Println("Improper number of arguments for 'node' command, requires exactly one.")
 		return
 	}
@@ -569,6 +1355,50 @@ func (c *CommandLine) node(cmd string) {
 		return
 	}
 	c.NodeID = id
+
+	if verify && !c.nodeExists(id) {
+		fmt.Printf("WARN: node %d does not own any shards. Run SHOW SHARDS for a list of shard owners.\n", id)
+	}
+}
+
+// nodeExists reports whether id is listed as an owner of any shard in the
+// output of SHOW SHARDS. It returns true if the check cannot be performed.
+func (c *CommandLine) nodeExists(id int) bool {
+	response, err := c.Client.Query(client.Query{Command: "SHOW SHARDS"})
+	if err != nil {
+		fmt.Printf("WARN: unable to verify node: %s\n", err)
+		return true
+	} else if err := response.Error(); err != nil {
+		fmt.Printf("WARN: unable to verify node: %s\n", err)
+		return true
+	}
+
+	want := strconv.Itoa(id)
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			idx := -1
+			for i, col := range row.Columns {
+				if col == "owners" {
+					idx = i
+				}
+			}
+			if idx < 0 {
+				continue
+			}
+			for _, values := range row.Values {
+				if idx >= len(values) {
+					continue
+				}
+				owners, _ := values[idx].(string)
+				for _, owner := range strings.Split(owners, ",") {
+					if strings.TrimSpace(owner) == want {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
 }
 
 // SetChunkSize sets the chunk size
@@ -623,12 +1453,11 @@ func (c *CommandLine) SetFormat(cmd string) {
 	// Remove the "format" keyword if it exists
 	cmd = strings.TrimSpace(strings.Replace(cmd, "format", "", -1))
 
-	switch cmd {
-	case "json", "csv", "column":
-		c.Format = cmd
-	default:
-		fmt.Printf("Unknown format %q. Please use json, csv, or column.\n", cmd)
+	if !isFormat(cmd) {
+		fmt.Printf("Unknown format %q. Please use json, ndjson, csv, column, markdown, or promql-style.\n", cmd)
+		return
 	}
+	c.Format = cmd
 }
 
 // SetWriteConsistency sets write consistency level.
@@ -714,9 +1543,7 @@ func (c *CommandLine) parseInto(stmt string) *client.BatchPoints {
 	}
 
 	return &client.BatchPoints{
-		Points: []client.Point{
-			client.Point{Raw: stmt},
-		},
+		Points:           rawPoints(stmt),
 		Database:         db,
 		RetentionPolicy:  rp,
 		Precision:        c.ClientConfig.Precision,
@@ -734,9 +1561,7 @@ func (c *CommandLine) parseInsert(stmt string) (*client.BatchPoints, error) {
 		return bp, nil
 	}
 	return &client.BatchPoints{
-		Points: []client.Point{
-			client.Point{Raw: point},
-		},
+		Points:           rawPoints(point),
 		Database:         c.Database,
 		RetentionPolicy:  c.RetentionPolicy,
 		Precision:        c.ClientConfig.Precision,
@@ -752,93 +1577,189 @@ func (c *CommandLine) Insert(stmt string) error {
 		return nil
 	}
 
+	c.autoSelectDatabase(bp)
+	if c.CreateDatabase && bp.Database != "" {
+		if err := c.ensureDatabase(bp.Database, bp.RetentionPolicy); err != nil {
+			fmt.Printf("ERR: %s\n", err)
+			return nil
+		}
+	}
+
+	var written map[fieldTypeKey]map[string]string
+	if c.CheckFieldTypes {
+		written = c.checkFieldTypes(bp)
+	}
+
+	// The elapsed time goes to the query output writer, so a tee file gets it too.
+	w := c.output()
 	start := time.Now()
-	defer func() { fmt.Printf("\nelapsed:%s\n", time.Since(start).String()) }()
+	defer c.writeElapsed(w, start)
 
 	if _, err := c.Client.Write(*bp); err != nil {
-		fmt.Printf("ERR: %s\n", err)
-		if c.Database == "" {
-			fmt.Println("Note: error may be due to not setting a database or retention policy.")
-			fmt.Println(`Please set a database with the command "use <database>" or`)
-			fmt.Println("INSERT INTO <database>.<retention-policy> <point>")
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		if bp.Database == "" {
+			c.databaseHint()
 		}
+		return nil
 	}
+	c.rememberFieldTypes(written)
 	return nil
 }
 
+// writeElapsed writes the time since start as the footer of a query's output,
+// unless Quiet is set.
+func (c *CommandLine) writeElapsed(w io.Writer, start time.Time) {
+	if c.Quiet {
+		return
+	}
+	fmt.Fprintf(w, "\nelapsed:%s\n", time.Since(start).String())
+}
+
 // query creates a query struct to be used with the client.
 func (c *CommandLine) query(query string) client.Query {
 	return client.Query{
 		Command:         query,
 		Database:        c.Database,
 		RetentionPolicy: c.RetentionPolicy,
-		Chunked:         c.Chunked,
+		Chunked:         c.chunked(),
 		ChunkSize:       c.ChunkSize,
 		NodeID:          c.NodeID,
 	}
 }
 
+// chunked returns true if responses are requested in chunks. This is false
+// when the server does not support chunking, even if it was turned on.
+func (c *CommandLine) chunked() bool {
+	return c.Chunked && !c.chunkingUnsupported
+}
+
+// minChunkingVersion is the first server version with chunked responses.
+var minChunkingVersion = [2]int{0, 13}
+
+// versionPattern matches the major and minor parts of a server version.
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)`)
+
+// chunkingSupported returns true if a server with the given version supports
+// chunked responses. Versions that cannot be parsed are assumed to support it.
+func chunkingSupported(version string) bool {
+	m := versionPattern.FindStringSubmatch(version)
+	if m == nil {
+		return true
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	if major != minChunkingVersion[0] {
+		return major > minChunkingVersion[0]
+	}
+	return minor >= minChunkingVersion[1]
+}
+
+// colorEnabled decides whether ANSI color output should be used. A non-empty
+// NO_COLOR disables color regardless of the terminal and FORCE_COLOR enables
+// it even when the output is piped. See https://no-color.org.
+func colorEnabled(isTTY bool) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if _, ok := os.LookupEnv("FORCE_COLOR"); ok {
+		return true
+	}
+	return isTTY
+}
+
+// errPrefix returns the prefix used for error output, colored red when
+// color output is enabled.
+func (c *CommandLine) errPrefix() string {
+	if c.Color {
+		return "\x1b[31mERR:\x1b[0m"
+	}
+	return "ERR:"
+}
+
+// parseRetentionPolicyDirective splits a leading "rp <name>;" directive off
+// of query. The directive sets the retention policy for that query only.
+func parseRetentionPolicyDirective(query string) (rp, remainder string, ok bool) {
+	ident, rest := parseNextIdentifier(query)
+	if !strings.EqualFold(ident, "rp") || len(rest) == 0 || !isWhitespace(rune(rest[0])) {
+		return "", query, false
+	}
+	rp, rest = parseNextIdentifier(rest)
+	rest = strings.TrimLeftFunc(rest, isWhitespace)
+	if rp == "" || !strings.HasPrefix(rest, ";") {
+		return "", query, false
+	}
+	return rp, strings.TrimSpace(rest[1:]), true
+}
+
 // ExecuteQuery runs any query statement.
 func (c *CommandLine) ExecuteQuery(query string) error {
-	// If we have a retention policy, we need to rewrite the statement sources
-	if c.RetentionPolicy != "" {
+	return c.ExecuteQueryContext(context.Background(), query)
+}
+
+// ExecuteQueryContext runs any query statement and can be canceled through
+// ctx. Unless signals are ignored, an interrupt also cancels the query.
+func (c *CommandLine) ExecuteQueryContext(ctx context.Context, query string) error {
+	c.lastQuery = query
+
+	// A leading "rp <name>;" directive overrides the retention policy for this query only.
+	if rp, q, ok := parseRetentionPolicyDirective(query); ok {
+		if c.Database != "" && !c.retentionPolicyExists(c.Database, rp) {
+			fmt.Printf("WARN: attempting query with retention policy %s anyway\n", rp)
+		}
+		defer func(rp string) { c.RetentionPolicy = rp }(c.RetentionPolicy)
+		c.RetentionPolicy = rp
+		query = q
+	}
+
+	// If we have a retention policy, we need to rewrite the statement
+	// sources, and with a range the conditions of SELECT statements.
+	if c.RetentionPolicy != "" || c.timeRange != nil {
 		pq, err := influxql.NewParser(strings.NewReader(query)).ParseQuery()
 		if err != nil {
-			fmt.Printf("ERR: %s\n", err)
+			fmt.Printf("%s %s\n", c.errPrefix(), err)
 			return err
 		}
 		for _, stmt := range pq.Statements {
-			if selectStatement, ok := stmt.(*influxql.SelectStatement); ok {
-				influxql.WalkFunc(selectStatement.Sources, func(n influxql.Node) {
-					if t, ok := n.(*influxql.Measurement); ok {
-						if t.Database == "" && c.Database != "" {
-							t.Database = c.Database
-						}
-						if t.RetentionPolicy == "" && c.RetentionPolicy != "" {
-							t.RetentionPolicy = c.RetentionPolicy
-						}
-					}
-				})
-			}
+			c.qualifySources(stmt)
+			c.applyTimeRange(stmt)
 		}
 		query = pq.String()
 	}
 
-	ctx := context.Background()
-	if !c.IgnoreSignals {
-		done := make(chan struct{})
-		defer close(done)
-
-		var cancel func()
-		ctx, cancel = context.WithCancel(ctx)
-		go func() {
-			select {
-			case <-done:
-			case <-c.osSignals:
-				cancel()
-			}
-		}()
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	var timing func() client.RequestTiming
+	if c.VerboseTiming {
+		ctx, timing = client.WithTiming(ctx)
 	}
 
+	// Results, stats and the elapsed time share one writer, and every formatter
+	// flushes before returning, so the elapsed line always follows the results.
+	w := c.output()
 	start := time.Now()
-	defer func() { fmt.Printf("\nelapsed:%s\n", time.Since(start).String()) }()
+	defer c.writeElapsed(w, start)
+	defer func() { c.logSlowQuery(query, time.Since(start)) }()
 
 	response, err := c.Client.QueryContext(ctx, c.query(query))
+	total := time.Since(start)
 	if err != nil {
-		if err.Error() == "" {
-			err = ctx.Err()
-			if err == context.Canceled {
-				err = errors.New("aborted by user")
-			} else if err == nil {
-				err = errors.New("no data received")
-			}
+		if err = c.queryContextErr(ctx, err); err.Error() == "" {
+			err = errors.New("no data received")
 		}
-		fmt.Printf("ERR: %s\n", err)
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
 		return err
 	}
-	c.FormatResponse(response, os.Stdout)
+	c.FormatResponse(response, w)
+	if c.Stats && !c.Quiet {
+		t := response.Transfer
+		fmt.Fprintf(w, "received %d bytes (%d bytes decoded, %d bytes saved by compression)\n",
+			t.WireBytes, t.DecodedBytes, t.DecodedBytes-t.WireBytes)
+	}
+	if timing != nil && !c.Quiet {
+		writeTiming(w, timing(), total)
+	}
 	if err := response.Error(); err != nil {
-		fmt.Printf("ERR: %s\n", response.Error())
+		fmt.Printf("%s %s\n", c.errPrefix(), response.Error())
 		if c.Database == "" {
 			fmt.Println("Warning: It is possible this error is due to not setting a database.")
 			fmt.Println(`Please set a database with the command "use <database>".`)
@@ -848,199 +1769,79 @@ func (c *CommandLine) ExecuteQuery(query string) error {
 	return nil
 }
 
-// FormatResponse formats output to the previously chosen format.
-func (c *CommandLine) FormatResponse(response *client.Response, w io.Writer) {
-	switch c.Format {
-	case "json":
-		c.writeJSON(response, w)
-	case "csv":
-		c.writeCSV(response, w)
-	case "column":
-		c.writeColumns(response, w)
-	default:
-		fmt.Fprintf(w, "Unknown output format %q.\n", c.Format)
-	}
-}
-
-func (c *CommandLine) writeJSON(response *client.Response, w io.Writer) {
-	var data []byte
-	var err error
-	if c.Pretty {
-		data, err = json.MarshalIndent(response, "", "    ")
-	} else {
-		data, err = json.Marshal(response)
+// qualifySources sets the current database and retention policy on the
+// sources of a SELECT statement, or of the SELECT statement being explained,
+// that do not name their own.
+func (c *CommandLine) qualifySources(stmt influxql.Statement) {
+	if explain, ok := stmt.(*influxql.ExplainStatement); ok {
+		stmt = explain.Statement
 	}
-	if err != nil {
-		fmt.Fprintf(w, "Unable to parse json: %s\n", err)
+	selectStatement, ok := stmt.(*influxql.SelectStatement)
+	if !ok {
 		return
 	}
-	fmt.Fprintln(w, string(data))
-}
-
-func tagsEqual(prev, current map[string]string) bool {
-	return reflect.DeepEqual(prev, current)
-}
-
-func columnsEqual(prev, current []string) bool {
-	return reflect.DeepEqual(prev, current)
-}
-
-func headersEqual(prev, current models.Row) bool {
-	if prev.Name != current.Name {
-		return false
-	}
-	return tagsEqual(prev.Tags, current.Tags) && columnsEqual(prev.Columns, current.Columns)
-}
-
-func (c *CommandLine) writeCSV(response *client.Response, w io.Writer) {
-	csvw := csv.NewWriter(w)
-	var previousHeaders models.Row
-	for _, result := range response.Results {
-		suppressHeaders := len(result.Series) > 0 && headersEqual(previousHeaders, result.Series[0])
-		if !suppressHeaders && len(result.Series) > 0 {
-			previousHeaders = models.Row{
-				Name:    result.Series[0].Name,
-				Tags:    result.Series[0].Tags,
-				Columns: result.Series[0].Columns,
+	influxql.WalkFunc(selectStatement.Sources, func(n influxql.Node) {
+		if t, ok := n.(*influxql.Measurement); ok {
+			if t.Database == "" && c.Database != "" {
+				t.Database = c.Database
+			}
+			if t.RetentionPolicy == "" && c.RetentionPolicy != "" {
+				t.RetentionPolicy = c.RetentionPolicy
 			}
 		}
-
-		// Create a tabbed writer for each result as they won't always line up
-		rows := c.formatResults(result, "\t", suppressHeaders)
-		for _, r := range rows {
-			csvw.Write(strings.Split(r, "\t"))
-		}
-	}
-	csvw.Flush()
+	})
 }
 
-func (c *CommandLine) writeColumns(response *client.Response, w io.Writer) {
-	// Create a tabbed writer for each result as they won't always line up
-	writer := new(tabwriter.Writer)
-	writer.Init(w, 0, 8, 1, ' ', 0)
-
-	var previousHeaders models.Row
-	for i, result := range response.Results {
-		// Print out all messages first
-		for _, m := range result.Messages {
-			fmt.Fprintf(w, "%s: %s.\n", m.Level, m.Text)
-		}
-		// Check to see if the headers are the same as the previous row.  If so, suppress them in the output
-		suppressHeaders := len(result.Series) > 0 && headersEqual(previousHeaders, result.Series[0])
-		if !suppressHeaders && len(result.Series) > 0 {
-			previousHeaders = models.Row{
-				Name:    result.Series[0].Name,
-				Tags:    result.Series[0].Tags,
-				Columns: result.Series[0].Columns,
-			}
+// FormatResponse formats output to the previously chosen format.
+//
+// The response is written to w first and then to each output file, in the
+// order they were added with the output command. A writer that fails does not
+// stop the others; its error is printed to w.
+func (c *CommandLine) FormatResponse(response *client.Response, w io.Writer) {
+	var f Formatter
+	for _, t := range c.formatTargets(w) {
+		var err error
+		if t.path == "" && c.usePager(w) {
+			err = c.formatWithPager(response, w)
+		} else {
+			opts := c.formatOptions()
+			opts.Format = t.format
+			err = f.Format(response, t.w, opts)
 		}
 
-		// If we are suppressing headers, don't output the extra line return. If we
-		// aren't suppressing headers, then we put out line returns between results
-		// (not before the first result, and not after the last result).
-		if !suppressHeaders && i > 0 {
-			fmt.Fprintln(writer, "")
+		var unknown unknownFormatError
+		if err != nil && t.path == "" && errors.As(err, &unknown) {
+			fmt.Fprintln(w, err)
+		} else if err != nil && t.path == "" {
+			fmt.Fprintf(w, "ERR: %s\n", err)
+		} else if err != nil {
+			fmt.Fprintf(w, "ERR: writing %s output to %s: %s\n", t.format, t.path, err)
 		}
-
-		rows := c.formatResults(result, "\t", suppressHeaders)
-		for _, r := range rows {
-			fmt.Fprintln(writer, r)
+	}
+	for _, o := range c.outputFiles {
+		if err := o.w.Flush(); err != nil {
+			fmt.Fprintf(w, "ERR: writing %s output to %s: %s\n", o.format, o.path, err)
 		}
-
 	}
-	writer.Flush()
 }
 
-// formatResults will behave differently if you are formatting for columns or csv
-func (c *CommandLine) formatResults(result client.Result, separator string, suppressHeaders bool) []string {
-	rows := []string{}
-	// Create a tabbed writer for each result as they won't always line up
-	for i, row := range result.Series {
-		// gather tags
-		tags := []string{}
-		for k, v := range row.Tags {
-			tags = append(tags, fmt.Sprintf("%s=%s", k, v))
-			sort.Strings(tags)
-		}
-
-		columnNames := []string{}
-
-		// Only put name/tags in a column if format is csv
-		if c.Format == "csv" {
-			if len(tags) > 0 {
-				columnNames = append([]string{"tags"}, columnNames...)
-			}
-
-			if row.Name != "" {
-				columnNames = append([]string{"name"}, columnNames...)
-			}
-		}
-
-		columnNames = append(columnNames, row.Columns...)
-
-		// Output a line separator if we have more than one set or results and format is column
-		if i > 0 && c.Format == "column" && !suppressHeaders {
-			rows = append(rows, "")
-		}
-
-		// If we are column format, we break out the name/tag to separate lines
-		if c.Format == "column" && !suppressHeaders {
-			if row.Name != "" {
-				n := fmt.Sprintf("name: %s", row.Name)
-				rows = append(rows, n)
-			}
-			if len(tags) > 0 {
-				t := fmt.Sprintf("tags: %s", (strings.Join(tags, ", ")))
-				rows = append(rows, t)
-			}
-		}
-
-		if !suppressHeaders {
-			rows = append(rows, strings.Join(columnNames, separator))
-		}
-
-		// if format is column, write dashes under each column
-		if c.Format == "column" && !suppressHeaders {
-			lines := []string{}
-			for _, columnName := range columnNames {
-				lines = append(lines, strings.Repeat("-", len(columnName)))
-			}
-			rows = append(rows, strings.Join(lines, separator))
-		}
-
-		for _, v := range row.Values {
-			var values []string
-			if c.Format == "csv" {
-				if row.Name != "" {
-					values = append(values, row.Name)
-				}
-				if len(tags) > 0 {
-					values = append(values, strings.Join(tags, ","))
-				}
-			}
-
-			for _, vv := range v {
-				values = append(values, interfaceToString(vv))
-			}
-			rows = append(rows, strings.Join(values, separator))
-		}
+// formatOptions returns the options used to format responses.
+func (c *CommandLine) formatOptions() FormatOptions {
+	return FormatOptions{
+		Format:      c.Format,
+		JSONStyle:   c.JSONStyle,
+		Precision:   c.ClientConfig.Precision,
+		Expanded:    c.Expanded,
+		TimeLayout:  c.TimeFormat,
+		NullString:  c.NullString,
+		FloatFormat: c.FloatFormat,
 	}
-	return rows
 }
 
-func interfaceToString(v interface{}) string {
-	switch t := v.(type) {
-	case nil:
-		return ""
-	case bool:
-		return fmt.Sprintf("%v", v)
-	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
-		return fmt.Sprintf("%d", t)
-	case float32, float64:
-		return fmt.Sprintf("%v", t)
-	default:
-		return fmt.Sprintf("%v", t)
-	}
+// setJSONStyle sets the json style, keeping Pretty in step with it.
+func (c *CommandLine) setJSONStyle(style JSONStyle) {
+	c.JSONStyle = style
+	c.Pretty = style == JSONStylePretty
 }
 
 // Settings prints current settings.
@@ -1050,33 +1851,246 @@ func (c *CommandLine) Settings() {
 	fmt.Fprintln(w, "Setting\tValue")
 	fmt.Fprintln(w, "--------\t--------")
 	fmt.Fprintf(w, "URL\t%s\n", c.URL.String())
+	fmt.Fprintf(w, "Language\t%s\n", c.Type.String())
+	if len(c.Hosts) > 0 {
+		fmt.Fprintf(w, "Hosts\t%s\n", strings.Join(c.redactedHosts(), ","))
+		fmt.Fprintf(w, "Active Host\t%s\n", c.activeHostName())
+	}
 	fmt.Fprintf(w, "Username\t%s\n", c.ClientConfig.Username)
+	fmt.Fprintf(w, "Token\t%s\n", maskToken(c.ClientConfig.Token))
+	fmt.Fprintf(w, "Headers\t%s\n", strings.Join(headerStrings(c.ClientConfig.Headers), ", "))
 	fmt.Fprintf(w, "Database\t%s\n", c.Database)
 	fmt.Fprintf(w, "RetentionPolicy\t%s\n", c.RetentionPolicy)
+	fmt.Fprintf(w, "Node ID\t%d\n", c.NodeID)
 	fmt.Fprintf(w, "Pretty\t%v\n", c.Pretty)
+	fmt.Fprintf(w, "JSON Style\t%s\n", c.JSONStyle)
 	fmt.Fprintf(w, "Format\t%s\n", c.Format)
+	fmt.Fprintf(w, "Expanded\t%v\n", c.Expanded)
+	fmt.Fprintf(w, "Time Format\t%s\n", c.TimeFormat)
+	fmt.Fprintf(w, "Null String\t%s\n", c.NullString)
+	fmt.Fprintf(w, "Float Format\t%s\n", c.FloatFormat)
+	fmt.Fprintf(w, "Time Range\t%s\n", c.timeRangeString())
 	fmt.Fprintf(w, "Write Consistency\t%s\n", c.ClientConfig.WriteConsistency)
-	fmt.Fprintf(w, "Chunked\t%v\n", c.Chunked)
+	fmt.Fprintf(w, "Chunked\t%v\n", c.chunked())
 	fmt.Fprintf(w, "Chunk Size\t%d\n", c.ChunkSize)
+	fmt.Fprintf(w, "Accept Gzip\t%v\n", c.ClientConfig.AcceptGzip)
+	fmt.Fprintf(w, "Gzip Writes\t%v\n", c.ClientConfig.GzipWrites)
+	fmt.Fprintf(w, "Max Response Size\t%s\n", maxResponseSize(c.ClientConfig.MaxResponseSize))
+	fmt.Fprintf(w, "Retries\t%d\n", c.ClientConfig.Retries)
+	fmt.Fprintf(w, "Query Timeout\t%s\n", c.QueryTimeout)
+	fmt.Fprintf(w, "Slow Log\t%s\n", c.SlowLog)
+	fmt.Fprintf(w, "Idle Timeout\t%s\n", c.IdleTimeout)
+	if c.Client != nil {
+		fmt.Fprintf(w, "Circuit Breaker\t%s\n", c.Client.BreakerState())
+	}
+	fmt.Fprintf(w, "Stats\t%v\n", c.Stats)
+	fmt.Fprintf(w, "Verbose Timing\t%v\n", c.VerboseTiming)
+	fmt.Fprintf(w, "Quiet\t%v\n", c.Quiet)
+	fmt.Fprintf(w, "Pager\t%v\n", c.Pager)
+	fmt.Fprintf(w, "Color\t%v\n", c.Color)
 	fmt.Fprintln(w)
 	w.Flush()
 }
 
+// settings handles "settings", printing the settings table, and
+// "settings json" (or --json), printing them as a JSON object.
+func (c *CommandLine) settings(cmd string) error {
+	switch arg := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(cmd)[len("settings"):], ";")); strings.ToLower(arg) {
+	case "":
+		c.Settings()
+		return nil
+	case "json", "--json", "-json":
+		return c.settingsJSON(c.output())
+	default:
+		fmt.Println("Usage: settings [json]")
+		return nil
+	}
+}
+
+// settingsJSON writes the current settings to w as an indented JSON object.
+// Credentials in the URL and hosts, the token and sensitive headers are
+// masked.
+func (c *CommandLine) settingsJSON(w io.Writer) error {
+	settings := struct {
+		URL              string   `json:"url"`
+		Language         string   `json:"language"`
+		Hosts            []string `json:"hosts,omitempty"`
+		ActiveHost       string   `json:"active_host,omitempty"`
+		Username         string   `json:"username"`
+		Token            string   `json:"token"`
+		Headers          []string `json:"headers,omitempty"`
+		Database         string   `json:"database"`
+		RetentionPolicy  string   `json:"retention_policy"`
+		NodeID           int      `json:"node_id"`
+		Format           string   `json:"format"`
+		Expanded         bool     `json:"expanded"`
+		Precision        string   `json:"precision"`
+		TimeFormat       string   `json:"time_format,omitempty"`
+		NullString       string   `json:"null_string,omitempty"`
+		FloatFormat      string   `json:"float_format,omitempty"`
+		TimeRange        string   `json:"time_range,omitempty"`
+		Pretty           bool     `json:"pretty"`
+		JSONStyle        string   `json:"json_style"`
+		WriteConsistency string   `json:"write_consistency"`
+		Chunked          bool     `json:"chunked"`
+		ChunkSize        int      `json:"chunk_size"`
+		AcceptGzip       bool     `json:"accept_gzip"`
+		GzipWrites       bool     `json:"gzip_writes"`
+		MaxResponseSize  int64    `json:"max_response_size"`
+		Retries          int      `json:"retries"`
+		CircuitBreaker   string   `json:"circuit_breaker,omitempty"`
+		Stats            bool     `json:"stats"`
+		VerboseTiming    bool     `json:"verbose_timing"`
+		Quiet            bool     `json:"quiet"`
+		Pager            bool     `json:"pager"`
+		Color            bool     `json:"color"`
+		Prompt           string   `json:"prompt"`
+		Tee              string   `json:"tee,omitempty"`
+		KeepAlive        string   `json:"keepalive,omitempty"`
+		QueryTimeout     string   `json:"query_timeout,omitempty"`
+		SlowLog          string   `json:"slowlog,omitempty"`
+		IdleTimeout      string   `json:"idle_timeout,omitempty"`
+		DiffHost         string   `json:"diff_host,omitempty"`
+		ServerVersion    string   `json:"server_version"`
+		ClientVersion    string   `json:"client_version"`
+	}{
+		URL:              c.URL.Redacted(),
+		Language:         c.Type.String(),
+		Hosts:            c.redactedHosts(),
+		ActiveHost:       c.activeHostName(),
+		Username:         c.ClientConfig.Username,
+		Token:            maskToken(c.ClientConfig.Token),
+		Headers:          headerStrings(c.ClientConfig.Headers),
+		Database:         c.Database,
+		RetentionPolicy:  c.RetentionPolicy,
+		NodeID:           c.NodeID,
+		Format:           c.Format,
+		Expanded:         c.Expanded,
+		Precision:        c.ClientConfig.Precision,
+		TimeFormat:       c.TimeFormat,
+		NullString:       c.NullString,
+		FloatFormat:      c.FloatFormat,
+		TimeRange:        c.timeRangeString(),
+		Pretty:           c.Pretty,
+		JSONStyle:        c.JSONStyle.String(),
+		WriteConsistency: c.ClientConfig.WriteConsistency,
+		Chunked:          c.chunked(),
+		ChunkSize:        c.ChunkSize,
+		AcceptGzip:       c.ClientConfig.AcceptGzip,
+		GzipWrites:       c.ClientConfig.GzipWrites,
+		MaxResponseSize:  c.ClientConfig.MaxResponseSize,
+		Retries:          c.ClientConfig.Retries,
+		Stats:            c.Stats,
+		VerboseTiming:    c.VerboseTiming,
+		Quiet:            c.Quiet,
+		Pager:            c.Pager,
+		Color:            c.Color,
+		Prompt:           c.Prompt,
+		DiffHost:         c.DiffHost,
+		ServerVersion:    c.ServerVersion,
+		ClientVersion:    c.ClientVersion,
+	}
+	if settings.Prompt == "" {
+		settings.Prompt = DefaultPrompt
+	}
+	if c.Client != nil {
+		settings.CircuitBreaker = c.Client.BreakerState()
+	}
+	if c.tee != nil {
+		settings.Tee = c.tee.path
+	}
+	if c.KeepAlive > 0 {
+		settings.KeepAlive = c.KeepAlive.String()
+	}
+	if c.QueryTimeout > 0 {
+		settings.QueryTimeout = c.QueryTimeout.String()
+	}
+	if c.SlowLog > 0 {
+		settings.SlowLog = c.SlowLog.String()
+	}
+	if c.IdleTimeout > 0 {
+		settings.IdleTimeout = c.IdleTimeout.String()
+	}
+
+	b, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
 func (c *CommandLine) help() {
 	fmt.Println(`Usage:
         connect <host:port>   connects to another node specified by host:port
         auth                  prompts for username and password
+        token <token>         sets the token sent in the Authorization header; takes precedence over auth
+        header set <k> <v>    sends header k with value v with every request. 'header clear [k]' removes one or
+                              all of them and 'header' lists them
         pretty                toggles pretty print for the json format
+        compact               toggles compact output for the json format
         chunked               turns on chunked responses from server
         chunk size <size>     sets the size of the chunked responses.  Set to 0 to reset to the default chunked size
+        stats                 toggles printing of response size statistics after each query
+        stats json            prints SHOW STATS and SHOW DIAGNOSTICS as a JSON object keyed by component
+        quiet                 toggles printing only query results, without the elapsed time or stats
         use <db_name>         sets current database
-        format <format>       specifies the format of the server responses: json, csv, or column
+        node <id> [verify]    sets the node to query, optionally checking it against SHOW SHARDS. 'node clear' resets it
+        rp <rp_name>; <query> runs a single query using the given retention policy
+        format <format>       specifies the format of the server responses: json, ndjson, csv, column, markdown, or promql-style
         precision <format>    specifies the format of the timestamp: rfc3339, h, m, s, ms, u or ns
+        language <name>       switches the language of the lines entered to flux or influxql; in flux only exit,
+                              quit, help, language and settings remain commands
+        timefmt <layout>      prints times in a Go layout such as 2006-01-02 15:04:05 in the column and csv formats. 'timefmt clear' resets it
+        range <start> <end>   adds time >= start AND time < end to SELECT statements without a time condition, e.g.
+                              range -1h now or range 2024-01-01 2024-01-02. 'range clear' removes it
+        floatfmt <verb|n>     prints floats with a fmt verb, or with n digits after the point, in the column, csv and
+                              markdown formats. 'floatfmt clear' resets it
+        null-string <token>   prints null values as token, e.g. \N, in the column and csv formats; json keeps null. 'null-string clear' resets it
         consistency <level>   sets write consistency level: any, one, quorum, or all
+        prompt <template>     sets the prompt; {db}, {rp}, {host} and {fmt} are replaced by the current settings
+        pager [on|off]        pipes output through $PAGER (or less -FRX) when connected to a terminal
         history               displays command history
-        settings              outputs the current settings for the shell
-        clear                 clears settings such as database or retention policy.  run 'clear' for help
+        history search <str>  displays the history entries containing str, with their indices
+        history run <index>   runs the history entry with the given index
+        edit                  opens the last query in $EDITOR and runs it when the editor exits successfully
+        arrow <path> <query>  runs a query and writes the result to an Arrow IPC (Feather) file
+        fieldtypes <name>     lists the field keys of a measurement grouped by field type
+        count <name>          prints the number of points in a measurement
+                              fieldtypes and count accept glob patterns such as cpu*
+        cardinality [exact] [name]
+                              prints the estimated series cardinality of the database or a measurement, and with exact
+                              also the exact one, which can be expensive on large databases
+        cleanup [-force] <name> where <predicate>
+                              previews the series and points of a measurement matching a predicate and, once
+                              confirmed, drops the series, or deletes the points for a time condition
+        for-each-db <pattern> <query>
+                              runs a query against every database matching a glob pattern
+        explain [analyze] <query>
+                              shows the query plan of a SELECT statement, and its execution statistics with analyze
+        schema <query>        prints the series and inferred column types of a query result as JSON
+        repeat <n> <query>    runs a query n times without printing rows and prints its latency statistics and errors
+        sparkline <query>     draws a sparkline with the minimum and maximum of each series of a single-field SELECT
+        export by <tag> <dir> <query>
+                              runs a query and writes the rows of each tag value to <dir>/<value>.csv
+        export db <name> <path> [-rp <rp>] [-since <time>] [-until <time>] [-window <duration>]
+                              writes a database as line protocol for influx -import, gzipped if path ends in .gz
+        decompress <in.gz> <out>
+                              writes the contents of a gzip file to out, checking it and counting its lines
+        diff <query>          runs a query against this server and -diff-host and prints the rows that differ
+        output <format> <path>
+                              also writes query results to a file in another format; 'output off' closes the files.
+                              A path of s3://bucket/key uploads the results to S3 when the output is closed
+        tee <path>|off        copies query output to a file while still printing it, 'tee off' stops
+        timeout <duration>    cancels queries running longer than the duration, e.g. 30s. 'timeout off' removes the limit
+        timing verbose        prints the dns, connect, tls, server wait and total time of each InfluxQL query. 'timing off' stops
+        slowlog <duration>    prints a SLOW: warning with the query to stderr when a query takes longer, e.g. 500ms. 'slowlog off' stops
+        keepalive <interval>  pings the server at the interval, e.g. 30s, reconnecting if a ping fails. 'keepalive off' stops
+        status                prints the server version, uptime, number of databases and whether it is reachable
+        settings [json]       outputs the current settings for the shell, as a JSON object with 'settings json'
+        clear                 clears settings such as database or retention policy, or all of them with 'clear all'.  run 'clear' for help
         exit/quit/ctrl+d      quits the influx shell
+        \d, \dt, \c, \x, \q  psql-style metacommands, run \? for the list
 
         show databases        show database names
         show series           show series information
@@ -1085,13 +2099,76 @@ func (c *CommandLine) help() {
         show field keys       show field key information
 
         A full list of influxql commands can be found at:
-        https://docs.influxdata.com/influxdb/latest/query_language/spec/`)
+        https://docs.influxdata.com/influxdb/latest/query_language/spec/
+
+        Exit codes when running non-interactively:
+        0                     success
+        1                     unclassified error
+        2                     unable to connect to the server
+        3                     authentication or authorization error
+        4                     query or write error
+        5                     statement parse error
+        6                     import error`)
 }
 
-func (c *CommandLine) history() {
+// history prints the command history. "history search <substr>" prints the
+// matching entries with their indices and "history run <index>" runs one.
+func (c *CommandLine) history(cmd string) error {
+	args := strings.Fields(strings.TrimSuffix(strings.TrimSpace(cmd), ";"))
+	if len(args) == 1 {
+		var buf bytes.Buffer
+		c.Line.WriteHistory(&buf)
+		fmt.Print(buf.String())
+		return nil
+	}
+
+	lines := c.historyLines()
+	switch strings.ToLower(args[1]) {
+	case "search":
+		if len(args) < 3 {
+			fmt.Println("Usage: history search <substr>")
+			return nil
+		}
+		substr := strings.Join(args[2:], " ")
+		for i, line := range lines {
+			if strings.Contains(line, substr) {
+				fmt.Printf("%d\t%s\n", i+1, line)
+			}
+		}
+	case "run":
+		if len(args) != 3 {
+			fmt.Println("Usage: history run <index>")
+			return nil
+		}
+		i, err := strconv.Atoi(args[2])
+		if err != nil || i < 1 || i > len(lines) {
+			fmt.Printf("Invalid history index %q. Use 'history search' to find one.\n", args[2])
+			return nil
+		}
+		line := lines[i-1]
+		if strings.EqualFold(strings.Fields(line)[0], "history") {
+			fmt.Println("Refusing to run a history command from the history.")
+			return nil
+		}
+		fmt.Println(line)
+		return c.ParseCommand(line)
+	default:
+		fmt.Printf("Unknown history command %q. Please use search or run.\n", args[1])
+	}
+	return nil
+}
+
+// historyLines returns the entries of the in-memory history, oldest first.
+func (c *CommandLine) historyLines() []string {
 	var buf bytes.Buffer
 	c.Line.WriteHistory(&buf)
-	fmt.Print(buf.String())
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
 }
 
 func (c *CommandLine) saveHistory() {
@@ -1167,6 +2244,14 @@ func (c *CommandLine) Version() {
 }
 
 func (c *CommandLine) exit() {
+	c.stopKeepAlive()
+	// flush and close the tee file
+	if err := c.closeTee(); err != nil {
+		fmt.Printf("closing tee file: %s\n", err)
+	}
+	if err := c.closeOutputFiles(); err != nil {
+		fmt.Println(err)
+	}
 	// write to history file
 	c.saveHistory()
 	// release line resources
@@ -1175,28 +2260,69 @@ func (c *CommandLine) exit() {
 }
 
 func (c *CommandLine) ExecuteFluxQuery(query string) error {
-	ctx := context.Background()
-	if !c.IgnoreSignals {
-		done := make(chan struct{})
-		defer close(done)
+	return c.ExecuteFluxQueryContext(context.Background(), query)
+}
+
+// ExecuteFluxQueryContext runs a flux query and can be canceled through ctx.
+// Like ExecuteQueryContext, an interrupt, unless signals are ignored, and the
+// query timeout also cancel the query, even while results are streaming.
+func (c *CommandLine) ExecuteFluxQueryContext(ctx context.Context, query string) error {
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+
+	repl, err := getFluxREPL(ctx, c.URL, c.ClientConfig.Username, c.ClientConfig.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := repl.Input(query); err != nil {
+		return c.queryContextErr(ctx, err)
+	}
+	return nil
+}
 
-		var cancel func()
-		ctx, cancel = context.WithCancel(ctx)
+// signalContext returns a context derived from ctx that is canceled when the
+// returned function is called or, unless signals are ignored, when the process
+// is interrupted. A nil ctx is treated as context.Background().
+func (c *CommandLine) signalContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	if !c.IgnoreSignals {
 		go func() {
 			select {
-			case <-done:
+			case <-ctx.Done():
 			case <-c.osSignals:
 				cancel()
 			}
 		}()
 	}
+	return ctx, cancel
+}
 
-	repl, err := getFluxREPL(c.URL, c.ClientConfig.Username, c.ClientConfig.Password)
-	if err != nil {
-		return err
-	}
+// JSONStyle controls how the json output format is rendered.
+type JSONStyle uint8
+
+const (
+	// JSONStyleNormal renders json without indentation.
+	JSONStyleNormal JSONStyle = iota
+	// JSONStylePretty renders indented json.
+	JSONStylePretty
+	// JSONStyleCompact renders json without indentation or HTML escaping.
+	JSONStyleCompact
+)
 
-	return repl.Input(query)
+func (s JSONStyle) String() string {
+	switch s {
+	case JSONStyleNormal:
+		return "normal"
+	case JSONStylePretty:
+		return "pretty"
+	case JSONStyleCompact:
+		return "compact"
+	}
+	return fmt.Sprintf("JSONStyle(%d)", uint8(s))
 }
 
 type QueryLanguage uint8
@@ -3,15 +3,22 @@ package cli_test
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/influxdata/influxdb/client"
 	"github.com/influxdata/influxdb/cmd/influx/cli"
@@ -56,6 +63,79 @@ func TestRunCLI_ExecuteInsert(t *testing.T) {
 	}
 }
 
+func TestRunCLI_ConnectRetries(t *testing.T) {
+	t.Parallel()
+	// Reserve an address and start the server on it only after the first
+	// attempts to connect have failed.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ts := emptyTestServer()
+	defer ts.Close()
+	started := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			started <- nil
+			return
+		}
+		started <- l
+		http.Serve(l, ts.Config.Handler)
+	}()
+
+	h, p, _ := net.SplitHostPort(addr)
+	c := cli.New(CLIENT_VERSION)
+	c.Host = h
+	c.Port, _ = strconv.Atoi(p)
+	c.ConnectRetries = 100
+	c.ConnectInterval = 10 * time.Millisecond
+	c.ClientConfig.Precision = "ms"
+	c.Execute = "INSERT sensor,floor=1 value=2"
+	c.IgnoreSignals = true
+	c.ForceTTY = true
+	err = c.Run()
+	if l := <-started; l == nil {
+		t.Skip("could not listen on the reserved address again")
+	} else {
+		defer l.Close()
+	}
+	if err != nil {
+		t.Fatalf("Run failed with error: %s", err)
+	}
+}
+
+func TestRunCLI_ConnectErrorRedactsCredentials(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, p, _ := net.SplitHostPort(l.Addr().String())
+	l.Close()
+
+	c := cli.New(CLIENT_VERSION)
+	c.Host = "admin:s3cr3t@127.0.0.1"
+	c.Port, _ = strconv.Atoi(p)
+	c.Execute = "SHOW DATABASES"
+	c.IgnoreSignals = true
+	c.ForceTTY = true
+	err = c.Run()
+	if err == nil {
+		t.Fatal("expected a connect error")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Fatalf("connect error reveals the password: %s", err)
+	}
+	if !strings.Contains(err.Error(), "Failed to connect to http://127.0.0.1:"+p) {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
 func TestRunCLI_ExecuteInsertWithPath(t *testing.T) {
 	path := "boom"
 	t.Parallel()
@@ -96,6 +176,116 @@ func TestRunCLI_ExecuteInsert_WithSignals(t *testing.T) {
 	}
 }
 
+func TestRunCLI_ExitCodes(t *testing.T) {
+	t.Parallel()
+	ts := emptyTestServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	h, p, _ := net.SplitHostPort(u.Host)
+
+	tests := []struct {
+		execute string
+		code    int
+	}{
+		{execute: "INSERT sensor,floor=1 value=2", code: cli.ExitSuccess},
+		{execute: "SHOW DATABASES", code: cli.ExitSuccess},
+		{execute: "rp autogen; SELECT FROM", code: cli.ExitParseError},
+	}
+
+	for _, tt := range tests {
+		c := cli.New(CLIENT_VERSION)
+		c.Host = h
+		c.Port, _ = strconv.Atoi(p)
+		c.Execute = tt.execute
+		c.IgnoreSignals = true
+		c.ForceTTY = true
+		if got := cli.ExitCode(c.Run()); got != tt.code {
+			t.Errorf("%q: unexpected exit code: got %d, exp %d", tt.execute, got, tt.code)
+		}
+	}
+
+	// With -continue-on-error the later statements still run but the exit
+	// code reports the failure.
+	c := cli.New(CLIENT_VERSION)
+	c.Host = h
+	c.Port, _ = strconv.Atoi(p)
+	c.Execute = "rp autogen; SELECT FROM\nformat json"
+	c.ContinueOnError = true
+	c.IgnoreSignals = true
+	c.ForceTTY = true
+	if got, exp := cli.ExitCode(c.Run()), cli.ExitParseError; got != exp {
+		t.Errorf("unexpected exit code: got %d, exp %d", got, exp)
+	}
+	if c.Format != "json" {
+		t.Errorf("statement after the failure was not run: format is %q", c.Format)
+	}
+
+	// Nothing is listening on the closed server.
+	closed := emptyTestServer()
+	cu, _ := url.Parse(closed.URL)
+	closed.Close()
+	ch, cp, _ := net.SplitHostPort(cu.Host)
+	c = cli.New(CLIENT_VERSION)
+	c.Host = ch
+	c.Port, _ = strconv.Atoi(cp)
+	c.Execute = "SHOW DATABASES"
+	c.IgnoreSignals = true
+	c.ForceTTY = true
+	if got, exp := cli.ExitCode(c.Run()), cli.ExitConnectError; got != exp {
+		t.Errorf("unexpected exit code: got %d, exp %d", got, exp)
+	}
+}
+
+func TestRunCLI_ExecuteEmpty(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		execute string
+		typ     cli.QueryLanguage
+	}{
+		{execute: ""},
+		{execute: "  \n\t "},
+		{execute: "-- nothing to see"},
+		{execute: "-- first\n  -- second\n;"},
+		{execute: "// flux comment\n", typ: cli.QueryLanguageFlux},
+	} {
+		c := cli.New(CLIENT_VERSION)
+		c.Execute = tt.execute
+		c.ExecuteSet = true
+		c.Type = tt.typ
+		c.IgnoreSignals = true
+		c.ForceTTY = true
+		err := c.Run()
+		if got, exp := cli.ExitCode(err), cli.ExitError; got != exp {
+			t.Errorf("%q: unexpected exit code: got %d, exp %d", tt.execute, got, exp)
+		}
+		if err == nil || !strings.Contains(err.Error(), "no statement provided") {
+			t.Errorf("%q: unexpected error: %v", tt.execute, err)
+		}
+	}
+}
+
+func TestExecuteQueryContext_Canceled(t *testing.T) {
+	t.Parallel()
+	ts := emptyTestServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	cl, err := client.NewClient(client.Config{URL: *u})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	c := cli.New(CLIENT_VERSION)
+	c.Client = cl
+	c.IgnoreSignals = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.ExecuteQueryContext(ctx, "SHOW DATABASES"); err == nil {
+		t.Fatal("expected an error from a canceled query")
+	}
+}
+
 func TestSetAuth(t *testing.T) {
 	t.Parallel()
 	c := cli.New(CLIENT_VERSION)
@@ -335,6 +525,96 @@ func TestParseCommand_Connect(t *testing.T) {
 	}
 }
 
+func TestConnect_DatabaseFromURL(t *testing.T) {
+	t.Parallel()
+	ts := emptyTestServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	tests := []struct {
+		addr, database, wantDatabase, wantPath string
+	}{
+		{addr: "http://" + u.Host + "/db", wantDatabase: "db", wantPath: ""},
+		{addr: u.Host + "/db/", wantDatabase: "db", wantPath: ""},
+		{addr: u.Host + "/db", database: "other", wantDatabase: "other", wantPath: ""},
+		{addr: u.Host + "/proxy", wantDatabase: "", wantPath: "proxy"},
+	}
+	for _, tt := range tests {
+		c := cli.CommandLine{Database: tt.database}
+		if err := c.Connect(tt.addr); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.addr, err)
+		}
+		if c.Database != tt.wantDatabase {
+			t.Errorf("%s: database is %q, want %q", tt.addr, c.Database, tt.wantDatabase)
+		}
+		if c.URL.Path != tt.wantPath {
+			t.Errorf("%s: path is %q, want %q", tt.addr, c.URL.Path, tt.wantPath)
+		}
+	}
+}
+
+func TestConnect_DatabaseFromURL_InitialOnly(t *testing.T) {
+	t.Parallel()
+	ts := emptyTestServer()
+	defer ts.Close()
+
+	// Connecting again, as reconnecting does, keeps the path as it is.
+	u, _ := url.Parse(ts.URL)
+	c := cli.CommandLine{}
+	if err := c.Connect(u.Host + "/proxy"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := c.Connect(u.Host + "/db"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Database != "" || c.URL.Path != "db" {
+		t.Fatalf("got database %q and path %q, want no database and path db", c.Database, c.URL.Path)
+	}
+}
+
+func TestParseCommand_ConnectCredentials(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var gotUser, gotPass string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		gotUser, gotPass, _ = r.BasicAuth()
+		mu.Unlock()
+		w.Header().Set("X-Influxdb-Version", SERVER_VERSION)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	_, port, _ := net.SplitHostPort(u.Host)
+
+	tests := []struct {
+		cmd, user, pass, host string
+	}{
+		{cmd: "connect Admin:P@ss@localhost:" + port, user: "Admin", pass: "P@ss", host: "localhost:" + port},
+		{cmd: "CONNECT Admin:Pa:Ss/%w0rd@LocalHost:" + port, user: "Admin", pass: "Pa:Ss/%w0rd", host: "LocalHost:" + port},
+		{cmd: "connect u%40corp:S3cr%21t@localhost:" + port, user: "u@corp", pass: "S3cr!t", host: "localhost:" + port},
+	}
+	for _, tt := range tests {
+		c := cli.CommandLine{}
+		if err := c.ParseCommand(tt.cmd); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.cmd, err)
+		}
+		mu.Lock()
+		user, pass := gotUser, gotPass
+		mu.Unlock()
+		if user != tt.user || pass != tt.pass {
+			t.Errorf("%s: server got credentials %q/%q, expected %q/%q", tt.cmd, user, pass, tt.user, tt.pass)
+		}
+		if c.ClientConfig.Username != tt.user || c.ClientConfig.Password != tt.pass {
+			t.Errorf("%s: config has credentials %q/%q, expected %q/%q", tt.cmd, c.ClientConfig.Username, c.ClientConfig.Password, tt.user, tt.pass)
+		}
+		if c.URL.Host != tt.host {
+			t.Errorf("%s: got host %q, expected %q", tt.cmd, c.URL.Host, tt.host)
+		}
+	}
+}
+
 func TestParseCommand_TogglePretty(t *testing.T) {
 	t.Parallel()
 	c := cli.CommandLine{}
@@ -351,6 +631,74 @@ func TestParseCommand_TogglePretty(t *testing.T) {
 	}
 }
 
+func TestParseCommand_ToggleCompact(t *testing.T) {
+	t.Parallel()
+	c := cli.CommandLine{}
+	if c.JSONStyle != cli.JSONStyleNormal {
+		t.Fatalf(`JSONStyle should be normal.`)
+	}
+	c.ParseCommand("compact")
+	if c.JSONStyle != cli.JSONStyleCompact {
+		t.Fatalf(`JSONStyle should be compact.`)
+	}
+	c.ParseCommand("compact")
+	if c.JSONStyle != cli.JSONStyleNormal {
+		t.Fatalf(`JSONStyle should be normal.`)
+	}
+
+	// Pretty print is one of the styles, so compact replaces it.
+	c.ParseCommand("pretty")
+	if c.JSONStyle != cli.JSONStylePretty || !c.Pretty {
+		t.Fatalf(`JSONStyle should be pretty.`)
+	}
+	c.ParseCommand("compact")
+	if c.JSONStyle != cli.JSONStyleCompact || c.Pretty {
+		t.Fatalf(`JSONStyle should be compact and Pretty false.`)
+	}
+}
+
+func TestParseCommand_Pager(t *testing.T) {
+	t.Parallel()
+	c := cli.CommandLine{}
+	for _, tt := range []struct {
+		cmd string
+		exp bool
+	}{
+		{cmd: "pager on", exp: true},
+		{cmd: "pager", exp: false},
+		{cmd: "pager", exp: true},
+		{cmd: "PAGER OFF;", exp: false},
+		{cmd: "pager maybe", exp: false},
+	} {
+		if err := c.ParseCommand(tt.cmd); err != nil {
+			t.Fatalf(`Got error %v for command %q, expected nil.`, err, tt.cmd)
+		}
+		if c.Pager != tt.exp {
+			t.Fatalf(`Command %q set pager to %v. Expected %v`, tt.cmd, c.Pager, tt.exp)
+		}
+	}
+}
+
+func TestParseCommand_Token(t *testing.T) {
+	t.Parallel()
+	ts := emptyTestServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	config := client.Config{URL: *u}
+	cl, err := client.NewClient(config)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	c := cli.CommandLine{Client: cl}
+	if err := c.ParseCommand("token s3cret"); err != nil {
+		t.Fatalf(`Got error %v for command "token s3cret", expected nil.`, err)
+	}
+	if got, exp := c.ClientConfig.Token, "s3cret"; got != exp {
+		t.Fatalf("unexpected token: %s != %s", exp, got)
+	}
+}
+
 func TestParseCommand_Exit(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -408,6 +756,7 @@ func TestParseCommand_Use(t *testing.T) {
 	tests := []struct {
 		cmd string
 		db  string
+		rp  string
 	}{
 		{cmd: "use db", db: "db"},
 		{cmd: " use db", db: "db"},
@@ -417,6 +766,12 @@ func TestParseCommand_Use(t *testing.T) {
 		{cmd: "Use db", db: "db"},
 		{cmd: `Use "db"`, db: "db"},
 		{cmd: `Use "db db"`, db: "db db"},
+		{cmd: "use db.autogen", db: "db", rp: "autogen"},
+		{cmd: `use "db"."autogen"`, db: "db", rp: "autogen"},
+		{cmd: `use "db db"."one week"`, db: "db db", rp: "one week"},
+		{cmd: "use db db"},
+		{cmd: `use "db`},
+		{cmd: "use .autogen"},
 	}
 
 	for _, test := range tests {
@@ -428,6 +783,85 @@ func TestParseCommand_Use(t *testing.T) {
 		if m.Database != test.db {
 			t.Fatalf(`Command "%s" changed database to %q. Expected %s`, test.cmd, m.Database, test.db)
 		}
+		if m.RetentionPolicy != test.rp {
+			t.Fatalf(`Command "%s" changed retention policy to %q. Expected %s`, test.cmd, m.RetentionPolicy, test.rp)
+		}
+	}
+}
+
+func TestParseCommand_MetaCommands(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		mu.Lock()
+		queries = append(queries, q)
+		mu.Unlock()
+		if q == "SHOW DATABASES" {
+			io.WriteString(w, `{"results":[{"series":[{"name":"databases","columns":["name"],"values":[["db"]]}]}]}`)
+			return
+		}
+		io.WriteString(w, `{"results":[{}]}`)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	m := cli.CommandLine{Client: c, IgnoreSignals: true, Quit: make(chan struct{})}
+
+	for _, cmd := range []string{`\c db`, `\d`, `\dt`, `\dt cpu`, `\x`, `\nope`} {
+		if err := m.ParseCommand(cmd); err != nil {
+			t.Fatalf("%s: unexpected error: %s", cmd, err)
+		}
+	}
+	if m.Database != "db" {
+		t.Errorf("unexpected database: %q", m.Database)
+	}
+	if !m.Expanded {
+		t.Error("expected \\x to turn on expanded output")
+	}
+	exp := []string{"SHOW DATABASES", "SHOW MEASUREMENTS", "SHOW TAG KEYS", "SHOW TAG KEYS FROM cpu"}
+	mu.Lock()
+	if !reflect.DeepEqual(queries, exp) {
+		t.Errorf("unexpected queries: got %q, exp %q", queries, exp)
+	}
+	mu.Unlock()
+
+	if err := m.ParseCommand(`\q`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	select {
+	case <-m.Quit:
+	default:
+		t.Error("expected \\q to quit")
+	}
+}
+
+func TestParseCommand_TimeFmt(t *testing.T) {
+	t.Parallel()
+	m := cli.CommandLine{}
+
+	if err := m.ParseCommand("timefmt 2006-01-02 15:04:05"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if exp := "2006-01-02 15:04:05"; m.TimeFormat != exp {
+		t.Fatalf("unexpected time format: got %q, exp %q", m.TimeFormat, exp)
+	}
+	if err := m.ParseCommand("timefmt yyyy-mm-dd"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if exp := "2006-01-02 15:04:05"; m.TimeFormat != exp {
+		t.Fatalf("an invalid layout replaced the time format: got %q", m.TimeFormat)
+	}
+	if err := m.ParseCommand("timefmt clear"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if m.TimeFormat != "" {
+		t.Fatalf("unexpected time format after clear: %q", m.TimeFormat)
 	}
 }
 
@@ -485,6 +919,322 @@ func TestParseCommand_UseAuth(t *testing.T) {
 	}
 }
 
+func TestParseCommand_UseSkipDBCheck(t *testing.T) {
+	t.Parallel()
+	ts := emptyTestServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	// The server refuses SHOW DATABASES for this user.
+	config := client.Config{URL: *u, Username: "anonymous"}
+	c, err := client.NewClient(config)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	for _, tt := range []struct {
+		skip bool
+		db   string
+	}{
+		{skip: false, db: ""},
+		{skip: true, db: "blank"},
+	} {
+		m := cli.CommandLine{Client: c, SkipDBCheck: tt.skip}
+		if err := m.ParseCommand("use blank"); err != nil {
+			t.Fatalf(`Got error %v for command "use blank", expected nil.`, err)
+		}
+		if m.Database != tt.db {
+			t.Fatalf(`Command "use" with skip %v changed database to %q. Expected %q`, tt.skip, m.Database, tt.db)
+		}
+	}
+}
+
+func TestParseCommand_ClearAll(t *testing.T) {
+	t.Parallel()
+	c := cli.CommandLine{
+		Database:        "db",
+		RetentionPolicy: "rp",
+		NodeID:          3,
+		Format:          "json",
+	}
+	if err := c.ParseCommand("clear all"); err != nil {
+		t.Fatalf(`Got error %v for command "clear all", expected nil.`, err)
+	}
+	if c.Database != "" || c.RetentionPolicy != "" || c.NodeID != 0 {
+		t.Fatalf("unexpected settings after clear all: database=%q rp=%q node=%d", c.Database, c.RetentionPolicy, c.NodeID)
+	}
+}
+
+func TestParseCommand_Tee(t *testing.T) {
+	t.Parallel()
+	ts := emptyTestServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	config := client.Config{URL: *u}
+	c, err := client.NewClient(config)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	m := cli.CommandLine{Client: c, Format: "csv"}
+
+	path := filepath.Join(t.TempDir(), "out.csv")
+	if err := m.ParseCommand("tee " + path); err != nil {
+		t.Fatalf(`Got error %v for command "tee", expected nil.`, err)
+	}
+	if err := m.ParseCommand("show databases"); err != nil {
+		t.Fatalf(`Got error %v for command "show databases", expected nil.`, err)
+	}
+	if err := m.ParseCommand("tee off"); err != nil {
+		t.Fatalf(`Got error %v for command "tee off", expected nil.`, err)
+	}
+	if err := m.ParseCommand("show databases"); err != nil {
+		t.Fatalf(`Got error %v for command "show databases", expected nil.`, err)
+	}
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, exp := string(buf), "name,name\ndatabases,db,db db\n\nelapsed:"
+	if !strings.HasPrefix(got, exp) || strings.Count(got, "elapsed:") != 1 {
+		t.Fatalf("unexpected tee output:\ngot:\n%s\nexp:\n%s", got, exp)
+	}
+}
+
+func TestParseCommand_ForEachDB(t *testing.T) {
+	t.Parallel()
+	var queried []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Influxdb-Version", SERVER_VERSION)
+		values := r.URL.Query()
+		if values.Get("q") == "SHOW DATABASES" {
+			io.WriteString(w, `{"results":[{"series":[{"name":"databases","columns":["name"],"values":[["db0"],["db1"],["other"]]}]}]}`)
+			return
+		}
+		queried = append(queried, values.Get("db"))
+		if values.Get("db") == "db1" {
+			io.WriteString(w, `{"results":[{"error":"boom"}]}`)
+			return
+		}
+		io.WriteString(w, `{"results":[{}]}`)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	m := cli.CommandLine{Client: c, Database: "mydb", IgnoreSignals: true}
+
+	err = m.ParseCommand("for-each-db db* SELECT * FROM cpu")
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 databases") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exp := []string{"db0", "db1"}; !reflect.DeepEqual(queried, exp) {
+		t.Fatalf("unexpected databases queried: got %v, expected %v", queried, exp)
+	}
+	if m.Database != "mydb" {
+		t.Fatalf("database not restored: %q", m.Database)
+	}
+}
+
+func TestParseCommand_Explain(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.Query().Get("q"))
+		mu.Unlock()
+		io.WriteString(w, `{"results":[{"series":[{"columns":["QUERY PLAN"],"values":[["EXPRESSION: <nil>"],["NUMBER OF SHARDS: 1"]]}]}]}`)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	m := cli.CommandLine{Client: c, Database: "db0", RetentionPolicy: "rp0", IgnoreSignals: true}
+
+	for _, cmd := range []string{
+		"explain SELECT value FROM cpu",
+		"EXPLAIN ANALYZE select value from cpu",
+		"explain SHOW DATABASES",
+	} {
+		if err := m.ParseCommand(cmd); err != nil {
+			t.Fatalf("%s: unexpected error: %s", cmd, err)
+		}
+	}
+
+	exp := []string{
+		"EXPLAIN SELECT value FROM db0.rp0.cpu",
+		"EXPLAIN ANALYZE SELECT value FROM db0.rp0.cpu",
+	}
+	if !reflect.DeepEqual(queries, exp) {
+		t.Fatalf("unexpected queries:\ngot: %q\nexp: %q", queries, exp)
+	}
+}
+
+func TestParseCommand_ExportBy(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "true" {
+			t.Error("expected a chunked query")
+		}
+		io.WriteString(w, `{"results":[{"series":[{"name":"cpu","tags":{"host":"a"},"columns":["time","value"],"values":[[1,1],[2,2]]}],"partial":true}]}`+"\n")
+		io.WriteString(w, `{"results":[{"series":[{"name":"cpu","tags":{"host":"a"},"columns":["time","value"],"values":[[3,3]]}],"partial":true}]}`+"\n")
+		io.WriteString(w, `{"results":[{"series":[{"name":"cpu","tags":{"host":"b/../c"},"columns":["time","value"],"values":[[1,4]]}]}]}`+"\n")
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	m := cli.CommandLine{Client: c, IgnoreSignals: true}
+
+	dir := t.TempDir()
+	if err := m.ParseCommand("export by host " + dir + " SELECT value FROM cpu GROUP BY host"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for name, exp := range map[string]string{
+		"a.csv":      "name,host,time,value\ncpu,a,1,1\ncpu,a,2,2\ncpu,a,3,3\n",
+		"b_.._c.csv": "name,host,time,value\ncpu,b/../c,1,4\n",
+	} {
+		buf, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf); got != exp {
+			t.Errorf("%s: unexpected contents:\ngot:\n%s\nexp:\n%s", name, got, exp)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("unexpected number of files: %d", len(entries))
+	}
+}
+
+func TestParseCommand_ExportDB(t *testing.T) {
+	t.Parallel()
+	const hour = int64(time.Hour)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("epoch") != "ns" {
+			t.Errorf("unexpected epoch: %q", r.URL.Query().Get("epoch"))
+		}
+		q := r.URL.Query().Get("q")
+		var lo, hi int64
+		switch {
+		case q == "SHOW RETENTION POLICIES ON mydb":
+			io.WriteString(w, `{"results":[{"series":[{"columns":["name","duration","default"],"values":[["old","1h0m0s",false],["autogen","0s",true]]}]}]}`)
+		case q == "SHOW MEASUREMENTS ON mydb":
+			io.WriteString(w, `{"results":[{"series":[{"name":"measurements","columns":["name"],"values":[["cpu"]]}]}]}`)
+		case q == `SHOW FIELD KEYS ON mydb FROM "autogen".cpu`:
+			io.WriteString(w, `{"results":[{"series":[{"name":"cpu","columns":["fieldKey","fieldType"],"values":[["n","integer"],["value","float"]]}]}]}`)
+		case q == `SELECT * FROM "autogen".cpu ORDER BY time ASC LIMIT 1`:
+			io.WriteString(w, `{"results":[{"series":[{"name":"cpu","columns":["time","host","n","value"],"values":[[0,"a",2,1.5]]}]}]}`)
+		case q == `SELECT * FROM "autogen".cpu ORDER BY time DESC LIMIT 1`:
+			fmt.Fprintf(w, `{"results":[{"series":[{"name":"cpu","columns":["time","host","n","value"],"values":[[%d,"b",null,2]]}]}]}`, 3*hour)
+		case strings.HasPrefix(q, `SELECT * FROM "autogen".cpu WHERE`):
+			if _, err := fmt.Sscanf(q, `SELECT * FROM "autogen".cpu WHERE time >= %d AND time < %d GROUP BY *`, &lo, &hi); err != nil {
+				t.Errorf("unexpected query %q: %s", q, err)
+			}
+			if hi-lo > hour {
+				t.Errorf("window too large: %q", q)
+			}
+			var series []string
+			if lo <= 0 && 0 < hi {
+				series = append(series, `{"name":"cpu","tags":{"host":"a"},"columns":["time","n","value"],"values":[[0,2,1.5]]}`)
+			}
+			if lo <= 3*hour && 3*hour < hi {
+				series = append(series, fmt.Sprintf(`{"name":"cpu","tags":{"host":"b"},"columns":["time","n","value"],"values":[[%d,null,2]]}`, 3*hour))
+			}
+			fmt.Fprintf(w, `{"results":[{"series":[%s]}]}`+"\n", strings.Join(series, ","))
+		default:
+			t.Errorf("unexpected query %q", q)
+			io.WriteString(w, `{"results":[{}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	m := cli.CommandLine{Client: c, IgnoreSignals: true}
+
+	path := filepath.Join(t.TempDir(), "mydb.lp.gz")
+	if err := m.ParseCommand("export db mydb " + path + " -window 1h"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(buf), "\n")
+	exp := []string{
+		"# DML",
+		"# CONTEXT-DATABASE:mydb",
+		"# CONTEXT-RETENTION-POLICY:autogen",
+		"# exported from " + ts.URL + ", 1970-01-01T00:00:00Z to 1970-01-01T03:00:00.000000001Z",
+		"cpu,host=a n=2i,value=1.5 0",
+		"cpu,host=b value=2 10800000000000",
+		"",
+	}
+	if !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("unexpected export:\ngot:\n%s\nexp:\n%s", strings.Join(lines, "\n"), strings.Join(exp, "\n"))
+	}
+}
+
+func TestParseCommand_Node(t *testing.T) {
+	t.Parallel()
+	ts := emptyTestServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	config := client.Config{URL: *u}
+	c, err := client.NewClient(config)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	tests := []struct {
+		cmd string
+		id  int
+	}{
+		{cmd: "node 2", id: 2},
+		{cmd: "node 1 verify", id: 1},
+		{cmd: "node 3 VERIFY;", id: 3},
+		{cmd: "node clear", id: 0},
+		{cmd: "node x", id: 0},
+	}
+
+	m := cli.CommandLine{Client: c}
+	for _, test := range tests {
+		if err := m.ParseCommand(test.cmd); err != nil {
+			t.Fatalf(`Got error %v for command %q, expected nil.`, err, test.cmd)
+		}
+		if m.NodeID != test.id {
+			t.Fatalf(`Command %q changed node id to %d. Expected %d`, test.cmd, m.NodeID, test.id)
+		}
+	}
+}
+
 func TestParseCommand_Consistency(t *testing.T) {
 	t.Parallel()
 	c := cli.CommandLine{}
@@ -543,6 +1293,62 @@ func TestParseCommand_Insert(t *testing.T) {
 	}
 }
 
+func TestParseCommand_InsertCreateDatabase(t *testing.T) {
+	t.Parallel()
+	var created []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Influxdb-Version", SERVER_VERSION)
+		switch r.URL.Path {
+		case "/query":
+			q := r.URL.Query().Get("q")
+			switch {
+			case strings.HasPrefix(q, "CREATE "):
+				created = append(created, q)
+				io.WriteString(w, `{"results":[{}]}`)
+			case strings.HasPrefix(q, "SHOW RETENTION POLICIES ON broken"):
+				io.WriteString(w, `{"results":[{"error":"database not found: broken"}]}`)
+			case strings.HasPrefix(q, "SHOW RETENTION POLICIES"):
+				io.WriteString(w, `{"results":[{"series":[{"columns":["name","duration","shardGroupDuration","replicaN","default"],"values":[["autogen","0s","168h0m0s",1,true]]}]}]}`)
+			default:
+				io.WriteString(w, `{"results":[{}]}`)
+			}
+		case "/write":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	m := cli.CommandLine{Client: c, CreateDatabase: true}
+
+	for _, cmd := range []string{
+		`INSERT INTO db.autogen cpu value=1`,
+		`INSERT INTO newdb.rp1 cpu value=1`,
+		`INSERT INTO broken.rp1 cpu value=1`,
+		`INSERT cpu value=1`,
+	} {
+		if err := m.ParseCommand(cmd); err != nil {
+			t.Fatalf(`Got error %v for command %q, expected nil.`, err, cmd)
+		}
+	}
+
+	// CREATE DATABASE is sent whether or not the database exists, and a
+	// missing retention policy is created rather than silently ignored.
+	exp := []string{
+		`CREATE DATABASE db`,
+		`CREATE DATABASE newdb`,
+		`CREATE RETENTION POLICY rp1 ON newdb DURATION INF REPLICATION 1`,
+		`CREATE DATABASE broken`,
+	}
+	if !reflect.DeepEqual(created, exp) {
+		t.Fatalf("unexpected create statements: got %q, exp %q", created, exp)
+	}
+}
+
 func TestParseCommand_History(t *testing.T) {
 	t.Parallel()
 	c := cli.CommandLine{Line: liner.NewLiner()}
@@ -574,6 +1380,33 @@ func TestParseCommand_History(t *testing.T) {
 	}
 }
 
+func TestParseCommand_HistorySearchAndRun(t *testing.T) {
+	t.Parallel()
+	c := cli.CommandLine{Line: liner.NewLiner()}
+	defer c.Line.Close()
+
+	c.Line.AppendHistory("use db0")
+	c.Line.AppendHistory("history run 1")
+	c.Line.AppendHistory("format json")
+
+	for _, cmd := range []string{
+		"history search format",
+		"history search",
+		"history run 3",
+		"history run 2",
+		"history run 9",
+		"history run x",
+		"history bogus",
+	} {
+		if err := c.ParseCommand(cmd); err != nil {
+			t.Fatalf(`Got error %v for command %q, expected nil.`, err, cmd)
+		}
+	}
+	if c.Format != "json" {
+		t.Fatalf(`"history run 3" did not run "format json": format is %q`, c.Format)
+	}
+}
+
 func TestParseCommand_HistoryWithBlankCommand(t *testing.T) {
 	t.Parallel()
 	c := cli.CommandLine{Line: liner.NewLiner()}
@@ -645,8 +1478,12 @@ func emptyTestServerWithPath(path string) *httptest.Server {
 					w.WriteHeader(http.StatusUnauthorized)
 					io.WriteString(w, fmt.Sprintf(`{"error":"error authorizing query: %s not authorized to execute statement 'SHOW DATABASES', requires admin privilege"}`, user))
 				}
+			case *influxql.ShowRetentionPoliciesStatement:
+				io.WriteString(w, `{"results":[{"series":[{"columns":["name","duration","shardGroupDuration","replicaN","default"],"values":[["autogen","0s","168h0m0s",1,true],["one week","168h0m0s","24h0m0s",1,false]]}]}]}`)
 			case *influxql.ShowDiagnosticsStatement:
 				io.WriteString(w, `{"results":[{}]}`)
+			case *influxql.ShowShardsStatement:
+				io.WriteString(w, `{"results":[{"series":[{"name":"db","columns":["id","database","retention_policy","shard_group","start_time","end_time","expiry_time","owners"],"values":[[1,"db","autogen",1,"2019-01-01T00:00:00Z","2019-01-08T00:00:00Z","2019-01-08T00:00:00Z","1,2"]]}]}]}`)
 			}
 		case writePath:
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// lineCounter counts the bytes and lines written through it.
+type lineCounter struct {
+	w     io.Writer
+	bytes int64
+	lines int64
+	last  byte
+}
+
+func (lc *lineCounter) Write(p []byte) (int, error) {
+	n, err := lc.w.Write(p)
+	lc.bytes += int64(n)
+	lc.lines += int64(bytes.Count(p[:n], []byte{'\n'}))
+	if n > 0 {
+		lc.last = p[n-1]
+	}
+	return n, err
+}
+
+// total returns the number of lines, counting a last line without a
+// trailing newline.
+func (lc *lineCounter) total() int64 {
+	if lc.bytes > 0 && lc.last != '\n' {
+		return lc.lines + 1
+	}
+	return lc.lines
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// decompress handles "decompress <in.gz> <out>", writing the decompressed
+// contents of a gzip file to out. The data is streamed, and the gzip checksum
+// is verified at the end; out is removed if the input is corrupt.
+func (c *CommandLine) decompress(cmd string) error {
+	args := strings.Fields(strings.TrimSpace(cmd)[len("decompress"):])
+	if len(args) != 2 {
+		fmt.Println("Usage: decompress <in.gz> <out>")
+		return nil
+	}
+	in, out := args[0], args[1]
+	if in == out {
+		return fmt.Errorf("decompress: input and output are the same file")
+	}
+
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cr := &countingReader{r: f}
+	gr, err := gzip.NewReader(cr)
+	if err != nil {
+		return fmt.Errorf("%s: %s", in, err)
+	}
+	defer gr.Close()
+
+	o, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	lc := &lineCounter{w: o}
+	_, err = io.Copy(lc, gr)
+	if cerr := o.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out)
+		return fmt.Errorf("%s: %s", in, err)
+	}
+
+	fmt.Fprintf(c.output(), "decompressed %s to %s: %d lines, %d bytes (%d compressed)\n",
+		in, out, lc.total(), lc.bytes, cr.n)
+	return nil
+}
@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// diffSeries holds the rows of one series keyed by their time, or by their
+// position when the series has no time column.
+type diffSeries struct {
+	rows map[string]map[string]interface{}
+	keys []string
+}
+
+// diff handles "diff <query>", running the query against the current
+// connection and DiffHost and printing the rows that differ.
+func (c *CommandLine) diff(cmd string) error {
+	query := strings.TrimSpace(strings.TrimSpace(cmd)[len("diff"):])
+	if query == "" {
+		fmt.Println("Usage: diff <query>")
+		return nil
+	}
+	if c.DiffHost == "" {
+		fmt.Println("diff requires a second server, set with -diff-host")
+		return nil
+	}
+
+	config := c.ClientConfig
+	u, err := client.ParseConnectionString(c.DiffHost, c.Ssl)
+	if err != nil {
+		return err
+	}
+	config.URL = u
+	config.UserAgent = "InfluxDBShell/" + c.ClientVersion
+	config.Proxy = http.ProxyFromEnvironment
+	other, err := client.NewClient(config)
+	if err != nil {
+		return fmt.Errorf("Could not create client %s", err)
+	}
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	responses := make([]*client.Response, 2)
+	for i, cl := range []*client.Client{c.Client, other} {
+		response, err := cl.QueryContext(ctx, c.query(query))
+		if err == nil {
+			err = response.Error()
+		}
+		if err != nil {
+			fmt.Printf("%s %s: %s\n", c.errPrefix(), cl.Addr(), err)
+			return err
+		}
+		responses[i] = response
+	}
+
+	if writeDiff(c.output(), responses[0], responses[1], c.DiffTolerance) == 0 {
+		fmt.Fprintln(c.output(), "no differences")
+	}
+	return nil
+}
+
+// writeDiff writes the rows only in a as "-", the rows only in b as "+" and
+// the rows in both with different values as "~", and returns the number of
+// differences. Columns are matched by name, so their order does not matter,
+// and numbers within tolerance of each other are equal.
+func writeDiff(w io.Writer, a, b *client.Response, tolerance float64) int {
+	as, bs := diffIndex(a), diffIndex(b)
+
+	var names []string
+	for name := range as {
+		names = append(names, name)
+	}
+	for name := range bs {
+		if _, ok := as[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	n := 0
+	for _, name := range names {
+		sa, sb := as[name], bs[name]
+		for _, key := range diffKeys(sa, sb) {
+			ra, inA := sa.row(key)
+			rb, inB := sb.row(key)
+			switch {
+			case !inB:
+				fmt.Fprintf(w, "- %s %s\n", name, formatDiffRow(ra))
+				n++
+			case !inA:
+				fmt.Fprintf(w, "+ %s %s\n", name, formatDiffRow(rb))
+				n++
+			default:
+				if changes := diffRow(ra, rb, tolerance); len(changes) > 0 {
+					fmt.Fprintf(w, "~ %s %s %s\n", name, key, strings.Join(changes, " "))
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// diffIndex groups the rows of a response by statement and series.
+func diffIndex(response *client.Response) map[string]*diffSeries {
+	index := make(map[string]*diffSeries)
+	for i, result := range response.Results {
+		for _, row := range result.Series {
+			name := row.Name
+			if len(row.Tags) > 0 {
+				tags := make([]string, 0, len(row.Tags))
+				for k, v := range row.Tags {
+					tags = append(tags, k+"="+v)
+				}
+				sort.Strings(tags)
+				name += "," + strings.Join(tags, ",")
+			}
+			if len(response.Results) > 1 {
+				name = fmt.Sprintf("[%d]%s", i, name)
+			}
+
+			s := index[name]
+			if s == nil {
+				s = &diffSeries{rows: make(map[string]map[string]interface{})}
+				index[name] = s
+			}
+			timeIdx := -1
+			for j, col := range row.Columns {
+				if col == "time" {
+					timeIdx = j
+				}
+			}
+			for _, v := range row.Values {
+				values := make(map[string]interface{}, len(v))
+				for j, col := range row.Columns {
+					if j < len(v) {
+						values[col] = v[j]
+					}
+				}
+				key := fmt.Sprintf("#%d", len(s.keys))
+				if timeIdx >= 0 && timeIdx < len(v) {
+					key = "time=" + interfaceToString(v[timeIdx])
+				}
+				// Rows sharing a time are told apart by their position.
+				for base, k := key, 1; s.rows[key] != nil; k++ {
+					key = fmt.Sprintf("%s#%d", base, k)
+				}
+				s.rows[key] = values
+				s.keys = append(s.keys, key)
+			}
+		}
+	}
+	return index
+}
+
+func (s *diffSeries) row(key string) (map[string]interface{}, bool) {
+	if s == nil {
+		return nil, false
+	}
+	r, ok := s.rows[key]
+	return r, ok
+}
+
+// diffKeys returns the row keys of a followed by those only in b.
+func diffKeys(a, b *diffSeries) []string {
+	var keys []string
+	if a != nil {
+		keys = append(keys, a.keys...)
+	}
+	if b != nil {
+		for _, k := range b.keys {
+			if _, ok := a.row(k); !ok {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+// diffRow returns the columns whose values differ as "col=a->b".
+func diffRow(a, b map[string]interface{}, tolerance float64) []string {
+	cols := make([]string, 0, len(a))
+	for col := range a {
+		cols = append(cols, col)
+	}
+	for col := range b {
+		if _, ok := a[col]; !ok {
+			cols = append(cols, col)
+		}
+	}
+	sort.Strings(cols)
+
+	var changes []string
+	for _, col := range cols {
+		va, vb := a[col], b[col]
+		if diffEqual(va, vb, tolerance) {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s=%s->%s", col, interfaceToString(va), interfaceToString(vb)))
+	}
+	return changes
+}
+
+// diffEqual reports whether two values are equal, comparing numbers with
+// the given tolerance.
+func diffEqual(a, b interface{}, tolerance float64) bool {
+	if isDiffNumber(a) && isDiffNumber(b) {
+		return math.Abs(toFloat64(a)-toFloat64(b)) <= tolerance
+	}
+	return (a == nil) == (b == nil) && interfaceToString(a) == interfaceToString(b)
+}
+
+func isDiffNumber(v interface{}) bool {
+	switch v.(type) {
+	case json.Number, float32, float64, int, int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		return true
+	}
+	return false
+}
+
+// formatDiffRow formats a row as space separated col=value pairs.
+func formatDiffRow(row map[string]interface{}) string {
+	cols := make([]string, 0, len(row))
+	for col := range row {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for i, col := range cols {
+		cols[i] = col + "=" + interfaceToString(row[col])
+	}
+	return strings.Join(cols, " ")
+}
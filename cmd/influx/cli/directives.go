@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// applyDirectives sets session options from the directives in the leading
+// comments of a script read from stdin, such as "-- format: csv" or
+// "-- chunk: 1000", so that a script carries the options it needs. Only the
+// comments before the first statement are read, and only those of the form
+// "-- name: value" with a single word name are directives; other comments are
+// left alone. An unknown directive or invalid value is reported on stderr and
+// ignored.
+func (c *CommandLine) applyDirectives(script string) {
+	for n, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			return
+		}
+		text := strings.TrimSpace(line[len("--"):])
+		i := strings.IndexByte(text, ':')
+		if i <= 0 || strings.ContainsAny(text[:i], " \t") {
+			continue
+		}
+		name, value := strings.ToLower(text[:i]), strings.TrimSpace(text[i+1:])
+		switch name {
+		case "format":
+			if !isFormat(strings.ToLower(value)) {
+				fmt.Fprintf(os.Stderr, "WARN: line %d: unknown format %q, ignoring the directive\n", n+1, value)
+				continue
+			}
+			c.Format = strings.ToLower(value)
+		case "chunk":
+			size, err := strconv.Atoi(value)
+			if err != nil || size < 0 {
+				fmt.Fprintf(os.Stderr, "WARN: line %d: invalid chunk size %q, ignoring the directive\n", n+1, value)
+				continue
+			}
+			c.ChunkSize = size
+		default:
+			fmt.Fprintf(os.Stderr, "WARN: line %d: unknown directive %q, ignoring it\n", n+1, name)
+		}
+	}
+}
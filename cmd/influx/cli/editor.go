@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// defaultEditor is the editor used when $EDITOR is not set.
+const defaultEditor = "vi"
+
+// editorCommand returns the command used to edit the file at path.
+func editorCommand(path string) *exec.Cmd {
+	args := strings.Fields(os.Getenv("EDITOR"))
+	if len(args) == 0 {
+		args = []string{defaultEditor}
+	}
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// edit opens the last query in the editor and runs the saved result. Nothing
+// is run if the editor exits with an error or the file is left empty.
+func (c *CommandLine) edit() error {
+	if c.Line == nil {
+		fmt.Println("edit is only available in interactive mode")
+		return nil
+	}
+
+	f, err := os.CreateTemp("", "influx-*.iql")
+	if err != nil {
+		return err
+	}
+	path := f.Name()
+	defer os.Remove(path)
+
+	_, err = f.WriteString(c.lastQuery)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := editorCommand(path).Run(); err != nil {
+		fmt.Printf("editor failed, query not run: %s\n", err)
+		return nil
+	}
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	query := strings.TrimSpace(string(buf))
+	if query == "" {
+		fmt.Println("empty query, nothing to run")
+		return nil
+	}
+	c.Line.AppendHistory(query)
+	return c.ExecuteQuery(query)
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxql"
+)
+
+// explain handles "explain <query>" and "explain analyze <query>", running
+// EXPLAIN or EXPLAIN ANALYZE for a single SELECT statement and printing the
+// plan as plain text.
+func (c *CommandLine) explain(cmd string) error {
+	query := strings.TrimSpace(strings.TrimSpace(cmd)[len("explain"):])
+	analyze := false
+	if fields := strings.Fields(query); len(fields) > 0 && strings.EqualFold(fields[0], "analyze") {
+		analyze = true
+		query = strings.TrimSpace(query[len(fields[0]):])
+	}
+	if query == "" {
+		fmt.Println("Usage: explain [analyze] <query>")
+		return nil
+	}
+
+	q, err := influxql.NewParser(strings.NewReader(query)).ParseQuery()
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	if len(q.Statements) != 1 {
+		fmt.Println("explain takes a single SELECT statement")
+		return nil
+	}
+	stmt, ok := q.Statements[0].(*influxql.SelectStatement)
+	if !ok {
+		fmt.Println("explain only supports SELECT statements; EXPLAIN shows how InfluxDB reads the data a SELECT statement returns")
+		return nil
+	}
+
+	explain := &influxql.ExplainStatement{Statement: stmt, Analyze: analyze}
+	c.qualifySources(explain)
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	response, err := c.Client.QueryContext(ctx, c.query(explain.String()))
+	if err == nil {
+		err = response.Error()
+	}
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	writePlan(c.output(), response)
+	return nil
+}
+
+// writePlan writes the rows of an EXPLAIN response one per line, without the
+// table formatting, so the indentation of the plan is kept.
+func writePlan(w io.Writer, response *client.Response) {
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for i, col := range row.Columns {
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintln(w, col)
+				fmt.Fprintln(w, strings.Repeat("-", len(col)))
+				for _, v := range row.Values {
+					if i < len(v) {
+						fmt.Fprintln(w, interfaceToString(v[i]))
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxdb/models"
+)
+
+// exportFile is a CSV file receiving the rows of one tag value.
+type exportFile struct {
+	f      *os.File
+	w      *csv.Writer
+	header []string // last header written, repeated when the columns change
+}
+
+// tagExporter writes the rows of a response into one CSV file per value of
+// a tag. Rows without the tag go to a file named "_untagged".
+type tagExporter struct {
+	tag   string
+	dir   string
+	files map[string]*exportFile // by tag value
+	names map[string]string      // tag value by file name, to avoid collisions
+}
+
+// exportBy handles "export by <tagkey> <dir> <query>". The query is streamed
+// in chunks and each row is written to <dir>/<tag value>.csv.
+func (c *CommandLine) exportBy(cmd string) error {
+	fields := strings.Fields(cmd)
+	if len(fields) < 5 || !strings.EqualFold(fields[1], "by") {
+		fmt.Println("Usage: export by <tagkey> <dir> <query>")
+		return nil
+	}
+	tag, dir := fields[2], fields[3]
+	// Keep the query as typed, including its whitespace.
+	query := cmd
+	for _, f := range fields[:4] {
+		query = strings.TrimSpace(query)[len(f):]
+	}
+	query = strings.TrimSpace(query)
+
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	e := &tagExporter{tag: tag, dir: dir, files: make(map[string]*exportFile), names: make(map[string]string)}
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	err := c.Client.QueryEach(ctx, c.query(query), e.write)
+	if cerr := e.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	fmt.Printf("wrote %d files to %s\n", len(e.files), dir)
+	return nil
+}
+
+// write writes the rows of one chunk to the files of their tag values.
+func (e *tagExporter) write(response *client.Response) error {
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			if err := e.writeRow(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *tagExporter) writeRow(row models.Row) error {
+	keys := make([]string, 0, len(row.Tags))
+	for k := range row.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	header := append(append([]string{"name"}, keys...), row.Columns...)
+
+	// The tag is either a series tag, from GROUP BY, or a selected column.
+	col := -1
+	for i, c := range row.Columns {
+		if c == e.tag {
+			col = i
+		}
+	}
+
+	for _, v := range row.Values {
+		value, ok := row.Tags[e.tag]
+		if col >= 0 && col < len(v) && v[col] != nil {
+			value, ok = interfaceToString(v[col]), true
+		}
+		if !ok {
+			value = ""
+		}
+		f, err := e.file(value)
+		if err != nil {
+			return err
+		}
+		if !equalStrings(f.header, header) {
+			f.header = header
+			if err := f.w.Write(header); err != nil {
+				return err
+			}
+		}
+
+		record := make([]string, 0, len(header))
+		record = append(record, row.Name)
+		for _, k := range keys {
+			record = append(record, row.Tags[k])
+		}
+		for _, x := range v {
+			record = append(record, interfaceToString(x))
+		}
+		if err := f.w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// file returns the file for a tag value, creating it on first use.
+func (e *tagExporter) file(value string) (*exportFile, error) {
+	if f, ok := e.files[value]; ok {
+		return f, nil
+	}
+	name := exportFileName(value)
+	base := name
+	for i := 2; ; i++ {
+		if _, taken := e.names[name]; !taken {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	e.names[name] = value
+
+	f, err := os.Create(filepath.Join(e.dir, name+".csv"))
+	if err != nil {
+		return nil, err
+	}
+	ef := &exportFile{f: f, w: csv.NewWriter(f)}
+	e.files[value] = ef
+	return ef, nil
+}
+
+// close flushes and closes every file, returning the first error.
+func (e *tagExporter) close() error {
+	var firstErr error
+	for _, f := range e.files {
+		f.w.Flush()
+		err := f.w.Error()
+		if cerr := f.f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// exportFileName turns a tag value into a file name that is safe on common
+// filesystems by replacing everything but letters, digits, '.', '-' and '_'.
+func exportFileName(value string) string {
+	if value == "" {
+		return "_untagged"
+	}
+	b := []byte(value)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		case c == '.' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
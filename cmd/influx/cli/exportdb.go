@@ -0,0 +1,344 @@
+package cli
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxql"
+)
+
+// DefaultExportWindow is the time range selected per query by export db.
+const DefaultExportWindow = 24 * time.Hour
+
+// exportDBUsage is printed for malformed export db commands.
+const exportDBUsage = "Usage: export db <name> <path> [-rp <rp>] [-since <time>] [-until <time>] [-window <duration>]"
+
+// exportDB handles "export db <name> <path>", writing every point of a
+// database's retention policy to path as line protocol, gzipped if path ends
+// in .gz. The file starts with the same context comments as influx_inspect
+// export so it can be loaded with influx -import.
+//
+// Points are selected one time window at a time, every measurement in turn,
+// so memory stays bounded and all points before the current window are
+// written when the export stops. The error then says which -since to resume
+// from.
+func (c *CommandLine) exportDB(cmd string) (err error) {
+	defer func() {
+		if err != nil {
+			fmt.Printf("%s %s\n", c.errPrefix(), err)
+		}
+	}()
+
+	args := strings.Fields(cmd)
+	if len(args) < 4 {
+		fmt.Println(exportDBUsage)
+		return nil
+	}
+	db, path := args[2], args[3]
+
+	fs := flag.NewFlagSet("export db", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	rp := fs.String("rp", "", "")
+	sinceArg := fs.String("since", "", "")
+	untilArg := fs.String("until", "", "")
+	window := fs.Duration("window", DefaultExportWindow, "")
+	if err := fs.Parse(args[4:]); err != nil || fs.NArg() > 0 || *window <= 0 {
+		fmt.Println(exportDBUsage)
+		return nil
+	}
+	var since, until time.Time
+	for _, t := range []struct {
+		arg string
+		dst *time.Time
+	}{{*sinceArg, &since}, {*untilArg, &until}} {
+		if t.arg == "" {
+			continue
+		}
+		v, err := time.Parse(time.RFC3339Nano, t.arg)
+		if err != nil {
+			return fmt.Errorf("export db: times must be RFC3339: %s", err)
+		}
+		*t.dst = v
+	}
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	// Times are read back as nanosecond epochs whatever the precision is.
+	c.Client.SetPrecision("ns")
+	defer c.Client.SetPrecision(c.ClientConfig.Precision)
+
+	if *rp == "" {
+		name, err := c.defaultRetentionPolicy(ctx, db)
+		if err != nil {
+			return err
+		}
+		*rp = name
+	}
+	measurements, err := c.exportMeasurements(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if since.IsZero() || until.IsZero() {
+		first, last, ok, err := c.exportRange(ctx, db, *rp, measurements)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			first, last = time.Unix(0, 0), time.Unix(0, 0)
+		}
+		if since.IsZero() {
+			since = first
+		}
+		if until.IsZero() {
+			until = last.Add(time.Nanosecond)
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	var gw *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gw = gzip.NewWriter(bw)
+		w = gw
+	}
+
+	e := &dbExporter{c: c, db: db, rp: *rp, w: w}
+	fmt.Fprintf(w, "# DML\n# CONTEXT-DATABASE:%s\n# CONTEXT-RETENTION-POLICY:%s\n", db, *rp)
+	fmt.Fprintf(w, "# exported from %s, %s to %s\n", c.Client.Addr(), since.UTC().Format(time.RFC3339Nano), until.UTC().Format(time.RFC3339Nano))
+
+	err = e.export(ctx, measurements, since, until, *window)
+	if gw != nil {
+		if cerr := gw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d points from %d measurements to %s\n", e.points, len(measurements), path)
+	return nil
+}
+
+// dbExporter writes the points of a retention policy as line protocol.
+type dbExporter struct {
+	c      *CommandLine
+	db, rp string
+	w      io.Writer
+	points int64
+}
+
+// export writes the points from since up to until, reporting progress at
+// most once a second.
+func (e *dbExporter) export(ctx context.Context, measurements []string, since, until time.Time, window time.Duration) error {
+	reported := time.Now()
+	for start := since; start.Before(until); {
+		end := start.Add(window)
+		if end.After(until) {
+			end = until
+		}
+		for _, m := range measurements {
+			if err := e.exportWindow(ctx, m, start, end); err != nil {
+				return fmt.Errorf("%s; the points before this window were exported, resume with -since %s",
+					err, start.UTC().Format(time.RFC3339Nano))
+			}
+		}
+		start = end
+		if time.Since(reported) >= time.Second {
+			fmt.Printf("exported %d points up to %s\n", e.points, end.UTC().Format(time.RFC3339Nano))
+			reported = time.Now()
+		}
+	}
+	return nil
+}
+
+// exportWindow writes the points of one measurement within [start, end).
+func (e *dbExporter) exportWindow(ctx context.Context, measurement string, start, end time.Time) error {
+	types := e.c.knownFieldTypes(fieldTypeKey{db: e.db, rp: e.rp, measurement: measurement})
+	q := fmt.Sprintf("SELECT * FROM %s WHERE time >= %d AND time < %d GROUP BY *",
+		influxql.QuoteIdent(e.rp, measurement), start.UnixNano(), end.UnixNano())
+	return e.c.Client.QueryEach(ctx, client.Query{Command: q, Database: e.db, Chunked: true, ChunkSize: e.c.ChunkSize}, func(response *client.Response) error {
+		if err := response.Error(); err != nil {
+			return err
+		}
+		for _, result := range response.Results {
+			for _, row := range result.Series {
+				if err := e.writeRow(row, types); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (e *dbExporter) writeRow(row models.Row, types map[string]string) error {
+	tags := models.NewTags(row.Tags)
+	for _, v := range row.Values {
+		if len(v) == 0 {
+			continue
+		}
+		ts, err := exportTime(v[0])
+		if err != nil {
+			return err
+		}
+		fields := make(models.Fields)
+		for i := 1; i < len(v) && i < len(row.Columns); i++ {
+			if v[i] == nil {
+				continue
+			}
+			fields[row.Columns[i]] = exportFieldValue(v[i], types[row.Columns[i]])
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		p, err := models.NewPoint(row.Name, tags, fields, ts)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(e.w, p.String()+"\n"); err != nil {
+			return err
+		}
+		e.points++
+	}
+	return nil
+}
+
+// exportTime converts a time column value in nanoseconds to a time.
+func exportTime(v interface{}) (time.Time, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unexpected time value %v", v)
+	}
+	ns, err := n.Int64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ns), nil
+}
+
+// exportFieldValue converts a field value from a response to the Go type of
+// its field type, so that it is written back with the same type.
+func exportFieldValue(v interface{}, typ string) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	switch typ {
+	case "integer":
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+	case "unsigned":
+		if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+			return u
+		}
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// defaultRetentionPolicy returns the name of the default retention policy of
+// a database.
+func (c *CommandLine) defaultRetentionPolicy(ctx context.Context, db string) (string, error) {
+	response, err := c.statusQuery(ctx, "SHOW RETENTION POLICIES ON "+influxql.QuoteIdent(db))
+	if err != nil {
+		return "", err
+	}
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			name, def := -1, -1
+			for i, col := range row.Columns {
+				switch col {
+				case "name":
+					name = i
+				case "default":
+					def = i
+				}
+			}
+			if name < 0 || def < 0 {
+				continue
+			}
+			for _, v := range row.Values {
+				if isDefault, _ := v[def].(bool); isDefault {
+					return fmt.Sprint(v[name]), nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("database %q has no default retention policy, use -rp", db)
+}
+
+// exportMeasurements returns the names of the measurements of a database.
+func (c *CommandLine) exportMeasurements(ctx context.Context, db string) ([]string, error) {
+	response, err := c.statusQuery(ctx, "SHOW MEASUREMENTS ON "+influxql.QuoteIdent(db))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, v := range row.Values {
+				if len(v) > 0 {
+					names = append(names, fmt.Sprint(v[0]))
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// exportRange returns the times of the first and last points of the
+// measurements. ok is false if they have no points.
+func (c *CommandLine) exportRange(ctx context.Context, db, rp string, measurements []string) (first, last time.Time, ok bool, err error) {
+	for _, m := range measurements {
+		for _, order := range []string{"ASC", "DESC"} {
+			q := fmt.Sprintf("SELECT * FROM %s ORDER BY time %s LIMIT 1", influxql.QuoteIdent(rp, m), order)
+			response, err := c.Client.QueryContext(ctx, client.Query{Command: q, Database: db})
+			if err == nil {
+				err = response.Error()
+			}
+			if err != nil {
+				return first, last, ok, err
+			}
+			v, found := statusValue(response, m, "time")
+			if !found {
+				continue
+			}
+			t, err := exportTime(v)
+			if err != nil {
+				return first, last, ok, err
+			}
+			if !ok || t.Before(first) {
+				first = t
+			}
+			if !ok || t.After(last) {
+				last = t
+			}
+			ok = true
+		}
+	}
+	return first, last, ok, nil
+}
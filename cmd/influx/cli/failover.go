@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// connectHosts connects to the first of Hosts that answers a ping, trying
+// them in order from start and wrapping around. Without Hosts it connects to
+// the current URL. Hosts that fail are logged, as is the one connected to
+// when it is not the first tried.
+func (c *CommandLine) connectHosts(start int) error {
+	if len(c.Hosts) == 0 {
+		return c.Connect("")
+	}
+	var err error
+	for i := range c.Hosts {
+		j := (start + i) % len(c.Hosts)
+		host := c.Hosts[j]
+		if err = c.Connect(strings.TrimSuffix(host, "/") + "/" + c.PathPrefix); err != nil {
+			fmt.Fprintf(os.Stderr, "WARN: could not connect to %s: %s\n", redactHost(host), err)
+			continue
+		}
+		c.activeHost = j
+		if i > 0 || !c.Quiet {
+			fmt.Fprintf(os.Stderr, "Using host %s\n", redactHost(host))
+		}
+		return nil
+	}
+	return err
+}
+
+// activeHostName returns the entry of Hosts last connected to, with any
+// password redacted, or "" if Hosts is not set.
+func (c *CommandLine) activeHostName() string {
+	if c.activeHost < len(c.Hosts) {
+		return redactHost(c.Hosts[c.activeHost])
+	}
+	return ""
+}
+
+// redactedHosts returns Hosts with any password in the entries redacted.
+func (c *CommandLine) redactedHosts() []string {
+	if len(c.Hosts) == 0 {
+		return nil
+	}
+	hosts := make([]string, len(c.Hosts))
+	for i, h := range c.Hosts {
+		hosts[i] = redactHost(h)
+	}
+	return hosts
+}
+
+// redactHost replaces the password of a user:pass@host entry of Hosts, with
+// or without a scheme, by "xxxxx" as url.URL.Redacted does.
+func redactHost(host string) string {
+	var scheme string
+	rest := host
+	if i := strings.Index(host, "://"); i >= 0 {
+		scheme, rest = host[:i+len("://")], host[i+len("://"):]
+	}
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	at := strings.LastIndex(rest[:end], "@")
+	if at < 0 {
+		return host
+	}
+	user, _, ok := strings.Cut(rest[:at], ":")
+	if !ok {
+		return host
+	}
+	return scheme + user + ":xxxxx@" + rest[at+1:]
+}
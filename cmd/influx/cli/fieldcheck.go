@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxql"
+)
+
+// fieldTypeKey identifies a measurement in the field type cache.
+type fieldTypeKey struct {
+	db, rp, measurement string
+}
+
+// checkFieldTypes warns about the fields of bp whose type differs from the
+// type the server already has for them, as the server rejects such writes
+// with an error that does not say much. The known types come from SHOW FIELD
+// KEYS, queried once per measurement and cached. The check is advisory: it
+// returns the field types written so the cache can be updated once the write
+// succeeds.
+func (c *CommandLine) checkFieldTypes(bp *client.BatchPoints) map[fieldTypeKey]map[string]string {
+	db := bp.Database
+	if db == "" {
+		return nil
+	}
+	var raw []string
+	for _, p := range bp.Points {
+		raw = append(raw, p.Raw)
+	}
+	points, err := models.ParsePointsWithPrecision([]byte(strings.Join(raw, "\n")), time.Now().UTC(), bp.Precision)
+	if err != nil {
+		// The server reports the parse error.
+		return nil
+	}
+
+	written := make(map[fieldTypeKey]map[string]string)
+	for _, p := range points {
+		key := fieldTypeKey{db: db, rp: bp.RetentionPolicy, measurement: string(p.Name())}
+		known := c.knownFieldTypes(key)
+		if written[key] == nil {
+			written[key] = make(map[string]string)
+		}
+		it := p.FieldIterator()
+		for it.Next() {
+			field, typ := string(it.FieldKey()), strings.ToLower(it.Type().String())
+			if want, ok := known[field]; ok && want != typ {
+				fmt.Printf("WARN: field %q of measurement %q is %s, not %s; the server will reject this point\n",
+					field, key.measurement, want, typ)
+			}
+			written[key][field] = typ
+		}
+	}
+	return written
+}
+
+// knownFieldTypes returns the field types of a measurement, querying them the
+// first time. A failed query caches an empty set so it is not repeated.
+func (c *CommandLine) knownFieldTypes(key fieldTypeKey) map[string]string {
+	if types, ok := c.fieldTypeCache[key]; ok {
+		return types
+	}
+	if c.fieldTypeCache == nil {
+		c.fieldTypeCache = make(map[fieldTypeKey]map[string]string)
+	}
+
+	types := make(map[string]string)
+	source := influxql.QuoteIdent(key.measurement)
+	if key.rp != "" {
+		source = influxql.QuoteIdent(key.rp, key.measurement)
+	}
+	q := fmt.Sprintf("SHOW FIELD KEYS ON %s FROM %s", influxql.QuoteIdent(key.db), source)
+	if response, err := c.Client.Query(client.Query{Command: q, Database: key.db}); err == nil && response.Error() == nil {
+		for _, result := range response.Results {
+			for _, row := range result.Series {
+				for _, v := range row.Values {
+					if len(v) >= 2 {
+						types[fmt.Sprint(v[0])] = fmt.Sprint(v[1])
+					}
+				}
+			}
+		}
+	}
+	c.fieldTypeCache[key] = types
+	return types
+}
+
+// rememberFieldTypes adds the types of newly written fields to the cache.
+func (c *CommandLine) rememberFieldTypes(written map[fieldTypeKey]map[string]string) {
+	for key, fields := range written {
+		known := c.fieldTypeCache[key]
+		if known == nil {
+			continue
+		}
+		for field, typ := range fields {
+			if _, ok := known[field]; !ok {
+				known[field] = typ
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxql"
+)
+
+// fieldTypeOrder is the order field types are listed in by fieldtypes.
+// Types not listed here are printed after these, sorted by name.
+var fieldTypeOrder = []string{"float", "integer", "string", "boolean"}
+
+// fieldTypes handles "fieldtypes <measurement>", listing the field keys of a
+// measurement in the current database grouped by type. The measurement may be
+// a glob pattern, in which case every matching measurement is listed.
+func (c *CommandLine) fieldTypes(cmd string) error {
+	name := strings.TrimSpace(strings.TrimSpace(cmd)[len("fieldtypes"):])
+	name = strings.TrimSuffix(name, ";")
+	if name == "" {
+		fmt.Println("Usage: fieldtypes <measurement|pattern>")
+		return nil
+	}
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	source := name
+	if !strings.HasPrefix(name, `"`) {
+		names, err := c.expandMeasurements(ctx, name)
+		if err != nil {
+			fmt.Printf("%s %s\n", c.errPrefix(), err)
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("no measurements matched")
+			return nil
+		}
+		for i := range names {
+			names[i] = influxql.QuoteIdent(names[i])
+		}
+		source = strings.Join(names, ", ")
+	}
+
+	response, err := c.Client.QueryContext(ctx, c.query("SHOW FIELD KEYS FROM "+source))
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	if err := response.Error(); err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	writeFieldTypes(c.output(), response, name)
+	return nil
+}
+
+// writeFieldTypes writes the field keys of a SHOW FIELD KEYS response to w
+// under a heading for each field type.
+func writeFieldTypes(w io.Writer, response *client.Response, measurement string) {
+	empty := true
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			keyIdx, typeIdx := -1, -1
+			for i, col := range row.Columns {
+				switch col {
+				case "fieldKey":
+					keyIdx = i
+				case "fieldType":
+					typeIdx = i
+				}
+			}
+			if keyIdx < 0 || typeIdx < 0 || len(row.Values) == 0 {
+				continue
+			}
+
+			byType := make(map[string][]string)
+			for _, v := range row.Values {
+				if len(v) <= keyIdx || len(v) <= typeIdx {
+					continue
+				}
+				typ := fmt.Sprint(v[typeIdx])
+				byType[typ] = append(byType[typ], fmt.Sprint(v[keyIdx]))
+			}
+
+			if !empty {
+				fmt.Fprintln(w)
+			}
+			empty = false
+			fmt.Fprintf(w, "name: %s\n", row.Name)
+			for _, typ := range fieldTypeNames(byType) {
+				keys := byType[typ]
+				sort.Strings(keys)
+				fmt.Fprintf(w, "%s (%d)\n", typ, len(keys))
+				for _, k := range keys {
+					fmt.Fprintf(w, "  %s\n", k)
+				}
+			}
+		}
+	}
+	if empty {
+		fmt.Fprintf(w, "no fields found for measurement %s\n", measurement)
+	}
+}
+
+// fieldTypeNames returns the types in byType in display order.
+func fieldTypeNames(byType map[string][]string) []string {
+	names := make([]string, 0, len(byType))
+	for _, typ := range fieldTypeOrder {
+		if _, ok := byType[typ]; ok {
+			names = append(names, typ)
+		}
+	}
+	var other []string
+	for typ := range byType {
+		known := false
+		for _, t := range fieldTypeOrder {
+			if typ == t {
+				known = true
+				break
+			}
+		}
+		if !known {
+			other = append(other, typ)
+		}
+	}
+	sort.Strings(other)
+	return append(names, other...)
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// floatVerb matches a single fmt verb for floats, with its flags, width and
+// precision.
+var floatVerb = regexp.MustCompile(`^%[-+# 0]*[0-9]*(\.[0-9]+)?[eEfFgGv]$`)
+
+// setFloatFormat handles "floatfmt <verb|digits>", which prints floats in
+// the column, csv and markdown formats with a fmt verb such as %.3f, or with
+// a number of digits after the decimal point, and "floatfmt clear", which
+// goes back to %v.
+func (c *CommandLine) setFloatFormat(cmd string) {
+	arg := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("floatfmt"):]), ";"))
+	if n, err := strconv.Atoi(arg); err == nil && n >= 0 && n <= 17 {
+		arg = fmt.Sprintf("%%.%df", n)
+	}
+	switch {
+	case arg == "":
+		if c.FloatFormat == "" {
+			fmt.Println("floatfmt is not set")
+		} else {
+			fmt.Printf("floatfmt is %s\n", c.FloatFormat)
+		}
+	case strings.EqualFold(arg, "clear"):
+		c.FloatFormat = ""
+	case !floatVerb.MatchString(arg):
+		fmt.Printf("Invalid float format %q. Please use a verb or a number of digits from 0 to 17, e.g. floatfmt %%.3f or floatfmt 6\n", arg)
+	default:
+		c.FloatFormat = arg
+	}
+}
+
+// valueString returns v as printed in the column, csv and markdown formats,
+// with floats formatted with floatFormat if it is set. A number of the
+// response is a float if it has a fraction or an exponent, as floats with
+// an integer value cannot be told from integers.
+func valueString(v interface{}, floatFormat string) string {
+	if floatFormat == "" {
+		return interfaceToString(v)
+	}
+	switch t := v.(type) {
+	case json.Number:
+		if strings.ContainsAny(t.String(), ".eE") {
+			if f, err := t.Float64(); err == nil {
+				return fmt.Sprintf(floatFormat, f)
+			}
+		}
+	case float32:
+		return fmt.Sprintf(floatFormat, t)
+	case float64:
+		return fmt.Sprintf(floatFormat, t)
+	}
+	return interfaceToString(v)
+}
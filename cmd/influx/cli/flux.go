@@ -31,7 +31,31 @@ func (q *replQuerier) Query(ctx context.Context, deps flux.Dependencies, compile
 	return q.client.Query(ctx, req)
 }
 
-func getFluxREPL(u url.URL, username, password string) (*repl.REPL, error) {
+// fluxREPL is the interactive Flux shell started by Run.
+type fluxREPL interface {
+	Run()
+}
+
+// newFluxREPL returns the interactive Flux shell. It is a variable so tests
+// can replace the REPL with a stub.
+var newFluxREPL = func(ctx context.Context, u url.URL, username, password string) (fluxREPL, error) {
+	r, err := getFluxREPL(ctx, u, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func getFluxREPL(ctx context.Context, u url.URL, username, password string) (*repl.REPL, error) {
+	q, err := newReplQuerier(u, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return repl.New(ctx, flux.NewDefaultDependencies(), q), nil
+}
+
+// newReplQuerier returns a querier sending Flux queries to the server at u.
+func newReplQuerier(u url.URL, username, password string) (*replQuerier, error) {
 	builtin.Initialize()
 
 	c, err := client.NewHTTP(u)
@@ -40,5 +64,5 @@ func getFluxREPL(u url.URL, username, password string) (*repl.REPL, error) {
 	}
 	c.Username = username
 	c.Password = password
-	return repl.New(context.Background(), flux.NewDefaultDependencies(), &replQuerier{client: c}), nil
+	return &replQuerier{client: c}, nil
 }
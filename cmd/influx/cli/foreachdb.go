@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// forEachDB handles "for-each-db <pattern> <query>", running the query
+// against every database matching the glob pattern. The output of each
+// database is preceded by its name. An interrupt stops the remaining
+// databases, and a summary lists the databases that succeeded and failed.
+func (c *CommandLine) forEachDB(cmd string) error {
+	args := strings.TrimSpace(strings.TrimSpace(cmd)[len("for-each-db"):])
+	var pattern, query string
+	if i := strings.IndexAny(args, " \t"); i >= 0 {
+		pattern, query = args[:i], strings.TrimSpace(args[i:])
+	}
+	if pattern == "" || query == "" {
+		fmt.Println("Usage: for-each-db <pattern> <query>")
+		return nil
+	}
+
+	names, err := c.listDatabases()
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	dbs, err := matchNames(names, pattern)
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	if len(dbs) == 0 {
+		fmt.Println("no databases matched")
+		return nil
+	}
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	defer func(db string) { c.Database = db }(c.Database)
+
+	var succeeded, failed []string
+	var firstErr error
+	aborted := false
+	for _, db := range dbs {
+		fmt.Fprintf(c.output(), "database: %s\n", db)
+		c.Database = db
+		if err := c.ExecuteQueryContext(ctx, query); err != nil {
+			failed = append(failed, db)
+			if firstErr == nil {
+				firstErr = err
+			}
+			if err == errAborted || ctx.Err() != nil {
+				aborted = true
+				break
+			}
+			continue
+		}
+		succeeded = append(succeeded, db)
+	}
+
+	fmt.Printf("succeeded: %s\n", strings.Join(succeeded, ", "))
+	fmt.Printf("failed: %s\n", strings.Join(failed, ", "))
+	if aborted {
+		if n := len(succeeded) + len(failed); n < len(dbs) {
+			fmt.Printf("not run: %s\n", strings.Join(dbs[n:], ", "))
+		}
+		return errAborted
+	}
+	if firstErr != nil {
+		return fmt.Errorf("query failed on %d of %d databases: %s", len(failed), len(dbs), firstErr)
+	}
+	return nil
+}
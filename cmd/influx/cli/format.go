@@ -0,0 +1,665 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxdb/models"
+)
+
+// FormatOptions controls how a Formatter renders a response.
+type FormatOptions struct {
+	// Format is the output format. Valid values are json, ndjson, csv, column,
+	// markdown, or promql-style.
+	Format string
+
+	// JSONStyle controls the rendering of the json format.
+	JSONStyle JSONStyle
+
+	// Precision is the epoch precision of numeric timestamps in the response.
+	// Timestamps are RFC3339 strings when it is empty or "rfc3339".
+	Precision string
+
+	// TimeLayout is a Go reference-time layout the time column of the column
+	// and csv formats is printed with. Times are printed as returned by the
+	// server when it is empty.
+	TimeLayout string
+
+	// NullString is printed for null values in the column and csv formats,
+	// so that they differ from empty strings. The json formats print JSON
+	// null regardless.
+	NullString string
+
+	// FloatFormat is the fmt verb floats are printed with in the column, csv
+	// and markdown formats. Floats are printed with %v when it is empty.
+	FloatFormat string
+
+	// Expanded prints each row of the column format as a record with one
+	// line per column, which is easier to read for wide rows.
+	Expanded bool
+}
+
+// Formatter formats query responses. The zero value is ready to use.
+type Formatter struct{}
+
+// Format writes response to w using the format described by opts.
+func (f *Formatter) Format(response *client.Response, w io.Writer, opts FormatOptions) error {
+	switch opts.Format {
+	case "json":
+		return f.writeJSON(response, w, opts)
+	case "ndjson":
+		return f.writeNDJSON(response, w)
+	case "csv":
+		return f.writeCSV(response, w, opts)
+	case "column":
+		return f.writeColumns(response, w, opts)
+	case "markdown":
+		return f.writeMarkdown(response, w, opts)
+	case "promql-style":
+		return f.writePromQL(response, w, opts)
+	default:
+		return unknownFormatError(opts.Format)
+	}
+}
+
+// unknownFormatError is returned by Format for an output format it does not
+// support. The CLI prints it as is rather than as an error.
+type unknownFormatError string
+
+func (e unknownFormatError) Error() string {
+	return fmt.Sprintf("Unknown output format %q.", string(e))
+}
+
+func (f *Formatter) writeJSON(response *client.Response, w io.Writer, opts FormatOptions) error {
+	var data []byte
+	var err error
+	switch opts.JSONStyle {
+	case JSONStylePretty:
+		data, err = json.MarshalIndent(response, "", "    ")
+	case JSONStyleCompact:
+		// Skip HTML escaping so characters such as <, > and & are not
+		// expanded into six byte \u escapes.
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(response)
+		data = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	default:
+		data, err = json.Marshal(response)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to parse json: %s", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// writeNDJSON writes one JSON object per line. Each row of a series becomes
+//
+//	{"name":"cpu","tags":{"host":"a"},"values":{"time":"...","value":1}}
+//
+// with the values in column order and tags omitted when the series has none.
+// Errors are written as {"error":"..."} and messages as
+// {"level":"...","text":"..."}.
+func (f *Formatter) writeNDJSON(response *client.Response, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, result := range response.Results {
+		for _, m := range result.Messages {
+			if err := writeJSONLine(bw, m); err != nil {
+				return err
+			}
+		}
+		if result.Err != nil {
+			if err := writeJSONLine(bw, map[string]string{"error": result.Err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, row := range result.Series {
+			name, err := json.Marshal(row.Name)
+			if err != nil {
+				return err
+			}
+			var tags []byte
+			if len(row.Tags) > 0 {
+				if tags, err = json.Marshal(row.Tags); err != nil {
+					return err
+				}
+			}
+			for _, values := range row.Values {
+				var buf bytes.Buffer
+				buf.WriteString(`{"name":`)
+				buf.Write(name)
+				if tags != nil {
+					buf.WriteString(`,"tags":`)
+					buf.Write(tags)
+				}
+				buf.WriteString(`,"values":{`)
+				for i, column := range row.Columns {
+					if i >= len(values) {
+						break
+					}
+					if i > 0 {
+						buf.WriteByte(',')
+					}
+					if err := writeJSONField(&buf, column, values[i]); err != nil {
+						return err
+					}
+				}
+				buf.WriteString("}}\n")
+				if _, err := bw.Write(buf.Bytes()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// writeJSONLine writes v as JSON followed by a newline.
+func writeJSONLine(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// writeJSONField writes a "key":value pair to buf.
+func writeJSONField(buf *bytes.Buffer, key string, value interface{}) error {
+	k, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+	return nil
+}
+
+func tagsEqual(prev, current map[string]string) bool {
+	return reflect.DeepEqual(prev, current)
+}
+
+func columnsEqual(prev, current []string) bool {
+	return reflect.DeepEqual(prev, current)
+}
+
+func headersEqual(prev, current models.Row) bool {
+	if prev.Name != current.Name {
+		return false
+	}
+	return tagsEqual(prev.Tags, current.Tags) && columnsEqual(prev.Columns, current.Columns)
+}
+
+// seriesKey returns a key identifying the series of row.
+func seriesKey(row models.Row) string {
+	return string(models.MakeKey([]byte(row.Name), models.NewTags(row.Tags)))
+}
+
+// normalizeColumns reorders the columns of each series in result to match the
+// order in which the series was first seen, so a series split across chunks
+// keeps a stable column order. New series are recorded in schemas.
+func normalizeColumns(result client.Result, schemas map[string][]string) client.Result {
+	series := make([]models.Row, len(result.Series))
+	for i, row := range result.Series {
+		key := seriesKey(row)
+		columns, ok := schemas[key]
+		if !ok {
+			schemas[key] = row.Columns
+		} else if !columnsEqual(columns, row.Columns) {
+			if r, ok := reorderColumns(row, columns); ok {
+				row = r
+			}
+		}
+		series[i] = row
+	}
+	result.Series = series
+	return result
+}
+
+// reorderColumns returns a copy of row with its columns and values arranged in
+// the given column order. It returns false if row does not have exactly the
+// same set of columns.
+func reorderColumns(row models.Row, columns []string) (models.Row, bool) {
+	if len(columns) != len(row.Columns) {
+		return row, false
+	}
+	index := make(map[string]int, len(row.Columns))
+	for i, name := range row.Columns {
+		index[name] = i
+	}
+	order := make([]int, len(columns))
+	for i, name := range columns {
+		j, ok := index[name]
+		if !ok {
+			return row, false
+		}
+		order[i] = j
+	}
+
+	values := make([][]interface{}, len(row.Values))
+	for i, v := range row.Values {
+		values[i] = make([]interface{}, len(order))
+		for j, k := range order {
+			if k < len(v) {
+				values[i][j] = v[k]
+			}
+		}
+	}
+	row.Columns = columns
+	row.Values = values
+	return row, true
+}
+
+func (f *Formatter) writeCSV(response *client.Response, w io.Writer, opts FormatOptions) error {
+	csvw := csv.NewWriter(w)
+	var previousHeaders models.Row
+	schemas := make(map[string][]string)
+	for _, result := range response.Results {
+		// Chunked responses may split a series and list its columns in a
+		// different order, so keep the order from the first chunk.
+		result = normalizeColumns(result, schemas)
+
+		suppressHeaders := len(result.Series) > 0 && headersEqual(previousHeaders, result.Series[0])
+		if !suppressHeaders && len(result.Series) > 0 {
+			previousHeaders = models.Row{
+				Name:    result.Series[0].Name,
+				Tags:    result.Series[0].Tags,
+				Columns: result.Series[0].Columns,
+			}
+		}
+
+		// Create a tabbed writer for each result as they won't always line up
+		rows := f.formatResults(result, "\t", suppressHeaders, opts)
+		for _, r := range rows {
+			csvw.Write(strings.Split(r, "\t"))
+		}
+	}
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (f *Formatter) writeColumns(response *client.Response, w io.Writer, opts FormatOptions) error {
+	if opts.Expanded {
+		return f.writeExpanded(response, w, opts)
+	}
+
+	// Create a tabbed writer for each result as they won't always line up
+	writer := new(tabwriter.Writer)
+	writer.Init(w, 0, 8, 1, ' ', 0)
+
+	var previousHeaders models.Row
+	for i, result := range response.Results {
+		// Print out all messages first. They go through the tabwriter so they
+		// are not written ahead of the rows it still buffers.
+		for _, m := range result.Messages {
+			fmt.Fprintf(writer, "%s: %s.\n", m.Level, m.Text)
+		}
+		// Check to see if the headers are the same as the previous row.  If so, suppress them in the output
+		suppressHeaders := len(result.Series) > 0 && headersEqual(previousHeaders, result.Series[0])
+		if !suppressHeaders && len(result.Series) > 0 {
+			previousHeaders = models.Row{
+				Name:    result.Series[0].Name,
+				Tags:    result.Series[0].Tags,
+				Columns: result.Series[0].Columns,
+			}
+		}
+
+		// If we are suppressing headers, don't output the extra line return. If we
+		// aren't suppressing headers, then we put out line returns between results
+		// (not before the first result, and not after the last result).
+		if !suppressHeaders && i > 0 {
+			fmt.Fprintln(writer, "")
+		}
+
+		rows := f.formatResults(result, "\t", suppressHeaders, opts)
+		for _, r := range rows {
+			fmt.Fprintln(writer, r)
+		}
+
+	}
+	return writer.Flush()
+}
+
+// writeExpanded writes every row as a numbered record with a line for each
+// column, below the name and tags of its series.
+func (f *Formatter) writeExpanded(response *client.Response, w io.Writer, opts FormatOptions) error {
+	writer := new(tabwriter.Writer)
+	writer.Init(w, 0, 8, 1, ' ', 0)
+
+	record := 0
+	for _, result := range response.Results {
+		for _, m := range result.Messages {
+			fmt.Fprintf(writer, "%s: %s.\n", m.Level, m.Text)
+		}
+		for _, row := range result.Series {
+			if row.Name != "" {
+				fmt.Fprintf(writer, "name: %s\n", row.Name)
+			}
+			if len(row.Tags) > 0 {
+				tags := make([]string, 0, len(row.Tags))
+				for k, v := range row.Tags {
+					tags = append(tags, fmt.Sprintf("%s=%s", k, v))
+				}
+				sort.Strings(tags)
+				fmt.Fprintf(writer, "tags: %s\n", strings.Join(tags, ", "))
+			}
+			for _, v := range row.Values {
+				record++
+				fmt.Fprintf(writer, "-[ RECORD %d ]-\n", record)
+				for i, col := range row.Columns {
+					var value interface{}
+					if i < len(v) {
+						value = v[i]
+					}
+					if value == nil {
+						fmt.Fprintf(writer, "%s\t| %s\n", col, opts.NullString)
+						continue
+					}
+					if opts.TimeLayout != "" && col == "time" {
+						fmt.Fprintf(writer, "%s\t| %s\n", col, formatTime(value, opts.TimeLayout, opts.Precision))
+						continue
+					}
+					fmt.Fprintf(writer, "%s\t| %s\n", col, valueString(value, opts.FloatFormat))
+				}
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+// markdownEscaper escapes characters that would break a markdown table cell.
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ")
+
+// writeMarkdown writes each series as a GitHub-flavored markdown table below
+// a heading with the measurement name and tags. Consecutive series with the
+// same headers, such as the chunks of a chunked response, share one table.
+func (f *Formatter) writeMarkdown(response *client.Response, w io.Writer, opts FormatOptions) error {
+	bw := bufio.NewWriter(w)
+	var previousHeaders models.Row
+	first := true
+	for _, result := range response.Results {
+		for _, m := range result.Messages {
+			fmt.Fprintf(bw, "%s: %s.\n", m.Level, m.Text)
+		}
+		for _, row := range result.Series {
+			if first || !headersEqual(previousHeaders, row) {
+				if !first {
+					fmt.Fprintln(bw)
+				}
+				first = false
+				previousHeaders = models.Row{Name: row.Name, Tags: row.Tags, Columns: row.Columns}
+
+				heading := row.Name
+				if len(row.Tags) > 0 {
+					tags := make([]string, 0, len(row.Tags))
+					for k, v := range row.Tags {
+						tags = append(tags, fmt.Sprintf("%s=%s", k, v))
+					}
+					sort.Strings(tags)
+					heading = strings.TrimSpace(fmt.Sprintf("%s (%s)", heading, strings.Join(tags, ", ")))
+				}
+				if heading != "" {
+					fmt.Fprintf(bw, "### %s\n\n", markdownEscaper.Replace(heading))
+				}
+
+				columns := make([]string, len(row.Columns))
+				separators := make([]string, len(row.Columns))
+				for i, c := range row.Columns {
+					columns[i] = markdownEscaper.Replace(c)
+					separators[i] = "---"
+				}
+				fmt.Fprintf(bw, "| %s |\n", strings.Join(columns, " | "))
+				fmt.Fprintf(bw, "| %s |\n", strings.Join(separators, " | "))
+			}
+
+			for _, v := range row.Values {
+				values := make([]string, len(v))
+				for i, vv := range v {
+					values[i] = markdownEscaper.Replace(valueString(vv, opts.FloatFormat))
+				}
+				fmt.Fprintf(bw, "| %s |\n", strings.Join(values, " | "))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// promLabelEscaper escapes label values as in the Prometheus text format.
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writePromQL writes each numeric value as a line in the style of the
+// Prometheus text exposition format:
+//
+//	cpu_usage_idle{host="a"} 98.5 1546300800000
+//
+// The metric name is the measurement, followed by the field unless the field
+// is named "value" as in data written by Prometheus remote write. Timestamps
+// are in milliseconds. Non-numeric fields are skipped with a comment line
+// warning about them.
+func (f *Formatter) writePromQL(response *client.Response, w io.Writer, opts FormatOptions) error {
+	bw := bufio.NewWriter(w)
+	for _, result := range response.Results {
+		for _, m := range result.Messages {
+			fmt.Fprintf(bw, "# %s: %s.\n", m.Level, m.Text)
+		}
+		for _, row := range result.Series {
+			keys := make([]string, 0, len(row.Tags))
+			for k := range row.Tags {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			labels := make([]string, len(keys))
+			for i, k := range keys {
+				labels[i] = fmt.Sprintf("%s=\"%s\"", promName(k), promLabelEscaper.Replace(row.Tags[k]))
+			}
+			var labelSet string
+			if len(labels) > 0 {
+				labelSet = "{" + strings.Join(labels, ",") + "}"
+			}
+
+			timeIdx := -1
+			for i, col := range row.Columns {
+				if col == "time" {
+					timeIdx = i
+				}
+			}
+
+			skipped := make(map[string]bool)
+			for _, v := range row.Values {
+				var ts string
+				if timeIdx >= 0 && timeIdx < len(v) {
+					if ms, ok := promTimestamp(v[timeIdx], opts.Precision); ok {
+						ts = " " + strconv.FormatInt(ms, 10)
+					}
+				}
+				for i, col := range row.Columns {
+					if i == timeIdx || i >= len(v) || v[i] == nil {
+						continue
+					}
+					value, ok := promValue(v[i])
+					if !ok {
+						if !skipped[col] {
+							skipped[col] = true
+							fmt.Fprintf(bw, "# WARN skipping non-numeric field %q of %s\n", col, row.Name)
+						}
+						continue
+					}
+					name := row.Name
+					if col != "value" {
+						name += "_" + col
+					}
+					fmt.Fprintf(bw, "%s%s %s%s\n", promName(name), labelSet, value, ts)
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// promName replaces the characters that are not valid in a Prometheus
+// metric or label name with underscores.
+func promName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// promValue returns v formatted as a Prometheus sample value, or false if
+// v is not a number.
+func promValue(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case json.Number:
+		return t.String(), true
+	case float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return interfaceToString(t), true
+	}
+	return "", false
+}
+
+// promTimestamp converts a time column value to milliseconds since the epoch.
+func promTimestamp(v interface{}, precision string) (int64, bool) {
+	switch t := v.(type) {
+	case string:
+		tm, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return 0, false
+		}
+		return tm.UnixNano() / int64(time.Millisecond), true
+	case json.Number:
+		n, err := t.Int64()
+		if err != nil {
+			return 0, false
+		}
+		return n * models.GetPrecisionMultiplier(precision) / int64(time.Millisecond), true
+	}
+	return 0, false
+}
+
+// formatResults will behave differently if you are formatting for columns or csv
+func (f *Formatter) formatResults(result client.Result, separator string, suppressHeaders bool, opts FormatOptions) []string {
+	rows := []string{}
+	// Create a tabbed writer for each result as they won't always line up
+	for i, row := range result.Series {
+		// gather tags
+		tags := []string{}
+		for k, v := range row.Tags {
+			tags = append(tags, fmt.Sprintf("%s=%s", k, v))
+			sort.Strings(tags)
+		}
+
+		columnNames := []string{}
+
+		// Only put name/tags in a column if format is csv
+		if opts.Format == "csv" {
+			if len(tags) > 0 {
+				columnNames = append([]string{"tags"}, columnNames...)
+			}
+
+			if row.Name != "" {
+				columnNames = append([]string{"name"}, columnNames...)
+			}
+		}
+
+		columnNames = append(columnNames, row.Columns...)
+
+		// Output a line separator if we have more than one set or results and format is column
+		if i > 0 && opts.Format == "column" && !suppressHeaders {
+			rows = append(rows, "")
+		}
+
+		// If we are column format, we break out the name/tag to separate lines
+		if opts.Format == "column" && !suppressHeaders {
+			if row.Name != "" {
+				n := fmt.Sprintf("name: %s", row.Name)
+				rows = append(rows, n)
+			}
+			if len(tags) > 0 {
+				t := fmt.Sprintf("tags: %s", (strings.Join(tags, ", ")))
+				rows = append(rows, t)
+			}
+		}
+
+		if !suppressHeaders {
+			rows = append(rows, strings.Join(columnNames, separator))
+		}
+
+		// if format is column, write dashes under each column
+		if opts.Format == "column" && !suppressHeaders {
+			lines := []string{}
+			for _, columnName := range columnNames {
+				lines = append(lines, strings.Repeat("-", len(columnName)))
+			}
+			rows = append(rows, strings.Join(lines, separator))
+		}
+
+		for _, v := range row.Values {
+			var values []string
+			if opts.Format == "csv" {
+				if row.Name != "" {
+					values = append(values, row.Name)
+				}
+				if len(tags) > 0 {
+					values = append(values, strings.Join(tags, ","))
+				}
+			}
+
+			for j, vv := range v {
+				if vv == nil {
+					values = append(values, opts.NullString)
+					continue
+				}
+				if opts.TimeLayout != "" && j < len(row.Columns) && row.Columns[j] == "time" {
+					values = append(values, formatTime(vv, opts.TimeLayout, opts.Precision))
+					continue
+				}
+				values = append(values, valueString(vv, opts.FloatFormat))
+			}
+			rows = append(rows, strings.Join(values, separator))
+		}
+	}
+	return rows
+}
+
+func interfaceToString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case json.Number:
+		// Keep the literal from the response so large integers are not
+		// rounded through a float64.
+		return t.String()
+	case bool:
+		return fmt.Sprintf("%v", v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		return fmt.Sprintf("%d", t)
+	case float32, float64:
+		return fmt.Sprintf("%v", t)
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
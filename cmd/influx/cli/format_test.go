@@ -0,0 +1,384 @@
+package cli_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxdb/cmd/influx/cli"
+	"github.com/influxdata/influxdb/models"
+)
+
+func TestFormatter_SuppressHeaders(t *testing.T) {
+	row := models.Row{
+		Name:    "cpu",
+		Columns: []string{"time", "value"},
+		Values:  [][]interface{}{{"1970-01-01T00:00:00Z", 1}},
+	}
+	response := &client.Response{
+		Results: []client.Result{
+			{Series: []models.Row{row}},
+			{Series: []models.Row{row}},
+		},
+	}
+
+	tests := []struct {
+		format string
+		exp    string
+	}{
+		{
+			format: "column",
+			exp: "name: cpu\n" +
+				"time                 value\n" +
+				"----                 -----\n" +
+				"1970-01-01T00:00:00Z 1\n" +
+				"1970-01-01T00:00:00Z 1\n",
+		},
+		{
+			format: "csv",
+			exp: "name,time,value\n" +
+				"cpu,1970-01-01T00:00:00Z,1\n" +
+				"cpu,1970-01-01T00:00:00Z,1\n",
+		},
+		{
+			format: "json",
+			exp:    `{"results":[{"series":[{"name":"cpu","columns":["time","value"],"values":[["1970-01-01T00:00:00Z",1]]}]},{"series":[{"name":"cpu","columns":["time","value"],"values":[["1970-01-01T00:00:00Z",1]]}]}]}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var f cli.Formatter
+		var buf bytes.Buffer
+		if err := f.Format(response, &buf, cli.FormatOptions{Format: tt.format}); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.format, err)
+		}
+		if got := buf.String(); got != tt.exp {
+			t.Errorf("%s: unexpected output:\ngot:\n%s\nexp:\n%s", tt.format, got, tt.exp)
+		}
+	}
+}
+
+func TestFormatter_Expanded(t *testing.T) {
+	response := &client.Response{Results: []client.Result{{Series: []models.Row{{
+		Name:    "cpu",
+		Tags:    map[string]string{"host": "a"},
+		Columns: []string{"time", "usage_idle"},
+		Values:  [][]interface{}{{"1970-01-01T00:00:00Z", 1}, {"1970-01-01T00:00:10Z", nil}},
+	}}}}}
+
+	var f cli.Formatter
+	var buf bytes.Buffer
+	if err := f.Format(response, &buf, cli.FormatOptions{Format: "column", Expanded: true}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	exp := "name: cpu\n" +
+		"tags: host=a\n" +
+		"-[ RECORD 1 ]-\n" +
+		"time       | 1970-01-01T00:00:00Z\n" +
+		"usage_idle | 1\n" +
+		"-[ RECORD 2 ]-\n" +
+		"time       | 1970-01-01T00:00:10Z\n" +
+		"usage_idle | \n"
+	if got := buf.String(); got != exp {
+		t.Errorf("unexpected output:\ngot:\n%s\nexp:\n%s", got, exp)
+	}
+}
+
+func TestFormatter_TimeLayout(t *testing.T) {
+	row := models.Row{Name: "cpu", Columns: []string{"time", "value"}}
+	rfc3339, epoch := row, row
+	rfc3339.Values = [][]interface{}{{"2000-01-02T03:04:05.5Z", 1}}
+	epoch.Values = [][]interface{}{{json.Number("946782245"), 1}}
+
+	for _, tt := range []struct {
+		format    string
+		precision string
+		row       models.Row
+		exp       string
+	}{
+		{
+			format: "column",
+			row:    rfc3339,
+			exp:    "name: cpu\ntime                  value\n----                  -----\n2000-01-02 03:04:05.5 1\n",
+		},
+		{
+			format:    "csv",
+			precision: "s",
+			row:       epoch,
+			exp:       "name,time,value\ncpu,2000-01-02 03:04:05,1\n",
+		},
+	} {
+		var f cli.Formatter
+		var buf bytes.Buffer
+		response := &client.Response{Results: []client.Result{{Series: []models.Row{tt.row}}}}
+		opts := cli.FormatOptions{Format: tt.format, Precision: tt.precision, TimeLayout: "2006-01-02 15:04:05.999"}
+		if err := f.Format(response, &buf, opts); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.format, err)
+		}
+		if got := buf.String(); got != tt.exp {
+			t.Errorf("%s: unexpected output:\ngot:\n%s\nexp:\n%s", tt.format, got, tt.exp)
+		}
+	}
+}
+
+func TestFormatter_NullString(t *testing.T) {
+	row := models.Row{Name: "cpu", Columns: []string{"time", "host", "value"}, Values: [][]interface{}{{"2000-01-02T03:04:05Z", "", nil}}}
+	for _, tt := range []struct {
+		format   string
+		expanded bool
+		exp      string
+	}{
+		{format: "csv", exp: "name,time,host,value\ncpu,2000-01-02T03:04:05Z,,\\N\n"},
+		{format: "column", exp: "name: cpu\ntime                 host value\n----                 ---- -----\n2000-01-02T03:04:05Z      \\N\n"},
+		{format: "column", expanded: true, exp: "name: cpu\n-[ RECORD 1 ]-\ntime  | 2000-01-02T03:04:05Z\nhost  | \nvalue | \\N\n"},
+		{format: "json", exp: `{"results":[{"series":[{"name":"cpu","columns":["time","host","value"],"values":[["2000-01-02T03:04:05Z","",null]]}]}]}` + "\n"},
+	} {
+		var f cli.Formatter
+		var buf bytes.Buffer
+		response := &client.Response{Results: []client.Result{{Series: []models.Row{row}}}}
+		opts := cli.FormatOptions{Format: tt.format, Expanded: tt.expanded, NullString: `\N`}
+		if err := f.Format(response, &buf, opts); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.format, err)
+		}
+		if got := buf.String(); got != tt.exp {
+			t.Errorf("%s: unexpected output:\ngot:\n%q\nexp:\n%q", tt.format, got, tt.exp)
+		}
+	}
+}
+
+func TestFormatter_FloatFormat(t *testing.T) {
+	row := models.Row{Name: "cpu", Columns: []string{"time", "count", "value"}, Values: [][]interface{}{
+		{json.Number("0"), json.Number("12"), json.Number("1.23456789e-05")},
+		{json.Number("1"), json.Number("7"), float64(2.5)},
+	}}
+	for _, tt := range []struct {
+		format string
+		exp    string
+	}{
+		{format: "csv", exp: "name,time,count,value\ncpu,0,12,0.000\ncpu,1,7,2.500\n"},
+		{format: "markdown", exp: "### cpu\n\n| time | count | value |\n| --- | --- | --- |\n| 0 | 12 | 0.000 |\n| 1 | 7 | 2.500 |\n"},
+		{format: "json", exp: `{"results":[{"series":[{"name":"cpu","columns":["time","count","value"],"values":[[0,12,1.23456789e-05],[1,7,2.5]]}]}]}` + "\n"},
+	} {
+		var f cli.Formatter
+		var buf bytes.Buffer
+		response := &client.Response{Results: []client.Result{{Series: []models.Row{row}}}}
+		if err := f.Format(response, &buf, cli.FormatOptions{Format: tt.format, FloatFormat: "%.3f"}); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.format, err)
+		}
+		if got := buf.String(); got != tt.exp {
+			t.Errorf("%s: unexpected output:\ngot:\n%q\nexp:\n%q", tt.format, got, tt.exp)
+		}
+	}
+}
+
+func TestFormatter_UnknownFormat(t *testing.T) {
+	var f cli.Formatter
+	var buf bytes.Buffer
+	if err := f.Format(&client.Response{}, &buf, cli.FormatOptions{Format: "xml"}); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+
+	// The CLI prints the message it always has, without an error prefix.
+	c := cli.CommandLine{Format: "xml"}
+	c.FormatResponse(&client.Response{}, &buf)
+	if got, exp := buf.String(), "Unknown output format \"xml\".\n"; got != exp {
+		t.Fatalf("unexpected output:\ngot: %q\nexp: %q", got, exp)
+	}
+}
+
+func TestFormatter_CSVChunkedColumnOrder(t *testing.T) {
+	tags := map[string]string{"host": "serverA"}
+	response := &client.Response{
+		Results: []client.Result{
+			{Series: []models.Row{{
+				Name:    "cpu",
+				Tags:    tags,
+				Columns: []string{"time", "value", "idle"},
+				Values:  [][]interface{}{{"1970-01-01T00:00:00Z", 1, 10}},
+			}}},
+			{Series: []models.Row{{
+				Name:    "cpu",
+				Tags:    tags,
+				Columns: []string{"idle", "time", "value"},
+				Values:  [][]interface{}{{20, "1970-01-01T00:00:01Z", 2}},
+			}}},
+		},
+	}
+
+	var f cli.Formatter
+	var buf bytes.Buffer
+	if err := f.Format(response, &buf, cli.FormatOptions{Format: "csv"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := "name,tags,time,value,idle\n" +
+		"cpu,host=serverA,1970-01-01T00:00:00Z,1,10\n" +
+		"cpu,host=serverA,1970-01-01T00:00:01Z,2,20\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("unexpected output:\ngot:\n%s\nexp:\n%s", got, exp)
+	}
+}
+
+func TestFormatter_PreservesIntegers(t *testing.T) {
+	// 9007199254740993 is 2^53 + 1 and cannot be represented by a float64.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"results":[{"series":[{"name":"cpu","columns":["time","value","ratio"],"values":[[0,9007199254740993,0.5]]}]}]}`)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c, err := client.NewClient(client.Config{URL: *u})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, chunked := range []bool{false, true} {
+		response, err := c.Query(client.Query{Command: "SELECT * FROM cpu", Chunked: chunked})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		for _, format := range []string{"json", "ndjson", "csv", "column", "markdown"} {
+			var f cli.Formatter
+			var buf bytes.Buffer
+			if err := f.Format(response, &buf, cli.FormatOptions{Format: format}); err != nil {
+				t.Fatalf("%s: unexpected error: %s", format, err)
+			}
+			if !strings.Contains(buf.String(), "9007199254740993") {
+				t.Errorf("%s (chunked %v): integer lost precision:\n%s", format, chunked, buf.String())
+			}
+			if !strings.Contains(buf.String(), "0.5") {
+				t.Errorf("%s (chunked %v): float not preserved:\n%s", format, chunked, buf.String())
+			}
+		}
+	}
+}
+
+func TestFormatter_NDJSON(t *testing.T) {
+	response := &client.Response{Results: []client.Result{
+		{Series: []models.Row{{
+			Name:    "cpu",
+			Tags:    map[string]string{"host": "a"},
+			Columns: []string{"time", "value"},
+			Values:  [][]interface{}{{"2000-01-01T00:00:00Z", 1}, {"2000-01-01T00:00:10Z", 2}},
+		}}},
+		{Series: []models.Row{{
+			Name:    "mem",
+			Columns: []string{"value", "time"},
+			Values:  [][]interface{}{{3, "2000-01-01T00:00:00Z"}},
+		}}},
+		{Err: errors.New("database not found")},
+	}}
+
+	var f cli.Formatter
+	var buf bytes.Buffer
+	if err := f.Format(response, &buf, cli.FormatOptions{Format: "ndjson"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := `{"name":"cpu","tags":{"host":"a"},"values":{"time":"2000-01-01T00:00:00Z","value":1}}
+{"name":"cpu","tags":{"host":"a"},"values":{"time":"2000-01-01T00:00:10Z","value":2}}
+{"name":"mem","values":{"value":3,"time":"2000-01-01T00:00:00Z"}}
+{"error":"database not found"}
+`
+	if got := buf.String(); got != exp {
+		t.Errorf("unexpected output:\ngot:\n%s\nexp:\n%s", got, exp)
+	}
+}
+
+func TestFormatter_Markdown(t *testing.T) {
+	row := models.Row{
+		Name:    "cpu",
+		Tags:    map[string]string{"host": "a"},
+		Columns: []string{"time", "value"},
+	}
+	first, second := row, row
+	first.Values = [][]interface{}{{"2000-01-01T00:00:00Z", "a|b"}}
+	second.Values = [][]interface{}{{"2000-01-01T00:00:10Z", 2}}
+	mem := models.Row{Name: "mem", Columns: []string{"value"}, Values: [][]interface{}{{3}}}
+	response := &client.Response{Results: []client.Result{
+		{Series: []models.Row{first}},
+		{Series: []models.Row{second, mem}},
+	}}
+
+	var f cli.Formatter
+	var buf bytes.Buffer
+	if err := f.Format(response, &buf, cli.FormatOptions{Format: "markdown"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := `### cpu (host=a)
+
+| time | value |
+| --- | --- |
+| 2000-01-01T00:00:00Z | a\|b |
+| 2000-01-01T00:00:10Z | 2 |
+
+### mem
+
+| value |
+| --- |
+| 3 |
+`
+	if got := buf.String(); got != exp {
+		t.Errorf("unexpected output:\ngot:\n%s\nexp:\n%s", got, exp)
+	}
+}
+
+func TestFormatter_PromQLStyle(t *testing.T) {
+	response := &client.Response{Results: []client.Result{{
+		Series: []models.Row{
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "a", "region": `us"west`},
+				Columns: []string{"time", "usage_idle", "state"},
+				Values: [][]interface{}{
+					{json.Number("1546300800"), json.Number("98.5"), "ok"},
+					{json.Number("1546300810"), json.Number("97"), "ok"},
+				},
+			},
+			{
+				Name:    "http-requests",
+				Columns: []string{"time", "value"},
+				Values:  [][]interface{}{{json.Number("1546300800"), json.Number("3")}},
+			},
+		},
+	}}}
+
+	var f cli.Formatter
+	var buf bytes.Buffer
+	if err := f.Format(response, &buf, cli.FormatOptions{Format: "promql-style", Precision: "s"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := `cpu_usage_idle{host="a",region="us\"west"} 98.5 1546300800000
+# WARN skipping non-numeric field "state" of cpu
+cpu_usage_idle{host="a",region="us\"west"} 97 1546300810000
+http_requests 3 1546300800000
+`
+	if got := buf.String(); got != exp {
+		t.Errorf("unexpected output:\ngot:\n%s\nexp:\n%s", got, exp)
+	}
+
+	buf.Reset()
+	rfc := &client.Response{Results: []client.Result{{
+		Series: []models.Row{{
+			Name:    "mem",
+			Columns: []string{"time", "free"},
+			Values:  [][]interface{}{{"2019-01-01T00:00:00.5Z", json.Number("10")}},
+		}},
+	}}}
+	if err := f.Format(rfc, &buf, cli.FormatOptions{Format: "promql-style"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, exp := buf.String(), "mem_free 10 1546300800500\n"; got != exp {
+		t.Errorf("unexpected output: got %q, expected %q", got, exp)
+	}
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxql"
+)
+
+// isGlob reports whether s contains any filepath.Match metacharacters.
+func isGlob(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+// matchNames returns the names matching pattern, using filepath.Match
+// semantics.
+func matchNames(names []string, pattern string) ([]string, error) {
+	var matched []string
+	for _, name := range names {
+		ok, err := filepath.Match(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, name)
+		}
+	}
+	return matched, nil
+}
+
+// measurements returns the measurement names in the current database.
+func (c *CommandLine) measurements(ctx context.Context) ([]string, error) {
+	response, err := c.Client.QueryContext(ctx, c.query("SHOW MEASUREMENTS"))
+	if err != nil {
+		return nil, err
+	}
+	if err := response.Error(); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, v := range row.Values {
+				if len(v) > 0 {
+					names = append(names, fmt.Sprint(v[0]))
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// expandMeasurements returns the measurements matching pattern. A pattern
+// without glob metacharacters is returned as is, without a query.
+func (c *CommandLine) expandMeasurements(ctx context.Context, pattern string) ([]string, error) {
+	if !isGlob(pattern) {
+		return []string{pattern}, nil
+	}
+	names, err := c.measurements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return matchNames(names, pattern)
+}
+
+// count handles "count <measurement>", printing the number of points in
+// each measurement matching the name, which may be a glob pattern.
+func (c *CommandLine) count(cmd string) error {
+	pattern := strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("count"):]), ";")
+	if pattern == "" {
+		fmt.Println("Usage: count <measurement|pattern>")
+		return nil
+	}
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	names, err := c.expandMeasurements(ctx, pattern)
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Println("no measurements matched")
+		return nil
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(c.output(), 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "measurement\tcount")
+	fmt.Fprintln(w, "-----------\t-----")
+	for _, name := range names {
+		response, err := c.Client.QueryContext(ctx, c.query("SELECT COUNT(*) FROM "+influxql.QuoteIdent(name)))
+		if err == nil {
+			err = response.Error()
+		}
+		if err != nil {
+			w.Flush()
+			fmt.Printf("%s %s: %s\n", c.errPrefix(), name, err)
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%d\n", name, pointCount(response))
+	}
+	return w.Flush()
+}
+
+// pointCount returns the number of points counted by a SELECT COUNT(*)
+// response. COUNT(*) counts each field separately, so this is the largest
+// of the per-field counts, which is exact when one field is in every point.
+func pointCount(response *client.Response) int64 {
+	var max int64
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, v := range row.Values {
+				for i, col := range row.Columns {
+					if col == "time" || i >= len(v) {
+						continue
+					}
+					var n int64
+					switch x := v[i].(type) {
+					case json.Number:
+						n, _ = x.Int64()
+					case float64:
+						n = int64(x)
+					case int64:
+						n = x
+					}
+					if n > max {
+						max = n
+					}
+				}
+			}
+		}
+	}
+	return max
+}
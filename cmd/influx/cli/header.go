@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// forbiddenHeaders are set by the HTTP client itself from the request and
+// cannot be overridden with a custom header.
+var forbiddenHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// AddHeader adds a header given as "Key: Value" to every request sent to
+// the server. A header added more than once is sent with each value.
+func (c *CommandLine) AddHeader(s string) error {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return fmt.Errorf("invalid header %q, expected Key: Value", s)
+	}
+	return c.setHeader(strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), false)
+}
+
+// setHeader validates and sets a header sent with every request, updating
+// the client if it is already connected. With replace set, value replaces
+// the values the header already has rather than being added to them.
+func (c *CommandLine) setHeader(name, value string, replace bool) error {
+	if !validHeaderName(name) {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("invalid value for header %s", name)
+	}
+	name = http.CanonicalHeaderKey(name)
+	if forbiddenHeaders[name] {
+		return fmt.Errorf("header %s cannot be set", name)
+	}
+	if c.ClientConfig.Headers == nil {
+		c.ClientConfig.Headers = http.Header{}
+	}
+	if replace {
+		c.ClientConfig.Headers.Set(name, value)
+	} else {
+		c.ClientConfig.Headers.Add(name, value)
+	}
+	c.updateClientHeaders()
+	return nil
+}
+
+// updateClientHeaders passes the configured headers to the connected client.
+func (c *CommandLine) updateClientHeaders() {
+	if c.Client != nil {
+		c.Client.SetHeaders(c.ClientConfig.Headers)
+	}
+}
+
+// header handles "header set <key> <value>", setting a header sent with
+// every request, "header clear [key]", removing one or all of them, and
+// "header", listing them.
+func (c *CommandLine) header(cmd string) {
+	args := strings.Fields(strings.TrimSuffix(strings.TrimSpace(cmd), ";"))[1:]
+	switch {
+	case len(args) == 0:
+		if len(c.ClientConfig.Headers) == 0 {
+			fmt.Println("no headers are set")
+			return
+		}
+		for _, h := range headerStrings(c.ClientConfig.Headers) {
+			fmt.Println(h)
+		}
+	case strings.EqualFold(args[0], "set") && len(args) >= 3:
+		// The value is the rest of the line, so it may contain spaces.
+		rest := strings.TrimSpace(strings.TrimSpace(cmd)[len("header"):])
+		rest = strings.TrimSpace(rest[len("set"):])
+		rest = strings.TrimSpace(rest[len(args[1]):])
+		if err := c.setHeader(args[1], strings.TrimSuffix(rest, ";"), true); err != nil {
+			fmt.Printf("%s %s\n", c.errPrefix(), err)
+		}
+	case strings.EqualFold(args[0], "clear") && len(args) <= 2:
+		if len(args) == 2 {
+			c.ClientConfig.Headers.Del(args[1])
+		} else {
+			c.ClientConfig.Headers = nil
+		}
+		c.updateClientHeaders()
+	default:
+		fmt.Println("Usage: header [set <key> <value> | clear [key]]")
+	}
+}
+
+// headerStrings returns the headers as sorted "Key: Value" lines, with the
+// values of sensitive headers masked.
+func headerStrings(h http.Header) []string {
+	var lines []string
+	for name, values := range h {
+		for _, v := range values {
+			if sensitiveHeader(name) {
+				v = maskToken(v)
+			}
+			lines = append(lines, name+": "+v)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// sensitiveHeader reports whether the value of the header named name likely
+// holds a credential.
+func sensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"authorization", "token", "key", "secret", "password", "cookie"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// validHeaderName reports whether name is a valid HTTP header field name.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"errors"
+	"time"
+)
+
+// errIdleTimeout is returned by readLine when no command is entered within
+// IdleTimeout.
+var errIdleTimeout = errors.New("idle timeout")
+
+// readLine prompts for the next command. With IdleTimeout set, it returns
+// errIdleTimeout once no command has been entered for that long. The prompt
+// is then still waiting for input, so the shell must exit. A running command
+// is never timed out, as the timer only runs while prompting.
+func (c *CommandLine) readLine() (string, error) {
+	if c.IdleTimeout <= 0 {
+		return c.Line.Prompt(c.prompt())
+	}
+
+	type result struct {
+		line string
+		err  error
+	}
+	ch := make(chan result, 1)
+	line, prompt := c.Line, c.prompt()
+	go func() {
+		l, err := line.Prompt(prompt)
+		ch <- result{line: l, err: err}
+	}()
+
+	timer := time.NewTimer(c.IdleTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.line, r.err
+	case <-timer.C:
+		return "", errIdleTimeout
+	}
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// keepAlive is a running keepalive goroutine.
+type keepAlive struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// setKeepAlive handles "keepalive <duration>" and "keepalive off". While
+// enabled, the server is pinged at the given interval so idle connections are
+// not dropped by load balancers, and a failed ping makes the shell reconnect
+// before the next command.
+func (c *CommandLine) setKeepAlive(cmd string) {
+	arg := strings.TrimSpace(strings.TrimSpace(cmd)[len("keepalive"):])
+	switch {
+	case arg == "":
+		if c.KeepAlive <= 0 {
+			fmt.Println("keepalive is off")
+		} else {
+			fmt.Printf("keepalive is %s\n", c.KeepAlive)
+		}
+		return
+	case strings.EqualFold(arg, "off"):
+		c.KeepAlive = 0
+	default:
+		d, err := time.ParseDuration(arg)
+		if err != nil || d < 0 {
+			fmt.Printf("Invalid keepalive interval %q. Please use a duration such as 30s, or off.\n", arg)
+			return
+		}
+		c.KeepAlive = d
+	}
+	if c.Line != nil {
+		c.startKeepAlive()
+	}
+}
+
+// startKeepAlive (re)starts the keepalive goroutine with the current
+// interval. Nothing is started when keepalive is off.
+func (c *CommandLine) startKeepAlive() {
+	c.stopKeepAlive()
+	if c.KeepAlive <= 0 {
+		return
+	}
+
+	ka := &keepAlive{stop: make(chan struct{}), done: make(chan struct{})}
+	c.keepAlive = ka
+	interval := c.KeepAlive
+	go func() {
+		defer close(ka.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ka.stop:
+				return
+			case <-ticker.C:
+			}
+			// A running command already keeps the connection busy.
+			if atomic.LoadInt32(&c.busy) == 1 {
+				continue
+			}
+			if cl := c.currentClient(); cl != nil {
+				if _, _, err := cl.Ping(); err != nil {
+					atomic.StoreInt32(&c.reconnect, 1)
+				}
+			}
+		}
+	}()
+}
+
+// stopKeepAlive stops the keepalive goroutine and waits for it to return.
+func (c *CommandLine) stopKeepAlive() {
+	if c.keepAlive == nil {
+		return
+	}
+	close(c.keepAlive.stop)
+	<-c.keepAlive.done
+	c.keepAlive = nil
+}
+
+// reconnectIfNeeded reconnects when a keepalive ping failed since the last
+// command. With Hosts set, the hosts after the one that failed are tried
+// first.
+func (c *CommandLine) reconnectIfNeeded() {
+	if !atomic.CompareAndSwapInt32(&c.reconnect, 1, 0) {
+		return
+	}
+	if err := c.connectHosts(c.activeHost + 1); err != nil {
+		fmt.Printf("WARN: keepalive ping failed and reconnecting did not succeed: %s\n", err)
+	}
+}
+
+// currentClient returns the client, which Connect may replace.
+func (c *CommandLine) currentClient() *client.Client {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+	return c.Client
+}
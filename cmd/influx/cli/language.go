@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/repl"
+)
+
+// fluxModeCommands are the CLI commands still recognized after switching to
+// Flux with "language flux". Every other line is Flux.
+var fluxModeCommands = map[string]bool{
+	"exit":     true,
+	"quit":     true,
+	"help":     true,
+	"language": true,
+	"settings": true,
+}
+
+// fluxSession is the Flux REPL of a shell switched to Flux, which keeps the
+// variables defined by earlier lines. The queries of a line run with the
+// context of that line, so that each can be interrupted or time out.
+type fluxSession struct {
+	repl    *repl.REPL
+	querier *replQuerier
+	ctx     context.Context
+}
+
+func newFluxSession(c *CommandLine) (*fluxSession, error) {
+	q, err := newReplQuerier(c.URL, c.ClientConfig.Username, c.ClientConfig.Password)
+	if err != nil {
+		return nil, err
+	}
+	s := &fluxSession{querier: q, ctx: context.Background()}
+	s.repl = repl.New(context.Background(), flux.NewDefaultDependencies(), s)
+	return s, nil
+}
+
+// Query runs a query of the REPL with the context of the current line.
+func (s *fluxSession) Query(_ context.Context, deps flux.Dependencies, compiler flux.Compiler) (flux.ResultIterator, error) {
+	return s.querier.Query(s.ctx, deps, compiler)
+}
+
+// setLanguage handles "language [flux|influxql]", switching the language of
+// the lines entered in the shell. The Flux REPL is started by the first Flux
+// line, and stopped when switching back to InfluxQL.
+func (c *CommandLine) setLanguage(cmd string) error {
+	arg := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("language"):]), ";"))
+	if arg == "" {
+		fmt.Printf("query language: %s\n", c.Type.String())
+		return nil
+	}
+	var lang QueryLanguage
+	if err := lang.Set(arg); err != nil {
+		fmt.Printf("Unknown language %q. Please use flux or influxql.\n", arg)
+		return nil
+	}
+	c.Type = lang
+	if lang == QueryLanguageInfluxQL {
+		c.fluxSession = nil
+	}
+	fmt.Printf("query language set to %s\n", lang.String())
+	return nil
+}
+
+// executeFluxLine runs a line of Flux in the session started by the first
+// one.
+func (c *CommandLine) executeFluxLine(line string) error {
+	if c.fluxSession == nil {
+		s, err := newFluxSession(c)
+		if err != nil {
+			fmt.Printf("%s %s\n", c.errPrefix(), err)
+			return err
+		}
+		c.fluxSession = s
+	}
+
+	ctx, cancel := c.queryContext(context.Background())
+	defer cancel()
+	c.fluxSession.ctx = ctx
+	if err := c.fluxSession.repl.Input(strings.TrimSpace(line)); err != nil {
+		err = c.queryContextErr(ctx, err)
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/influxql"
+)
+
+// metaCommandHelp lists the backslash metacommands.
+const metaCommandHelp = `Metacommands:
+        \d                    lists the measurements of the current database
+        \dt [measurement]     lists the tag keys, of one measurement if given
+        \c <db>               uses a database, like use
+        \x                    toggles expanded output of the column format
+        \q                    quits the influx shell
+        \?                    shows this list`
+
+// metaCommand handles the psql-style backslash commands, which are shorthand
+// for existing commands and queries.
+func (c *CommandLine) metaCommand(cmd string) error {
+	args := strings.Fields(strings.TrimSuffix(strings.TrimSpace(cmd), ";"))
+	switch strings.ToLower(args[0]) {
+	case `\d`:
+		return c.ExecuteQuery("SHOW MEASUREMENTS")
+	case `\dt`:
+		if len(args) > 1 {
+			return c.ExecuteQuery("SHOW TAG KEYS FROM " + influxql.QuoteIdent(args[1]))
+		}
+		return c.ExecuteQuery("SHOW TAG KEYS")
+	case `\c`:
+		c.use("use " + strings.Join(args[1:], " "))
+	case `\x`:
+		c.Expanded = !c.Expanded
+		if c.Expanded {
+			fmt.Println("Expanded display is on")
+		} else {
+			fmt.Println("Expanded display is off")
+		}
+	case `\q`:
+		close(c.Quit)
+	case `\?`:
+		fmt.Println(metaCommandHelp)
+	default:
+		fmt.Printf("Unknown metacommand %q.\n%s\n", args[0], metaCommandHelp)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// netrcPath returns the location of the netrc file. $NETRC overrides the
+// default of ~/.netrc.
+func netrcPath() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, ".netrc")
+	}
+	if u, err := user.Current(); err == nil {
+		return filepath.Join(u.HomeDir, ".netrc")
+	}
+	return ""
+}
+
+// readNetrc returns the login and password for host from the netrc file at
+// path. A missing file is not an error.
+func readNetrc(path, host string) (login, password string, ok bool, err error) {
+	if path == "" {
+		return "", "", false, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", "", false, nil
+	} else if err != nil {
+		return "", "", false, err
+	}
+	defer f.Close()
+	return parseNetrc(f, host)
+}
+
+// parseNetrc returns the login and password of the machine entry matching
+// host, falling back to the default entry if there is one.
+func parseNetrc(r io.Reader, host string) (login, password string, ok bool, err error) {
+	type entry struct {
+		login, password string
+	}
+	var (
+		current     *entry
+		match, def  *entry
+		inMacro     bool
+		lineNo      int
+		expectValue string
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+
+		// Macro definitions run until the next blank line.
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			tok := fields[i]
+			if expectValue != "" {
+				switch expectValue {
+				case "machine":
+					current = &entry{}
+					if tok == host && match == nil {
+						match = current
+					}
+				case "login":
+					current.login = tok
+				case "password":
+					current.password = tok
+				}
+				expectValue = ""
+				continue
+			}
+
+			switch tok {
+			case "machine":
+				expectValue = tok
+			case "default":
+				current = &entry{}
+				if def == nil {
+					def = current
+				}
+			case "login", "password", "account":
+				if current == nil {
+					return "", "", false, fmt.Errorf("line %d: %q outside of a machine entry", lineNo, tok)
+				}
+				expectValue = tok
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			default:
+				// The token is not included since it may be a password.
+				return "", "", false, fmt.Errorf("line %d: unexpected token", lineNo)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", false, err
+	}
+	if expectValue != "" {
+		return "", "", false, fmt.Errorf("missing value for %q", expectValue)
+	}
+
+	if match == nil {
+		match = def
+	}
+	if match == nil {
+		return "", "", false, nil
+	}
+	return match.login, match.password, true, nil
+}
@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// setNullString handles "null-string <token>", which prints null values as
+// token in the column and csv formats so they can be told apart from empty
+// strings, and "null-string clear", which prints them as empty again. The
+// json formats keep printing JSON null.
+func (c *CommandLine) setNullString(cmd string) {
+	token := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("null-string"):]), ";"))
+	switch {
+	case token == "":
+		if c.NullString == "" {
+			fmt.Println("null-string is not set")
+		} else {
+			fmt.Printf("null-string is %s\n", c.NullString)
+		}
+	case strings.EqualFold(token, "clear"):
+		c.NullString = ""
+	default:
+		c.NullString = token
+	}
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// formatTarget is a writer query results are formatted to and its format.
+// path is empty for the shell's own output.
+type formatTarget struct {
+	format string
+	path   string
+	w      io.Writer
+}
+
+// outputFile is a file, or an S3 object, receiving the query results in its
+// own format, in addition to the results printed in the shell's format.
+type outputFile struct {
+	format string
+	path   string
+	dst    io.WriteCloser
+	w      *bufio.Writer
+}
+
+// close flushes buffered output and closes the file. An S3 object is only
+// created once it is closed.
+func (o *outputFile) close() error {
+	err := o.w.Flush()
+	if cerr := o.dst.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// formatTargets returns the writers a response is formatted to, in order: w
+// in the shell's format first, then every output file in the order they were
+// added.
+func (c *CommandLine) formatTargets(w io.Writer) []formatTarget {
+	targets := []formatTarget{{format: c.Format, w: w}}
+	for _, o := range c.outputFiles {
+		targets = append(targets, formatTarget{format: o.format, path: o.path, w: o.w})
+	}
+	return targets
+}
+
+// setOutput handles the output command. "output <format> <path>" also writes
+// the results of every query to the file at path, truncating it, in the given
+// format. A path of s3://bucket/key uploads the results to S3 instead, once
+// the output is closed. "output off" closes every output file, and "output"
+// lists them.
+func (c *CommandLine) setOutput(cmd string) error {
+	args := strings.Fields(strings.TrimSuffix(strings.TrimSpace(cmd)[len("output"):], ";"))
+	switch {
+	case len(args) == 0:
+		if len(c.outputFiles) == 0 {
+			fmt.Println("no output files")
+		}
+		for _, o := range c.outputFiles {
+			fmt.Printf("%s\t%s\n", o.format, o.path)
+		}
+		return nil
+	case len(args) == 1 && strings.EqualFold(args[0], "off"):
+		for _, o := range c.outputFiles {
+			fmt.Printf("stopped writing %s to %s\n", o.format, o.path)
+		}
+		return c.closeOutputFiles()
+	case len(args) != 2:
+		fmt.Println("Usage: output <format> <path>, or output off")
+		return nil
+	}
+
+	format, path := strings.ToLower(args[0]), strings.Trim(args[1], `"'`)
+	if !isFormat(format) {
+		fmt.Printf("Unknown format %q. Please use json, ndjson, csv, column, markdown, or promql-style.\n", format)
+		return nil
+	}
+	for _, o := range c.outputFiles {
+		if o.path == path {
+			return fmt.Errorf("already writing %s to %s", o.format, path)
+		}
+	}
+	dst, err := c.createOutput(path)
+	if err != nil {
+		return err
+	}
+	c.outputFiles = append(c.outputFiles, &outputFile{format: format, path: path, dst: dst, w: bufio.NewWriter(dst)})
+	fmt.Printf("writing %s output to %s\n", format, path)
+	return nil
+}
+
+// createOutput creates the file at path, or starts the upload of an
+// s3://bucket/key object.
+func (c *CommandLine) createOutput(path string) (io.WriteCloser, error) {
+	bucket, key, ok, err := parseS3URL(path)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return os.Create(path)
+	}
+	config, err := s3ConfigFromEnv(c.S3Endpoint, c.S3Region)
+	if err != nil {
+		return nil, err
+	}
+	return newS3Writer(config, bucket, key)
+}
+
+// closeOutputFiles flushes and closes every output file, returning the first
+// error. The size of the objects uploaded to S3 is printed.
+func (c *CommandLine) closeOutputFiles() error {
+	var err error
+	for _, o := range c.outputFiles {
+		cerr := o.close()
+		if s3, ok := o.dst.(*s3Writer); ok && cerr == nil {
+			fmt.Printf("uploaded %d bytes to %s\n", s3.size, o.path)
+		}
+		if err == nil && cerr != nil {
+			err = fmt.Errorf("closing %s: %s", o.path, cerr)
+		}
+	}
+	c.outputFiles = nil
+	return err
+}
+
+// isFormat reports whether name is a supported output format.
+func isFormat(name string) bool {
+	switch name {
+	case "json", "ndjson", "csv", "column", "markdown", "promql-style":
+		return true
+	}
+	return false
+}
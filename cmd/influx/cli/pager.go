@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// defaultPager is the pager used when $PAGER is not set. The flags make less
+// exit immediately if the output fits on one screen, pass through color
+// escapes, and leave the output on the screen after exiting.
+var defaultPager = []string{"less", "-FRX"}
+
+// pagerCommand returns the command used to page output.
+func pagerCommand() *exec.Cmd {
+	args := strings.Fields(os.Getenv("PAGER"))
+	if len(args) == 0 {
+		args = defaultPager
+	}
+	return exec.Command(args[0], args[1:]...)
+}
+
+// usePager returns true if output written to w should go through the pager.
+// Output that is not going to the terminal is never paged.
+func (c *CommandLine) usePager(w io.Writer) bool {
+	return c.Pager && w == io.Writer(os.Stdout) && terminal.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// formatWithPager formats response and pipes the result through the pager.
+// If the pager cannot be started, the output is written to w directly.
+func (c *CommandLine) formatWithPager(response *client.Response, w io.Writer) error {
+	var buf bytes.Buffer
+	var f Formatter
+	if err := f.Format(response, &buf, c.formatOptions()); err != nil {
+		return err
+	}
+
+	cmd := pagerCommand()
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		_, err = buf.WriteTo(w)
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "WARN: unable to start pager: %s\n", err)
+		_, err = buf.WriteTo(w)
+		return err
+	}
+
+	// The pager may exit before reading everything, for example when the user
+	// quits early. The resulting broken pipe error is expected and ignored.
+	buf.WriteTo(stdin)
+	stdin.Close()
+	cmd.Wait()
+	return nil
+}
+
+// setPager turns the pager on or off.
+func (c *CommandLine) setPager(cmd string) {
+	args := strings.Fields(strings.ToLower(strings.TrimSuffix(strings.TrimSpace(cmd), ";")))
+	switch {
+	case len(args) == 1:
+		c.Pager = !c.Pager
+	case len(args) == 2 && args[1] == "on":
+		c.Pager = true
+	case len(args) == 2 && args[1] == "off":
+		c.Pager = false
+	default:
+		fmt.Println("Usage: pager [on|off]")
+		return
+	}
+	if c.Pager {
+		fmt.Println("pager enabled")
+	} else {
+		fmt.Println("pager disabled")
+	}
+}
@@ -5,30 +5,58 @@ import (
 	"fmt"
 )
 
+// parseDatabaseAndRetentionPolicy parses "db", "db.rp" or ".rp" into the
+// database and retention policy names. Either part may be double quoted, in
+// which case it may contain dots and spaces, and \" and \\ are unescaped.
 func parseDatabaseAndRetentionPolicy(stmt []byte) (string, string, error) {
-	var db, rp []byte
-	var quoted bool
-	var seperatorCount int
+	var parts [2][]byte
+	var part int
 
 	stmt = bytes.TrimSpace(stmt)
-
-	for _, b := range stmt {
-		if b == '"' {
-			quoted = !quoted
-			continue
-		}
-		if b == '.' && !quoted {
-			seperatorCount++
-			if seperatorCount > 1 {
+	for i := 0; i < len(stmt); i++ {
+		b := stmt[i]
+		switch {
+		case b == '"':
+			if len(parts[part]) > 0 {
+				return "", "", fmt.Errorf("unexpected quote in %s", string(stmt))
+			}
+			ident, n, ok := parseQuotedName(stmt[i:])
+			if !ok {
+				return "", "", fmt.Errorf("unterminated quoted name in %s", string(stmt))
+			}
+			i += n - 1
+			if i+1 < len(stmt) && stmt[i+1] != '.' {
+				return "", "", fmt.Errorf("expected . after quoted name in %s", string(stmt))
+			}
+			parts[part] = ident
+		case b == '.':
+			part++
+			if part > 1 {
 				return "", "", fmt.Errorf("unable to parse database and retention policy from %s", string(stmt))
 			}
-			continue
+		case isWhitespace(rune(b)):
+			return "", "", fmt.Errorf("unexpected space in %s, quote names containing spaces", string(stmt))
+		default:
+			parts[part] = append(parts[part], b)
 		}
-		if seperatorCount == 1 {
-			rp = append(rp, b)
-			continue
+	}
+	return string(parts[0]), string(parts[1]), nil
+}
+
+// parseQuotedName parses the double quoted name at the start of stmt and
+// returns it unescaped along with the number of bytes consumed.
+func parseQuotedName(stmt []byte) ([]byte, int, bool) {
+	ident := []byte{}
+	for i := 1; i < len(stmt); i++ {
+		switch stmt[i] {
+		case '\\':
+			if i+1 < len(stmt) && (stmt[i+1] == '"' || stmt[i+1] == '\\') {
+				i++
+			}
+		case '"':
+			return ident, i + 1, true
 		}
-		db = append(db, b)
+		ident = append(ident, stmt[i])
 	}
-	return string(db), string(rp), nil
+	return nil, 0, false
 }
@@ -58,7 +58,46 @@ func Test_parseDatabaseAndretentionPolicy(t *testing.T) {
 			db:   "foo.bin",
 			rp:   "bar.baz....",
 		},
+		{
+			stmt: `"my db"."one week"`,
+			db:   "my db",
+			rp:   "one week",
+		},
+		{
+			stmt: `"my.db. v2".autogen`,
+			db:   "my.db. v2",
+			rp:   "autogen",
+		},
+		{
+			stmt: `"say \"hi\"".rp`,
+			db:   `say "hi"`,
+			rp:   "rp",
+		},
+		{
+			stmt: `"back\\slash"`,
+			db:   `back\slash`,
+		},
 
+		{
+			stmt: `my db`,
+			err:  errors.New("foo"),
+		},
+		{
+			stmt: `"my db".one week`,
+			err:  errors.New("foo"),
+		},
+		{
+			stmt: `"foo.bar`,
+			err:  errors.New("foo"),
+		},
+		{
+			stmt: `foo"bar"`,
+			err:  errors.New("foo"),
+		},
+		{
+			stmt: `"foo"bar`,
+			err:  errors.New("foo"),
+		},
 		{
 			stmt: `"foo.bin"."bar".boom`,
 			err:  errors.New("foo"),
@@ -88,3 +127,26 @@ func Test_parseDatabaseAndretentionPolicy(t *testing.T) {
 	}
 
 }
+
+func Test_parseRetentionPolicyDirective(t *testing.T) {
+	tests := []struct {
+		query     string
+		rp        string
+		remainder string
+		ok        bool
+	}{
+		{query: `rp autogen; SELECT * FROM cpu`, rp: "autogen", remainder: `SELECT * FROM cpu`, ok: true},
+		{query: ` RP autogen ;SELECT * FROM cpu`, rp: "autogen", remainder: `SELECT * FROM cpu`, ok: true},
+		{query: `rp "one week"; SELECT * FROM cpu`, rp: "one week", remainder: `SELECT * FROM cpu`, ok: true},
+		{query: `rp autogen SELECT * FROM cpu`, remainder: `rp autogen SELECT * FROM cpu`},
+		{query: `rpx; SELECT * FROM cpu`, remainder: `rpx; SELECT * FROM cpu`},
+		{query: `SELECT * FROM rp`, remainder: `SELECT * FROM rp`},
+	}
+
+	for _, tt := range tests {
+		rp, remainder, ok := parseRetentionPolicyDirective(tt.query)
+		if rp != tt.rp || remainder != tt.remainder || ok != tt.ok {
+			t.Errorf("%q: got (%q, %q, %v), exp (%q, %q, %v)", tt.query, rp, remainder, ok, tt.rp, tt.remainder, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"unsafe"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/peterh/liner"
+)
+
+// collectPaste gathers the points pasted on the lines after an INSERT
+// statement into it, so that they are written in one batch with the same
+// target. Pasted lines that repeat "INSERT " without INTO are points as well.
+// The paste ends with the first line that is not a point, which is returned
+// as next to be run on its own, or with the input already read from the
+// terminal. A line is only prompted for once it is buffered, so no prompt is
+// left waiting for input while the insert runs.
+func (c *CommandLine) collectPaste(stmt string) (batch, next string, err error) {
+	lines := []string{stmt}
+	for bufferedLine(c.Line) {
+		l, err := c.Line.Prompt(c.prompt())
+		if err == io.EOF {
+			return strings.Join(lines, "\n"), "exit", nil
+		} else if err != nil {
+			return strings.Join(lines, "\n"), "", err
+		}
+
+		point := l
+		if i, rest := parseNextIdentifier(l); strings.EqualFold(i, "insert") {
+			point = rest
+		}
+		if !isLinePoint(point) {
+			return strings.Join(lines, "\n"), l, nil
+		}
+		lines = append(lines, strings.TrimSpace(point))
+	}
+	return strings.Join(lines, "\n"), "", nil
+}
+
+// bufferedLine reports whether line has read a whole line of input that no
+// prompt has returned yet, as it does with the rest of a paste. liner reads
+// the input through a buffered reader it does not expose, so the reader is
+// looked up by reflection; false is returned if it cannot be found.
+func bufferedLine(line *liner.State) bool {
+	if line == nil {
+		return false
+	}
+	f := reflect.ValueOf(line).Elem().FieldByName("r")
+	if !f.IsValid() || !f.CanAddr() {
+		return false
+	}
+	r, ok := reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem().Interface().(*bufio.Reader)
+	if !ok || r == nil {
+		return false
+	}
+	b, _ := r.Peek(r.Buffered())
+	return bytes.ContainsAny(b, "\r\n")
+}
+
+// isInsert reports whether a line is an INSERT statement.
+func isInsert(line string) bool {
+	i, _ := parseNextIdentifier(line)
+	return strings.EqualFold(i, "insert")
+}
+
+// isLinePoint reports whether a line looks like a point in line protocol
+// rather than a command: its second word holds the fields, as in
+// "cpu,host=a value=1".
+func isLinePoint(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) >= 2 && !strings.EqualFold(fields[0], "into") && strings.Contains(fields[1], "=")
+}
+
+// rawPoints returns the points of the line protocol text of an INSERT, one
+// for each line that is not blank.
+func rawPoints(text string) []client.Point {
+	var points []client.Point
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			points = append(points, client.Point{Raw: line})
+		}
+	}
+	return points
+}
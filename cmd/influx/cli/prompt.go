@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultPrompt is the prompt template used when none is configured.
+const DefaultPrompt = "> "
+
+// promptPlaceholder matches a {name} placeholder in a prompt template.
+var promptPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// promptValues returns the values substituted for the prompt placeholders.
+func (c *CommandLine) promptValues() map[string]string {
+	return map[string]string{
+		"{db}":   c.Database,
+		"{rp}":   c.RetentionPolicy,
+		"{host}": c.Host,
+		"{fmt}":  c.Format,
+	}
+}
+
+// prompt returns the prompt for the next line of input, expanding the
+// placeholders in the configured template. Unknown placeholders are kept.
+func (c *CommandLine) prompt() string {
+	tmpl := c.Prompt
+	if tmpl == "" {
+		tmpl = DefaultPrompt
+	}
+	values := c.promptValues()
+	return promptPlaceholder.ReplaceAllStringFunc(tmpl, func(p string) string {
+		if v, ok := values[p]; ok {
+			return v
+		}
+		return p
+	})
+}
+
+// unknownPlaceholders returns the placeholders in tmpl that prompt does not
+// know how to expand.
+func (c *CommandLine) unknownPlaceholders(tmpl string) []string {
+	values := c.promptValues()
+	var unknown []string
+	for _, p := range promptPlaceholder.FindAllString(tmpl, -1) {
+		if _, ok := values[p]; !ok {
+			unknown = append(unknown, p)
+		}
+	}
+	return unknown
+}
+
+// warnUnknownPlaceholders prints a warning for every placeholder in tmpl
+// that is not recognized.
+func (c *CommandLine) warnUnknownPlaceholders(tmpl string) {
+	if unknown := c.unknownPlaceholders(tmpl); len(unknown) > 0 {
+		fmt.Printf("Warning: unknown prompt placeholder(s) %s. Supported placeholders are {db}, {rp}, {host}, and {fmt}.\n",
+			strings.Join(unknown, ", "))
+	}
+}
+
+// SetPrompt handles the prompt command. "prompt <template>" sets the prompt
+// template, "prompt default" restores the default prompt and "prompt" alone
+// prints the current template. Quote the template to keep trailing spaces.
+func (c *CommandLine) SetPrompt(cmd string) {
+	tmpl := strings.TrimSpace(strings.TrimSpace(cmd)[len("prompt"):])
+	switch {
+	case tmpl == "":
+		current := c.Prompt
+		if current == "" {
+			current = DefaultPrompt
+		}
+		fmt.Printf("prompt is %q\n", current)
+		return
+	case strings.EqualFold(tmpl, "default"):
+		c.Prompt = DefaultPrompt
+		return
+	}
+
+	if len(tmpl) >= 2 && (tmpl[0] == '"' || tmpl[0] == '\'') && tmpl[len(tmpl)-1] == tmpl[0] {
+		if tmpl[0] == '"' {
+			s, err := strconv.Unquote(tmpl)
+			if err != nil {
+				fmt.Printf("Invalid prompt %s: %s\n", tmpl, err)
+				return
+			}
+			tmpl = s
+		} else {
+			tmpl = tmpl[1 : len(tmpl)-1]
+		}
+	}
+	c.warnUnknownPlaceholders(tmpl)
+	c.Prompt = tmpl
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/influxdata/influxql"
+)
+
+// runCommand runs cmd through ParseCommand, turning a panic while parsing,
+// querying or formatting into an error so that one bad command does not end
+// the session. The panic is printed with the command, and with its stack if
+// Debug is set, and returned as an ExitError for the non-interactive modes.
+func (c *CommandLine) runCommand(cmd string) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		// The command is left out if it holds credentials, and passwords
+		// in a statement are redacted.
+		msg := fmt.Sprintf("panic running %q: %v", influxql.Sanitize(cmd), r)
+		if isCredentialCommand(cmd) {
+			msg = fmt.Sprintf("panic running command: %v", r)
+		}
+		if c.Debug {
+			msg += "\n" + string(debug.Stack())
+		}
+		fmt.Printf("%s %s\n", c.errPrefix(), msg)
+		err = &Error{Code: ExitError, Err: errors.New(msg)}
+	}()
+	return c.ParseCommand(cmd)
+}
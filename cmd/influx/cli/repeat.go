@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// repeat handles "repeat <n> <query>", running a query n times without
+// printing its rows and then printing latency statistics of the runs that
+// succeeded and the errors of those that failed. An interrupt stops the runs
+// and prints the statistics so far. The timeout, if set, applies to each run.
+func (c *CommandLine) repeat(cmd string) error {
+	fields := strings.Fields(cmd)
+	if len(fields) < 3 {
+		fmt.Println("Usage: repeat <n> <query>")
+		return nil
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil || n <= 0 {
+		fmt.Printf("Invalid count %q. Please use a positive number of runs.\n", fields[1])
+		return nil
+	}
+	query := strings.TrimSpace(strings.TrimSpace(cmd)[len(fields[0]):])
+	query = strings.TrimSpace(query[len(fields[1]):])
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	var latencies []time.Duration
+	errs := make(map[string]int)
+	var errOrder []string
+	runs := 0
+	for ; runs < n && ctx.Err() == nil; runs++ {
+		qctx, qcancel := ctx, context.CancelFunc(func() {})
+		if c.QueryTimeout > 0 {
+			qctx, qcancel = context.WithTimeout(ctx, c.QueryTimeout)
+		}
+		start := time.Now()
+		response, err := c.Client.QueryContext(qctx, c.query(query))
+		d := time.Since(start)
+		if err == nil {
+			err = response.Error()
+		}
+		if err != nil && ctx.Err() != nil {
+			// Interrupted, which says nothing about the query.
+			qcancel()
+			break
+		}
+		if err != nil {
+			msg := c.queryContextErr(qctx, err).Error()
+			if errs[msg] == 0 {
+				errOrder = append(errOrder, msg)
+			}
+			errs[msg]++
+		} else {
+			latencies = append(latencies, d)
+		}
+		qcancel()
+	}
+
+	w := c.output()
+	if runs < n {
+		fmt.Fprintf(w, "interrupted after %d of %d runs\n", runs, n)
+	}
+	fmt.Fprintf(w, "%d runs, %d succeeded, %d failed\n", runs, len(latencies), runs-len(latencies))
+	writeLatencies(w, latencies)
+	for _, msg := range errOrder {
+		fmt.Fprintf(w, "%d x %s\n", errs[msg], msg)
+	}
+	return nil
+}
+
+// writeLatencies writes the minimum, average, median, 95th percentile and
+// maximum of latencies.
+func writeLatencies(w io.Writer, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	round := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+	fmt.Fprintf(w, "min %s, avg %s, p50 %s, p95 %s, max %s\n",
+		round(sorted[0]),
+		round(sum/time.Duration(len(sorted))),
+		round(percentile(sorted, 0.50)),
+		round(percentile(sorted, 0.95)),
+		round(sorted[len(sorted)-1]))
+}
+
+// percentile returns the nearest-rank percentile p of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
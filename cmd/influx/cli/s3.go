@@ -0,0 +1,338 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3PartSize is the size of the parts of an upload to S3, which bounds the
+// memory an output to S3 buffers. It is the smallest size S3 accepts for
+// all parts but the last.
+var s3PartSize = 5 << 20
+
+// s3Config is how objects are uploaded to S3 or S3-compatible storage.
+type s3Config struct {
+	// Endpoint is the URL of S3-compatible storage, whose objects are
+	// addressed by path. Objects are uploaded to AWS when it is empty.
+	Endpoint string
+
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// s3ConfigFromEnv returns the S3 configuration of the standard AWS
+// environment variables. A non-empty endpoint or region takes precedence
+// over the environment.
+func s3ConfigFromEnv(endpoint, region string) (s3Config, error) {
+	config := s3Config{
+		Endpoint:        endpoint,
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	for _, name := range []string{"AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"} {
+		if config.Endpoint == "" {
+			config.Endpoint = os.Getenv(name)
+		}
+	}
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if config.Region == "" {
+			config.Region = os.Getenv(name)
+		}
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return s3Config{}, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to write to S3")
+	}
+	return config, nil
+}
+
+// parseS3URL splits s3://bucket/key into the bucket and the key. ok is false
+// if path is not an S3 URL.
+func parseS3URL(path string) (bucket, key string, ok bool, err error) {
+	if !strings.HasPrefix(path, "s3://") {
+		return "", "", false, nil
+	}
+	rest := path[len("s3://"):]
+	i := strings.Index(rest, "/")
+	if i <= 0 || i == len(rest)-1 {
+		return "", "", true, fmt.Errorf("invalid S3 URL %q: use s3://bucket/key", path)
+	}
+	return rest[:i], rest[i+1:], true, nil
+}
+
+// s3Writer streams what is written to it to an S3 object with a multipart
+// upload, buffering one part at a time. Close completes the upload, or aborts
+// it if anything failed, so that no partial object is left.
+type s3Writer struct {
+	config   s3Config
+	client   *http.Client
+	bucket   string
+	key      string
+	uploadID string
+	buf      bytes.Buffer
+	parts    []s3Part
+	size     int64
+	err      error
+}
+
+// s3Part is an uploaded part of an object, listed when completing the upload.
+type s3Part struct {
+	PartNumber int
+	ETag       string
+}
+
+// newS3Writer starts a multipart upload to key in bucket.
+func newS3Writer(config s3Config, bucket, key string) (*s3Writer, error) {
+	w := &s3Writer{config: config, client: &http.Client{}, bucket: bucket, key: key}
+	resp, err := w.do("POST", "uploads=", nil)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(resp, &result); err != nil || result.UploadID == "" {
+		return nil, fmt.Errorf("starting the upload of %s: unexpected response %q", w.url(), resp)
+	}
+	w.uploadID = result.UploadID
+	return w, nil
+}
+
+// Write buffers p, uploading a part whenever a full part is buffered.
+func (w *s3Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf.Write(p)
+	w.size += int64(len(p))
+	for w.buf.Len() >= s3PartSize {
+		if w.err = w.uploadPart(w.buf.Next(s3PartSize)); w.err != nil {
+			return len(p), w.err
+		}
+	}
+	return len(p), nil
+}
+
+// Close uploads the last part and completes the upload. If the upload
+// failed, it is aborted instead and the error returned.
+func (w *s3Writer) Close() error {
+	if w.err == nil && (w.buf.Len() > 0 || len(w.parts) == 0) {
+		w.err = w.uploadPart(w.buf.Bytes())
+	}
+	if w.err == nil {
+		w.err = w.complete()
+	}
+	if w.err != nil {
+		if _, err := w.do("DELETE", "uploadId="+s3Escape(w.uploadID), nil); err != nil {
+			return fmt.Errorf("%s; aborting the upload failed: %s", w.err, err)
+		}
+		return w.err
+	}
+	return nil
+}
+
+// uploadPart uploads p as the next part.
+func (w *s3Writer) uploadPart(p []byte) error {
+	n := len(w.parts) + 1
+	query := "partNumber=" + strconv.Itoa(n) + "&uploadId=" + s3Escape(w.uploadID)
+	req, err := w.newRequest("PUT", query, p)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if err := s3Error(resp.StatusCode, body); err != nil {
+		return fmt.Errorf("uploading part %d of %s: %s", n, w.url(), err)
+	}
+	w.parts = append(w.parts, s3Part{PartNumber: n, ETag: resp.Header.Get("ETag")})
+	return nil
+}
+
+// complete completes the upload from the uploaded parts.
+func (w *s3Writer) complete() error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: w.parts})
+	if err != nil {
+		return err
+	}
+	// The response can be an error even with 200 OK, as S3 only knows
+	// whether completing the upload succeeded after it started answering.
+	resp, err := w.do("POST", "uploadId="+s3Escape(w.uploadID), body)
+	if err != nil {
+		return err
+	}
+	return s3Error(http.StatusOK, resp)
+}
+
+// do sends a request for the object and returns the response body, or an
+// error for a response that is not successful.
+func (w *s3Writer) do(method, query string, body []byte) ([]byte, error) {
+	req, err := w.newRequest(method, query, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := s3Error(resp.StatusCode, b); err != nil {
+		return nil, fmt.Errorf("%s %s: %s", method, w.url(), err)
+	}
+	return b, nil
+}
+
+// url returns the s3:// URL of the object.
+func (w *s3Writer) url() string {
+	return "s3://" + w.bucket + "/" + w.key
+}
+
+// newRequest returns a request for the object signed with AWS Signature
+// Version 4. query must be in canonical form: sorted and escaped.
+func (w *s3Writer) newRequest(method, query string, body []byte) (*http.Request, error) {
+	host, path := w.config.objectLocation(w.bucket, w.key)
+	scheme := "https"
+	if strings.HasPrefix(w.config.Endpoint, "http://") {
+		scheme = "http"
+	}
+	u := scheme + "://" + host + path
+	if query != "" {
+		u += "?" + query
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	w.config.sign(req, path, query, body, time.Now().UTC())
+	return req, nil
+}
+
+// objectLocation returns the host and escaped path of an object. Objects are
+// addressed by path at a custom endpoint and in buckets whose name has dots,
+// which do not match the wildcard certificate of virtual-hosted buckets.
+func (config s3Config) objectLocation(bucket, key string) (host, path string) {
+	if config.Endpoint != "" {
+		host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(config.Endpoint, "https://"), "http://"), "/")
+		return host, "/" + s3Escape(bucket) + "/" + s3EscapePath(key)
+	}
+	if strings.Contains(bucket, ".") {
+		return "s3." + config.Region + ".amazonaws.com", "/" + s3Escape(bucket) + "/" + s3EscapePath(key)
+	}
+	return bucket + ".s3." + config.Region + ".amazonaws.com", "/" + s3EscapePath(key)
+}
+
+// sign adds the AWS Signature Version 4 authorization of req, whose escaped
+// path and canonical query are given, sent at t.
+func (config s3Config) sign(req *http.Request, path, query string, body []byte, t time.Time) {
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	amzDate := t.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", config.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{req.Method, path, query, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := t.Format("20060102") + "/" + config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + config.SecretAccessKey)
+	for _, s := range []string{t.Format("20060102"), config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	io.WriteString(h, s)
+	return h.Sum(nil)
+}
+
+// s3Escape escapes s as AWS Signature Version 4 requires, leaving only the
+// unreserved characters of RFC 3986.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// s3EscapePath escapes an object key, keeping its slashes.
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = s3Escape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3Error returns the error of an S3 response, or nil if it succeeded.
+func s3Error(status int, body []byte) error {
+	var e struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &e) == nil {
+		return fmt.Errorf("%s: %s", e.Code, e.Message)
+	}
+	if status < 200 || status > 299 {
+		return fmt.Errorf("%d %s", status, http.StatusText(status))
+	}
+	return nil
+}
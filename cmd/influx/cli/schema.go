@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxql"
+)
+
+// schemaSeries describes the name, tags and columns of one series of a
+// query result.
+type schemaSeries struct {
+	StatementID int               `json:"statement_id"`
+	Name        string            `json:"name"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Columns     []schemaColumn    `json:"columns"`
+}
+
+// schemaColumn is a column name and the type inferred from its values.
+type schemaColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// schema handles "schema <query>", printing the series and column types of
+// the query result as JSON. SELECT statements are run with LIMIT 1, so only
+// the first row of each series is read.
+func (c *CommandLine) schema(cmd string) error {
+	query := strings.TrimSpace(strings.TrimSpace(cmd)[len("schema"):])
+	if query == "" {
+		fmt.Println("Usage: schema <query>")
+		return nil
+	}
+
+	q, err := influxql.NewParser(strings.NewReader(query)).ParseQuery()
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	for _, stmt := range q.Statements {
+		if s, ok := stmt.(*influxql.SelectStatement); ok {
+			s.Limit = 1
+		}
+		c.qualifySources(stmt)
+	}
+
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	response, err := c.Client.QueryContext(ctx, c.query(q.String()))
+	if err == nil {
+		err = response.Error()
+	}
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	return writeSchema(c.output(), response)
+}
+
+// writeSchema writes the series of response, with the inferred type of each
+// column, to w as an indented JSON document.
+func writeSchema(w io.Writer, response *client.Response) error {
+	doc := struct {
+		Series []schemaSeries `json:"series"`
+	}{Series: []schemaSeries{}}
+
+	for i, result := range response.Results {
+		for _, row := range result.Series {
+			s := schemaSeries{
+				StatementID: i,
+				Name:        row.Name,
+				Tags:        row.Tags,
+				Columns:     make([]schemaColumn, len(row.Columns)),
+			}
+			for j, col := range row.Columns {
+				s.Columns[j] = schemaColumn{Name: col, Type: columnType(col, row.Values, j)}
+			}
+			doc.Series = append(doc.Series, s)
+		}
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// columnType infers the type of the column at index i from the first non-null
+// value in it. The time column is always "time", and "unknown" is returned
+// when every value is null.
+func columnType(name string, values [][]interface{}, i int) string {
+	if name == "time" {
+		return "time"
+	}
+	for _, v := range values {
+		if i < len(v) && v[i] != nil {
+			return valueType(v[i])
+		}
+	}
+	return "unknown"
+}
+
+// valueType maps the types handled by interfaceToString to integer, float,
+// string or boolean. JSON does not tell integers and floats apart, so a
+// json.Number without a fraction or exponent is reported as an integer.
+func valueType(v interface{}) string {
+	switch t := v.(type) {
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return "integer"
+		}
+		return "float"
+	case bool:
+		return "boolean"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		return "integer"
+	case float32, float64:
+		return "float"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxql"
+)
+
+// setSlowLog handles "slowlog <duration>", which warns on stderr about every
+// InfluxQL query that takes longer than the duration, and "slowlog off".
+func (c *CommandLine) setSlowLog(cmd string) {
+	arg := strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("slowlog"):]), ";")
+	switch {
+	case arg == "":
+		if c.SlowLog <= 0 {
+			fmt.Println("slowlog is off")
+		} else {
+			fmt.Printf("slowlog is %s\n", c.SlowLog)
+		}
+	case strings.EqualFold(arg, "off"):
+		c.SlowLog = 0
+	default:
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			fmt.Printf("Invalid slowlog threshold %q. Please use a duration such as 500ms, or off.\n", arg)
+			return
+		}
+		c.SlowLog = d
+	}
+}
+
+// logSlowQuery warns on stderr if a query took longer than SlowLog. Being on
+// stderr, the warning is printed in quiet mode too without mixing with the
+// results. Passwords in the query are redacted.
+func (c *CommandLine) logSlowQuery(query string, elapsed time.Duration) {
+	if c.SlowLog <= 0 || elapsed <= c.SlowLog {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "SLOW: query took %s, over %s: %s\n", elapsed, c.SlowLog, influxql.Sanitize(query))
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxql"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// sparkBars are the bars of a sparkline, from the lowest value to the
+// highest.
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// sparkSeries is the values of one series in a sparkline. A nil value is
+// drawn as a gap.
+type sparkSeries struct {
+	label  string
+	values []*float64
+}
+
+// sparkline handles "sparkline <query>", running a SELECT of a single field
+// and printing a unicode sparkline of each series with its minimum and
+// maximum. Each series is scaled on its own.
+func (c *CommandLine) sparkline(cmd string) error {
+	query := strings.TrimSpace(strings.TrimSpace(cmd)[len("sparkline"):])
+	if query == "" {
+		fmt.Println("Usage: sparkline <query>")
+		return nil
+	}
+	if !c.ForceTTY && !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Println("sparkline draws to a terminal; use a format such as csv to process the values instead")
+		return nil
+	}
+
+	q, err := influxql.NewParser(strings.NewReader(query)).ParseQuery()
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+	if len(q.Statements) != 1 {
+		fmt.Println("sparkline takes a single SELECT statement")
+		return nil
+	}
+	stmt, ok := q.Statements[0].(*influxql.SelectStatement)
+	if !ok {
+		fmt.Println("sparkline takes a single SELECT statement")
+		return nil
+	}
+	if len(stmt.Fields) != 1 || stmt.HasFieldWildcard() {
+		fmt.Println("sparkline takes a SELECT of a single field, e.g. sparkline SELECT mean(usage_idle) FROM cpu WHERE time > now() - 1h GROUP BY time(1m), host")
+		return nil
+	}
+	c.qualifySources(stmt)
+
+	ctx, cancel := c.queryContext(context.Background())
+	defer cancel()
+	response, err := c.Client.QueryContext(ctx, c.query(stmt.String()))
+	if err == nil {
+		err = response.Error()
+	}
+	if err != nil {
+		err = c.queryContextErr(ctx, err)
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return err
+	}
+
+	// A chunked response can split a series, so its values are gathered by
+	// series key in the order the series first appear.
+	var series []*sparkSeries
+	byKey := make(map[string]*sparkSeries)
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			key := seriesKey(row)
+			s, ok := byKey[key]
+			if !ok {
+				s = &sparkSeries{label: sparkLabel(row)}
+				byKey[key] = s
+				series = append(series, s)
+			}
+			col := len(row.Columns) - 1
+			for _, v := range row.Values {
+				if col >= len(v) || v[col] == nil {
+					s.values = append(s.values, nil)
+					continue
+				}
+				f, ok := sparkValue(v[col])
+				if !ok {
+					err := fmt.Errorf("sparkline: %s of %s is not numeric: %v", row.Columns[col], s.label, v[col])
+					fmt.Printf("%s %s\n", c.errPrefix(), err)
+					return err
+				}
+				s.values = append(s.values, &f)
+			}
+		}
+	}
+	if len(series) == 0 {
+		fmt.Println("no data")
+		return nil
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(c.output(), 0, 8, 2, ' ', 0)
+	for _, s := range series {
+		line, min, max := drawSparkline(s.values)
+		if math.IsInf(min, 0) {
+			fmt.Fprintf(w, "%s\t%s\t\n", s.label, line)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\tmin %v\tmax %v\n", s.label, line, min, max)
+	}
+	return w.Flush()
+}
+
+// drawSparkline returns the sparkline of values with their minimum and
+// maximum. min and max are infinite if every value is nil.
+func drawSparkline(values []*float64) (line string, min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if v != nil {
+			min, max = math.Min(min, *v), math.Max(max, *v)
+		}
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		switch {
+		case v == nil:
+			b.WriteRune(' ')
+		case max == min:
+			b.WriteRune(sparkBars[len(sparkBars)/2])
+		default:
+			i := int((*v - min) / (max - min) * float64(len(sparkBars)-1))
+			b.WriteRune(sparkBars[i])
+		}
+	}
+	return b.String(), min, max
+}
+
+// sparkValue returns the number a value of the response stands for.
+func sparkValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// sparkLabel returns the name of a series with its tags.
+func sparkLabel(row models.Row) string {
+	tags := make([]string, 0, len(row.Tags))
+	for k, v := range row.Tags {
+		tags = append(tags, k+"="+v)
+	}
+	sort.Strings(tags)
+	return strings.Join(append([]string{row.Name}, tags...), ",")
+}
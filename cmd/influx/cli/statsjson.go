@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxdb/models"
+)
+
+// statsInstance is one series of SHOW STATS, such as the statistics of one
+// shard of the shard component.
+type statsInstance struct {
+	Tags   map[string]string      `json:"tags,omitempty"`
+	Values map[string]interface{} `json:"values"`
+}
+
+// statsJSON handles "stats json", printing SHOW STATS and SHOW DIAGNOSTICS
+// as one JSON object for monitoring scripts. Statistics are keyed by
+// component, each with the list of its instances. Diagnostics are keyed by
+// section, each an object of its values, or a list of them for a section of
+// several rows. A statement the user may not run is reported under "errors"
+// and the other is still printed.
+func (c *CommandLine) statsJSON() error {
+	ctx, cancel := c.queryContext(context.Background())
+	defer cancel()
+
+	doc := struct {
+		Stats       map[string][]statsInstance `json:"stats,omitempty"`
+		Diagnostics map[string]interface{}     `json:"diagnostics,omitempty"`
+		Errors      map[string]string          `json:"errors,omitempty"`
+	}{}
+	addError := func(stmt string, err error) {
+		if doc.Errors == nil {
+			doc.Errors = make(map[string]string)
+		}
+		doc.Errors[stmt] = c.queryContextErr(ctx, err).Error()
+	}
+
+	if response, err := c.statusQuery(ctx, "SHOW STATS"); err != nil {
+		addError("SHOW STATS", err)
+	} else {
+		doc.Stats = make(map[string][]statsInstance)
+		forEachRow(response, func(row models.Row) {
+			for _, values := range row.Values {
+				doc.Stats[row.Name] = append(doc.Stats[row.Name], statsInstance{
+					Tags:   row.Tags,
+					Values: rowObject(row.Columns, values),
+				})
+			}
+		})
+	}
+
+	if response, err := c.statusQuery(ctx, "SHOW DIAGNOSTICS"); err != nil {
+		addError("SHOW DIAGNOSTICS", err)
+	} else {
+		doc.Diagnostics = make(map[string]interface{})
+		forEachRow(response, func(row models.Row) {
+			if len(row.Values) == 1 {
+				doc.Diagnostics[row.Name] = rowObject(row.Columns, row.Values[0])
+				return
+			}
+			objects := make([]map[string]interface{}, 0, len(row.Values))
+			for _, values := range row.Values {
+				objects = append(objects, rowObject(row.Columns, values))
+			}
+			doc.Diagnostics[row.Name] = objects
+		})
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.output(), "%s\n", b)
+	if len(doc.Errors) == 2 {
+		return errors.New("stats json: neither SHOW STATS nor SHOW DIAGNOSTICS could be run")
+	}
+	return nil
+}
+
+// forEachRow calls fn with every series of a response.
+func forEachRow(response *client.Response, fn func(models.Row)) {
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			fn(row)
+		}
+	}
+}
+
+// rowObject returns the values of a row keyed by their columns.
+func rowObject(columns []string, values []interface{}) map[string]interface{} {
+	obj := make(map[string]interface{}, len(columns))
+	for i, col := range columns {
+		if i < len(values) {
+			obj[col] = values[i]
+		}
+	}
+	return obj
+}
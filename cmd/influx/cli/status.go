@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxdb/models"
+)
+
+// statusNA is printed for a status value the server did not provide, for
+// example because the user is not permitted to run the query behind it.
+const statusNA = "n/a"
+
+// status handles the status command, printing a summary of the server's
+// health. Each value comes from its own request, and one that fails is shown
+// as n/a rather than failing the whole command.
+func (c *CommandLine) status() error {
+	ctx, cancel := c.signalContext(context.Background())
+	defer cancel()
+
+	var healthy string
+	version, latency := statusNA, statusNA
+	if d, v, err := c.Client.Ping(); err == nil {
+		healthy, latency = "yes", d.Round(time.Microsecond).String()
+		if v != "" {
+			version = v
+		}
+	} else {
+		healthy = fmt.Sprintf("no (%s)", err)
+	}
+
+	uptime := statusNA
+	if response, err := c.statusQuery(ctx, "SHOW DIAGNOSTICS"); err == nil {
+		if v, ok := statusValue(response, "system", "uptime"); ok {
+			uptime = interfaceToString(v)
+		}
+	}
+
+	databases := statusNA
+	if response, err := c.statusQuery(ctx, "SHOW DATABASES"); err == nil {
+		n := 0
+		for _, result := range response.Results {
+			for _, row := range result.Series {
+				n += len(row.Values)
+			}
+		}
+		databases = strconv.Itoa(n)
+	}
+
+	series, shards := statusNA, statusNA
+	if response, err := c.statusQuery(ctx, "SHOW STATS"); err == nil {
+		var numSeries int64
+		var numShards int
+		for _, result := range response.Results {
+			for _, row := range result.Series {
+				switch row.Name {
+				case "database":
+					numSeries += statusInt(row, "numSeries")
+				case "shard":
+					numShards++
+				}
+			}
+		}
+		series, shards = strconv.FormatInt(numSeries, 10), strconv.Itoa(numShards)
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(c.output(), 0, 1, 1, ' ', 0)
+	fmt.Fprintf(w, "Server\t%s\n", c.Client.Addr())
+	fmt.Fprintf(w, "Healthy\t%s\n", healthy)
+	fmt.Fprintf(w, "Version\t%s\n", version)
+	fmt.Fprintf(w, "Ping\t%s\n", latency)
+	fmt.Fprintf(w, "Uptime\t%s\n", uptime)
+	fmt.Fprintf(w, "Databases\t%s\n", databases)
+	fmt.Fprintf(w, "Series\t%s\n", series)
+	fmt.Fprintf(w, "Shards\t%s\n", shards)
+	return w.Flush()
+}
+
+// statusQuery runs a query for the status command, treating an error in the
+// response as a failed query.
+func (c *CommandLine) statusQuery(ctx context.Context, q string) (*client.Response, error) {
+	response, err := c.Client.QueryContext(ctx, client.Query{Command: q})
+	if err != nil {
+		return nil, err
+	}
+	if err := response.Error(); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// statusValue returns the value of column in the first row of the series
+// with the given name.
+func statusValue(response *client.Response, name, column string) (interface{}, bool) {
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			if row.Name != name || len(row.Values) == 0 {
+				continue
+			}
+			for i, col := range row.Columns {
+				if col == column && i < len(row.Values[0]) {
+					return row.Values[0][i], true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// statusInt returns the integer value of column in the first row of a SHOW
+// STATS series, or 0 if it has none.
+func statusInt(row models.Row, column string) int64 {
+	if len(row.Values) == 0 {
+		return 0
+	}
+	for i, col := range row.Columns {
+		if col != column || i >= len(row.Values[0]) {
+			continue
+		}
+		switch v := row.Values[0][i].(type) {
+		case json.Number:
+			n, _ := v.Int64()
+			return n
+		case float64:
+			return int64(v)
+		case int64:
+			return v
+		}
+	}
+	return 0
+}
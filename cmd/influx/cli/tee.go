@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// teeFile is a file receiving a copy of the query output.
+type teeFile struct {
+	path string
+	f    *os.File
+	w    *bufio.Writer
+}
+
+// close flushes buffered output and closes the file.
+func (t *teeFile) close() error {
+	err := t.w.Flush()
+	if cerr := t.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// output returns the writer query results are written to. It is stdout,
+// and the tee file as well while one is open.
+func (c *CommandLine) output() io.Writer {
+	var stdout io.Writer = os.Stdout
+	if c.stdout != nil {
+		stdout = c.stdout
+	}
+	if c.tee == nil {
+		return stdout
+	}
+	return io.MultiWriter(stdout, c.tee.w)
+}
+
+// setTee handles the tee command. "tee <path>" copies query output to the
+// file at path, truncating it, and "tee off" stops copying.
+func (c *CommandLine) setTee(cmd string) error {
+	args := strings.TrimSpace(strings.TrimSpace(cmd)[len("tee"):])
+	switch {
+	case args == "":
+		if c.tee == nil {
+			fmt.Println("tee is off")
+		} else {
+			fmt.Printf("tee is writing to %s\n", c.tee.path)
+		}
+		return nil
+	case strings.EqualFold(args, "off"):
+		if c.tee == nil {
+			fmt.Println("tee is already off")
+			return nil
+		}
+		path := c.tee.path
+		if err := c.closeTee(); err != nil {
+			return fmt.Errorf("closing %s: %s", path, err)
+		}
+		fmt.Printf("stopped writing to %s\n", path)
+		return nil
+	}
+
+	path := strings.Trim(args, `"'`)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if c.tee != nil {
+		old := c.tee.path
+		if err := c.closeTee(); err != nil {
+			fmt.Printf("closing %s: %s\n", old, err)
+		}
+	}
+	c.tee = &teeFile{path: path, f: f, w: bufio.NewWriter(f)}
+	fmt.Printf("writing output to stdout and %s\n", path)
+	return nil
+}
+
+// closeTee flushes and closes the tee file, if any.
+func (c *CommandLine) closeTee() error {
+	if c.tee == nil {
+		return nil
+	}
+	t := c.tee
+	c.tee = nil
+	return t.close()
+}
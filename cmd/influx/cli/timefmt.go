@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// layoutCheckTime is formatted to tell whether a layout has any elements. It
+// differs from the reference time in every element.
+var layoutCheckTime = time.Date(1999, time.December, 31, 23, 58, 59, 123456789, time.FixedZone("CET", 60*60))
+
+// setTimeFormat handles "timefmt <layout>", which prints the time column of
+// the column and csv formats using a Go reference-time layout, and
+// "timefmt clear", which goes back to printing times as the server returns
+// them.
+func (c *CommandLine) setTimeFormat(cmd string) {
+	layout := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("timefmt"):]), ";"))
+	switch {
+	case layout == "":
+		if c.TimeFormat == "" {
+			fmt.Println("timefmt is not set")
+		} else {
+			fmt.Printf("timefmt is %s\n", c.TimeFormat)
+		}
+	case strings.EqualFold(layout, "clear"):
+		c.TimeFormat = ""
+	case !isTimeLayout(layout):
+		fmt.Printf("Invalid layout %q. Please write the reference time in the layout you want, e.g. timefmt 2006-01-02 15:04:05\n", layout)
+	default:
+		c.TimeFormat = layout
+	}
+}
+
+// isTimeLayout reports whether layout contains at least one element of the
+// reference time, as one without any prints the same text for every time.
+func isTimeLayout(layout string) bool {
+	return layoutCheckTime.Format(layout) != layout
+}
+
+// formatTime formats a time column value with layout. The value is an
+// RFC3339 string, or an epoch in the given precision. Values that are neither
+// are printed unchanged.
+func formatTime(v interface{}, layout, precision string) string {
+	switch t := v.(type) {
+	case string:
+		if tm, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return tm.Format(layout)
+		}
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return time.Unix(0, n*models.GetPrecisionMultiplier(precision)).UTC().Format(layout)
+		}
+	}
+	return interfaceToString(v)
+}
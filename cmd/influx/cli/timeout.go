@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// setTimeout handles "timeout <duration>" and "timeout off", limiting how
+// long each InfluxQL or Flux query may run before it is canceled.
+func (c *CommandLine) setTimeout(cmd string) {
+	arg := strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("timeout"):]), ";")
+	switch {
+	case arg == "":
+		if c.QueryTimeout <= 0 {
+			fmt.Println("timeout is off")
+		} else {
+			fmt.Printf("timeout is %s\n", c.QueryTimeout)
+		}
+	case strings.EqualFold(arg, "off"):
+		c.QueryTimeout = 0
+	default:
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			fmt.Printf("Invalid timeout %q. Please use a duration such as 30s, or off.\n", arg)
+			return
+		}
+		c.QueryTimeout = d
+	}
+}
+
+// queryContext returns the context a query runs with. It is canceled by an
+// interrupt, as with signalContext, and after QueryTimeout when one is set.
+func (c *CommandLine) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := c.signalContext(ctx)
+	if c.QueryTimeout <= 0 {
+		return ctx, cancel
+	}
+	ctx, cancelTimeout := context.WithTimeout(ctx, c.QueryTimeout)
+	return ctx, func() {
+		cancelTimeout()
+		cancel()
+	}
+}
+
+// queryContextErr returns the error to report for a query that failed with
+// err, replacing it when the query was interrupted or timed out.
+func (c *CommandLine) queryContextErr(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return errAborted
+	case context.DeadlineExceeded:
+		return fmt.Errorf("query timed out after %s", c.QueryTimeout)
+	}
+	return err
+}
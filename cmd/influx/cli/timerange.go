@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxql"
+)
+
+// setTimeRange handles "range <start> <end>", which adds
+// "time >= start AND time < end" to the SELECT statements run afterwards
+// that have no time condition of their own, and "range clear", which
+// removes it. See parseRangeBound for the accepted bounds.
+func (c *CommandLine) setTimeRange(cmd string) {
+	args := strings.Fields(strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("range"):]), ";"))
+	switch {
+	case len(args) == 0:
+		if c.timeRange == nil {
+			fmt.Println("range is not set")
+		} else {
+			fmt.Printf("range is %s\n", c.timeRangeString())
+		}
+		return
+	case len(args) == 1 && strings.EqualFold(args[0], "clear"):
+		c.timeRange = nil
+		return
+	case len(args) != 2:
+		fmt.Println("Usage: range <start> <end>, e.g. range -1h now, or range clear")
+		return
+	}
+
+	start, err := parseRangeBound(args[0])
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return
+	}
+	end, err := parseRangeBound(args[1])
+	if err != nil {
+		fmt.Printf("%s %s\n", c.errPrefix(), err)
+		return
+	}
+	c.timeRange = &influxql.BinaryExpr{
+		Op:  influxql.AND,
+		LHS: &influxql.BinaryExpr{Op: influxql.GTE, LHS: &influxql.VarRef{Val: "time"}, RHS: start},
+		RHS: &influxql.BinaryExpr{Op: influxql.LT, LHS: &influxql.VarRef{Val: "time"}, RHS: end},
+	}
+}
+
+// parseRangeBound parses a bound of a range: now, a duration relative to now
+// such as -1h, an RFC3339 time or a date, or an InfluxQL time expression
+// without spaces such as now()-1d.
+func parseRangeBound(s string) (influxql.Expr, error) {
+	now := &influxql.Call{Name: "now"}
+	if strings.EqualFold(s, "now") || strings.EqualFold(s, "now()") {
+		return now, nil
+	}
+	if (s[0] == '-' || s[0] == '+') && len(s) > 1 {
+		if d, err := influxql.ParseDuration(s[1:]); err == nil {
+			op := influxql.SUB
+			if s[0] == '+' {
+				op = influxql.ADD
+			}
+			return &influxql.BinaryExpr{Op: op, LHS: now, RHS: &influxql.DurationLiteral{Val: d}}, nil
+		}
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &influxql.StringLiteral{Val: t.UTC().Format(time.RFC3339Nano)}, nil
+		}
+	}
+	expr, err := influxql.ParseExpr(s)
+	if err == nil {
+		switch expr.(type) {
+		case *influxql.Call, *influxql.BinaryExpr, *influxql.StringLiteral, *influxql.IntegerLiteral:
+			return expr, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid range bound %q: use now, a duration such as -1h, a time such as 2006-01-02T15:04:05Z or an expression such as now()-1d", s)
+}
+
+// applyTimeRange adds the range set by "range" to the condition of a SELECT
+// statement, or of the SELECT statement being explained, that has no time
+// condition.
+func (c *CommandLine) applyTimeRange(stmt influxql.Statement) {
+	if c.timeRange == nil {
+		return
+	}
+	if explain, ok := stmt.(*influxql.ExplainStatement); ok {
+		stmt = explain.Statement
+	}
+	selectStatement, ok := stmt.(*influxql.SelectStatement)
+	if !ok {
+		return
+	}
+	if selectStatement.Condition == nil {
+		selectStatement.Condition = influxql.CloneExpr(c.timeRange)
+		return
+	}
+	if influxql.HasTimeExpr(selectStatement.Condition) {
+		return
+	}
+	selectStatement.Condition = &influxql.BinaryExpr{
+		Op:  influxql.AND,
+		LHS: &influxql.ParenExpr{Expr: selectStatement.Condition},
+		RHS: influxql.CloneExpr(c.timeRange),
+	}
+}
+
+// timeRangeString returns the condition set by "range", or "" if none is.
+func (c *CommandLine) timeRangeString() string {
+	if c.timeRange == nil {
+		return ""
+	}
+	return c.timeRange.String()
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// setTiming handles "timing verbose", which prints how long the phases of
+// each InfluxQL query took, and "timing off".
+func (c *CommandLine) setTiming(cmd string) {
+	arg := strings.TrimSuffix(strings.TrimSpace(strings.TrimSpace(cmd)[len("timing"):]), ";")
+	switch {
+	case arg == "":
+		if c.VerboseTiming {
+			fmt.Println("timing is verbose")
+		} else {
+			fmt.Println("timing is off")
+		}
+	case strings.EqualFold(arg, "verbose"):
+		c.VerboseTiming = true
+	case strings.EqualFold(arg, "off"):
+		c.VerboseTiming = false
+	default:
+		fmt.Printf("Invalid timing %q. Please use verbose or off.\n", arg)
+	}
+}
+
+// writeTiming writes the phases of a query request and its total time, which
+// includes reading the response.
+func writeTiming(w io.Writer, t client.RequestTiming, total time.Duration) {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+	fmt.Fprintf(w, "timing: dns %s, connect %s, tls %s, wait %s, first byte %s, total %s",
+		round(t.DNS), round(t.Connect), round(t.TLS), round(t.Wait), round(t.FirstByte), round(total))
+	if t.Reused {
+		fmt.Fprint(w, " (reused connection)")
+	}
+	fmt.Fprintln(w)
+}
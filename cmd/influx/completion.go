@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// completionValues lists the allowed values of flags that take one of a fixed
+// set of values.
+var completionValues = map[string][]string{
+	"format":      {"json", "ndjson", "csv", "column", "markdown", "promql-style"},
+	"precision":   {"rfc3339", "h", "m", "s", "ms", "u", "ns"},
+	"consistency": {"any", "one", "quorum", "all"},
+	"type":        {"influxql", "flux"},
+}
+
+// writeCompletion writes a shell completion script for the flags in fs.
+func writeCompletion(w io.Writer, fs *flag.FlagSet, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: influx completion bash|zsh")
+	}
+
+	var flags []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) { flags = append(flags, f) })
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(w, flags)
+	case "zsh":
+		writeZshCompletion(w, flags)
+	default:
+		return fmt.Errorf("unsupported shell %q: specify bash or zsh", args[0])
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer, flags []*flag.Flag) {
+	names := make([]string, 0, len(flags))
+	for _, f := range flags {
+		names = append(names, "-"+f.Name)
+	}
+
+	fmt.Fprintln(w, "# bash completion for influx")
+	fmt.Fprintln(w, "_influx() {")
+	fmt.Fprintln(w, "    local cur prev")
+	fmt.Fprintln(w, `    cur="${COMP_WORDS[COMP_CWORD]}"`)
+	fmt.Fprintln(w, `    prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `    case "$prev" in`)
+	for _, f := range flags {
+		if values, ok := completionValues[f.Name]; ok {
+			fmt.Fprintf(w, "        -%[1]s|--%[1]s)\n", f.Name)
+			fmt.Fprintf(w, "            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(values, " "))
+			fmt.Fprintln(w, "            return ;;")
+		}
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _influx influx")
+}
+
+func writeZshCompletion(w io.Writer, flags []*flag.Flag) {
+	// Escape characters that have a special meaning in _arguments specs.
+	escape := strings.NewReplacer(`'`, `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+
+	fmt.Fprintln(w, "#compdef influx")
+	fmt.Fprintln(w, "_arguments \\")
+	for i, f := range flags {
+		spec := fmt.Sprintf("-%s[%s]", f.Name, escape.Replace(f.Usage))
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+			spec += fmt.Sprintf(":%s:", f.Name)
+			if values, ok := completionValues[f.Name]; ok {
+				spec += "(" + strings.Join(values, " ") + ")"
+			}
+		}
+		sep := " \\"
+		if i == len(flags)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(w, "  '%s'%s\n", spec, sep)
+	}
+}
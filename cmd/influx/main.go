@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/influxdata/influxdb/client"
 	"github.com/influxdata/influxdb/cmd/influx/cli"
@@ -41,18 +42,45 @@ func main() {
 	fs := flag.NewFlagSet("InfluxDB shell version "+version, flag.ExitOnError)
 	fs.StringVar(&c.Host, "host", client.DefaultHost, "Influxdb host to connect to.")
 	fs.StringVar(&c.PathPrefix, "path-prefix", client.DefaultPath, "Influxdb url path prefix (for running behind proxies)")
+	hosts := fs.String("hosts", "", "Comma separated hosts, as host:port, tried in order until one answers. Overrides -host and -port.")
 	fs.IntVar(&c.Port, "port", client.DefaultPort, "Influxdb port to connect to.")
 	fs.StringVar(&c.ClientConfig.UnixSocket, "socket", "", "Influxdb unix socket to connect to.")
 	fs.StringVar(&c.ClientConfig.Username, "username", "", "Username to connect to the server.")
 	fs.StringVar(&c.ClientConfig.Password, "password", "", `Password to connect to the server.  Leaving blank will prompt for password (--password="").`)
+	fs.StringVar(&c.PasswordFile, "password-file", "", "Read the password from this file. -password @path does the same.")
+	fs.StringVar(&c.ClientConfig.Token, "token", "", "Token sent in the Authorization header. Takes precedence over username and password.")
+	fs.Func("header", `Header sent with every request, as "Key: Value". May be repeated.`, c.AddHeader)
 	fs.StringVar(&c.Database, "database", c.Database, "Database to connect to the server.")
+	fs.BoolVar(&c.ContinueOnError, "continue-on-error", false, "Keep executing the statements given to -execute or read from stdin after one fails.")
+	fs.BoolVar(&c.SkipDBCheck, "skip-db-check", false, "Use a database or retention policy even if its existence cannot be verified.")
 	fs.Var(&c.Type, "type", "query language for executing commands or invoking the REPL: influxql, flux")
 	fs.BoolVar(&c.Ssl, "ssl", false, "Use https for connecting to cluster.")
 	fs.BoolVar(&c.ClientConfig.UnsafeSsl, "unsafeSsl", false, "Set this when connecting to the cluster using https and not use SSL verification.")
-	fs.StringVar(&c.Format, "format", defaultFormat, "Format specifies the format of the server responses:  json, csv, or column.")
+	fs.BoolVar(&c.ClientConfig.AcceptGzip, "accept-gzip", false, "Request gzip compressed query responses from the server.")
+	fs.BoolVar(&c.ClientConfig.GzipWrites, "gzip-writes", false, "Compress the body of write requests with gzip.")
+	fs.IntVar(&c.ClientConfig.MaxIdleConns, "max-idle-conns", 0, "Maximum number of idle connections kept open.  Zero means no limit.")
+	fs.IntVar(&c.ClientConfig.MaxIdleConnsPerHost, "max-idle-conns-per-host", 0, "Maximum number of idle connections kept open per host.  Zero uses the Go default of 2.")
+	fs.DurationVar(&c.ClientConfig.IdleConnTimeout, "idle-conn-timeout", 0, "How long an idle connection is kept open.  Zero means no limit.")
+	fs.BoolVar(&c.ClientConfig.DisableKeepAlives, "disable-keepalives", false, "Disable HTTP keep-alives and use a new connection for every request.")
+	fs.Int64Var(&c.ClientConfig.MaxResponseSize, "max-response-size", 0, "Maximum size of a query response in bytes. Zero means no limit.")
+	fs.IntVar(&c.ConnectRetries, "connect-retries", 0, "How many more times the initial connection is tried if it fails.")
+	fs.DurationVar(&c.ConnectInterval, "connect-retry-interval", time.Second, "Delay between the attempts of the initial connection.")
+	fs.IntVar(&c.ClientConfig.Retries, "retries", 0, "How many times a query that cannot reach the server is retried.")
+	fs.DurationVar(&c.ClientConfig.RetryBackoff, "retry-backoff", 200*time.Millisecond, "Delay before the first retry, doubled after each retry, with jitter.")
+	fs.IntVar(&c.ClientConfig.BreakerThreshold, "breaker-threshold", 0, "Consecutive query failures that open the circuit breaker. Zero disables it.")
+	fs.DurationVar(&c.ClientConfig.BreakerWindow, "breaker-window", time.Minute, "Window the consecutive failures opening the circuit breaker must fall within.")
+	fs.DurationVar(&c.ClientConfig.BreakerCooldown, "breaker-cooldown", 30*time.Second, "How long queries fail fast once the circuit breaker opens.")
+	fs.StringVar(&c.Format, "format", defaultFormat, "Format specifies the format of the server responses:  json, ndjson, csv, column, markdown, or promql-style.")
 	fs.StringVar(&c.ClientConfig.Precision, "precision", defaultPrecision, "Precision specifies the format of the timestamp:  rfc3339,h,m,s,ms,u or ns.")
 	fs.StringVar(&c.ClientConfig.WriteConsistency, "consistency", "all", "Set write consistency level: any, one, quorum, or all.")
+	fs.StringVar(&c.Prompt, "prompt", cli.DefaultPrompt, "Prompt template. {db}, {rp}, {host} and {fmt} are replaced by the current settings.")
 	fs.BoolVar(&c.Pretty, "pretty", false, "Turns on pretty print for the json format.")
+	timing := fs.String("timing", "", "Set to verbose to print the dns, connect, tls, server wait and total time of each InfluxQL query.")
+	fs.DurationVar(&c.SlowLog, "log-queries-slower-than", 0, "Print a SLOW: warning to stderr for InfluxQL queries taking longer than this. Zero disables it.")
+	fs.BoolVar(&c.Debug, "debug", false, "Include the stack trace when a command panics.")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", 0, "Exit the interactive shell when no command is entered for this long. Zero disables it.")
+	fs.BoolVar(&c.Quiet, "quiet", false, "Print only query results, without the elapsed time, stats or the startup banner.")
+	compact := fs.Bool("compact", false, "Turns on compact output for the json format.")
 	fs.IntVar(&c.NodeID, "node", 0, "Specify the node that data should be retrieved from (enterprise only).")
 	fs.StringVar(&c.Execute, "execute", c.Execute, "Execute command and quit.")
 	fs.BoolVar(&c.ShowVersion, "version", false, "Displays the InfluxDB version.")
@@ -60,6 +88,17 @@ func main() {
 	fs.IntVar(&c.ImporterConfig.PPS, "pps", defaultPPS, "How many points per second the import will allow.  By default it is zero and will not throttle importing.")
 	fs.StringVar(&c.ImporterConfig.Path, "path", "", "path to the file to import")
 	fs.BoolVar(&c.ImporterConfig.Compressed, "compressed", false, "set to true if the import file is compressed")
+	fs.IntVar(&c.ImporterConfig.BatchSize, "batch-size", 5000, "How many points the import writes per request.")
+	fs.StringVar(&c.ImporterConfig.Checkpoint, "import-checkpoint", "", "Path of a checkpoint file used to resume an interrupted import.")
+	fs.IntVar(&c.ImporterConfig.Workers, "import-workers", 1, "How many batches the import writes concurrently.")
+	fs.BoolVar(&c.ImporterConfig.ReportDuplicates, "import-report-duplicates", false, "Report points with the same series and timestamp as an earlier point in the import file.")
+	fs.StringVar(&c.S3Endpoint, "s3-endpoint", "", "URL of S3-compatible storage written to by output s3://. Defaults to AWS_ENDPOINT_URL, or AWS.")
+	fs.StringVar(&c.S3Region, "s3-region", "", "Region of the bucket written to by output s3://. Defaults to AWS_REGION, or us-east-1.")
+	fs.StringVar(&c.DiffHost, "diff-host", "", "Second server compared against by the diff command, as host:port.")
+	fs.Float64Var(&c.DiffTolerance, "diff-tolerance", 0, "Largest difference between two numbers that the diff command treats as equal.")
+	fs.BoolVar(&c.CreateDatabase, "create-db", false, "Create the target database of INSERT statements and imports if it does not exist.")
+	fs.BoolVar(&c.AutoDatabase, "auto-db", false, "Send an INSERT without a database to the only database of the server, if there is exactly one.")
+	fs.BoolVar(&c.CheckFieldTypes, "check-field-types", false, "Warn before an INSERT writes a field with a type other than the one the server has.")
 
 	// Define our own custom usage to print
 	fs.Usage = func() {
@@ -70,32 +109,108 @@ func main() {
 			Path that follows the host and port
   -host 'host name'
 			Host to connect to.
+  -hosts 'host:port,host:port'
+			Hosts tried in order until one answers, for failover between servers.  Overrides
+			-host and -port.  Once pings are enabled with the keepalive command, a dropped
+			connection makes the shell reconnect to the next host before the following command.
   -port 'port #'
 			Port to connect to.
   -socket 'unix domain socket'
 			Unix socket to connect to.
   -database 'database name'
 			Database to connect to the server.
+  -skip-db-check
+			Use a database or retention policy even if its existence cannot be verified.
   -password 'password'
 			Password to connect to the server.  Leaving blank will prompt for password (--password '').
+  -password-file 'path'
+			Read the password from a file, such as one mounted by a secret manager.  A trailing
+			newline is removed.  '-password @path' does the same.
   -username 'username'
 			Username to connect to the server.
+  -token 'token'
+			Token sent in the Authorization header. Takes precedence over username and password.
+  -header 'Key: Value'
+			Header sent with every request, e.g. for a proxy in front of the server.  May be
+			repeated.  Host and Content-Length cannot be set.
   -ssl
 			Use https for requests.
   -unsafeSsl
 			Set this when connecting to the cluster using https and not use SSL verification.
+  -accept-gzip
+			Request gzip compressed query responses from the server.
+  -gzip-writes
+			Compress the body of INSERT and import write requests with gzip. Writes are sent
+			uncompressed if the server rejects a compressed one.
+  -max-idle-conns 'count'
+			Maximum number of idle connections kept open.  Zero means no limit.
+  -max-idle-conns-per-host 'count'
+			Maximum number of idle connections kept open per host.  Zero uses the Go default of 2.
+  -idle-conn-timeout 'duration'
+			How long an idle connection is kept open, for example 30s.  Zero means no limit.
+  -disable-keepalives
+			Disable HTTP keep-alives and use a new connection for every request.
+  -max-response-size 'bytes'
+			Fail queries whose decoded response is larger than this many bytes, guarding against
+			running out of memory.  Zero, the default, means no limit.
+  -connect-retries 'count'
+			How many more times the initial connection is tried if it fails, for when the server
+			may still be starting.  SSL hints are only given after the last attempt.  Defaults to 0.
+  -connect-retry-interval 'duration'
+			Delay between the attempts of the initial connection.  Defaults to 1s.
+  -retries 'count'
+			How many times a query is retried when the server cannot be reached or answers with
+			502, 503 or 504.  Defaults to 0.
+  -retry-backoff 'duration'
+			Delay before the first retry, doubled after each further retry.  Each delay is
+			randomly shortened by up to half so clients do not retry in step.  Defaults to 200ms.
+  -breaker-threshold 'count'
+			Open a circuit breaker after this many consecutive failed queries.  While it is open,
+			queries fail immediately instead of contacting the server.  Zero, the default,
+			disables the breaker.
+  -breaker-window 'duration'
+			The consecutive failures must happen within this window.  Defaults to 1m.
+  -breaker-cooldown 'duration'
+			How long the breaker stays open before letting a query through.  Defaults to 30s.
   -execute 'command'
 			Execute command and quit.
+  -continue-on-error
+			Keep executing the statements given to -execute or read from stdin after one fails.
+			By default the first failing statement stops the shell.  The exit code reflects the
+			first failure.
   -type 'influxql|flux'
 			Type specifies the query language for executing commands or when invoking the REPL.
-  -format 'json|csv|column'
-			Format specifies the format of the server responses:  json, csv, or column.
+  -format 'json|ndjson|csv|column|markdown|promql-style'
+			Format specifies the format of the server responses:  json, ndjson, csv, column, markdown,
+			or promql-style.
   -precision 'rfc3339|h|m|s|ms|u|ns'
 			Precision specifies the format of the timestamp:  rfc3339, h, m, s, ms, u or ns.
   -consistency 'any|one|quorum|all'
 			Set write consistency level: any, one, quorum, or all
+  -node 'node id'
+			Specify the node that data should be retrieved from (enterprise only).
+  -prompt 'template'
+			Prompt shown by the interactive shell.  {db}, {rp}, {host} and {fmt} are replaced by the
+			current database, retention policy, host and format.  Defaults to "> ".
   -pretty
 			Turns on pretty print for the json format.
+  -timing verbose
+			Print the dns, connect, tls, server wait and total time of each InfluxQL query, to tell
+			a slow network from a slow server.  Toggle it in the shell with 'timing verbose|off'.
+  -log-queries-slower-than 'duration'
+			Print a SLOW: warning with the query text to stderr for every InfluxQL query taking longer
+			than this, for example 500ms.  Set it in the shell with 'slowlog'.  Zero disables it.
+  -debug
+			Include the stack trace when a command fails with a panic.  Without it only the
+			command and the panic are printed.
+  -idle-timeout 'duration'
+			Exit the interactive shell when no command is entered for this long, for example 15m.
+			A running command is never interrupted.  Zero, the default, disables it.
+  -quiet
+			Print only query results: the elapsed time after each query, the stats line and the
+			"Connected to" banner are suppressed.  Toggle it in the shell with 'quiet'.
+  -compact
+			Turns on compact output for the json format.  Ignored when -pretty is set.
   -import
 			Import a previous database export from file
   -pps
@@ -104,6 +219,41 @@ func main() {
 			Path to file to import
   -compressed
 			Set to true if the import file is compressed
+  -batch-size
+			How many points the import writes per request.  Defaults to 5000.
+  -import-checkpoint 'path'
+			Path of a checkpoint file used to resume an interrupted import.  Progress is recorded
+			after each written batch and the file is removed once the import completes.
+  -import-workers
+			How many batches the import writes concurrently.  Defaults to 1.  With more than one
+			worker the import stops reading after the first failed batch.
+  -import-report-duplicates
+			Report points with the same series and timestamp as an earlier point in the import
+			file, with their line numbers.  Every point key is kept in memory, so this is off by
+			default.
+  -s3-endpoint 'url'
+			URL of S3-compatible storage, such as http://localhost:9000, that 'output <format>
+			s3://bucket/key' uploads to.  Defaults to AWS_ENDPOINT_URL, or AWS itself.  Credentials
+			are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+  -s3-region 'region'
+			Region of the bucket output uploads to.  Defaults to AWS_REGION, or us-east-1.
+  -diff-host 'host:port'
+			Second server the diff command runs queries against.  Credentials and the ssl setting
+			are shared with the main connection.
+  -diff-tolerance 'number'
+			Largest difference between two numbers that the diff command treats as equal.
+			Defaults to 0.
+  -create-db
+			Create the target database of INSERT INTO statements and imports if it does not exist,
+			and the target retention policy of INSERT INTO statements with an infinite duration.
+  -auto-db
+			When an INSERT has no database and none is selected with use, select the only database
+			of the server, ignoring _internal.  With more or no databases, the INSERT fails with a
+			list of the available ones.
+  -check-field-types
+			Before an INSERT, compare the types of its fields with SHOW FIELD KEYS and warn about
+			fields the server will reject for having a different type.  The point is still sent.
+			Field types are queried once per measurement and cached.
 
 Examples:
 
@@ -111,9 +261,27 @@ Examples:
     $ influx -database 'metrics' -execute 'select * from cpu' -format 'json' -pretty
 
     # Connect to a specific database on startup and set database context:
-    $ influx -database 'metrics' -host 'localhost' -port '8086'`)
+    $ influx -database 'metrics' -host 'localhost' -port '8086'
+
+    # Run a script from stdin; leading '-- format: csv' and '-- chunk: 1000' comments set those options:
+    $ influx -database 'metrics' < report.iql`)
+	}
+
+	// The completion subcommand is intentionally left out of the usage.
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		if err := writeCompletion(os.Stdout, fs, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
+
 	fs.Parse(os.Args[1:])
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "execute" {
+			c.ExecuteSet = true
+		}
+	})
 
 	argsNotParsed := fs.Args()
 	if len(argsNotParsed) > 0 {
@@ -122,6 +290,23 @@ Examples:
 		os.Exit(1)
 	}
 
+	if *compact {
+		c.JSONStyle = cli.JSONStyleCompact
+	}
+	switch strings.ToLower(*timing) {
+	case "", "off":
+	case "verbose":
+		c.VerboseTiming = true
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -timing %q: must be verbose or off\n", *timing)
+		os.Exit(1)
+	}
+	for _, h := range strings.Split(*hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			c.Hosts = append(c.Hosts, h)
+		}
+	}
+
 	if c.ShowVersion {
 		c.Version()
 		os.Exit(0)
@@ -129,6 +314,6 @@ Examples:
 
 	if err := c.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"math/rand"
 	"os"
 	"os/signal"
+	"runtime"
 	"syscall"
 	"time"
 
@@ -21,9 +23,10 @@ import (
 
 // These variables are populated via the Go linker.
 var (
-	version string
-	commit  string
-	branch  string
+	version   string
+	commit    string
+	branch    string
+	buildTime string
 )
 
 func init() {
@@ -37,6 +40,9 @@ func init() {
 	if branch == "" {
 		branch = "unknown"
 	}
+	if buildTime == "" {
+		buildTime = "unknown"
+	}
 
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 }
@@ -80,8 +86,10 @@ func (m *Main) Run(args ...string) error {
 		cmd.Version = version
 		cmd.Commit = commit
 		cmd.Branch = branch
+		cmd.BuildTime = buildTime
 
 		if err := cmd.Run(args...); err != nil {
+			cmd.Logger.Sync()
 			return fmt.Errorf("run: %s", err)
 		}
 
@@ -106,6 +114,9 @@ func (m *Main) Run(args ...string) error {
 			cmd.Logger.Info("Server shutdown completed")
 		}
 
+		// Flush log entries still buffered.
+		cmd.Logger.Sync()
+
 		// goodbye.
 
 	case "backup":
@@ -155,11 +166,22 @@ func NewVersionCommand() *VersionCommand {
 func (cmd *VersionCommand) Run(args ...string) error {
 	// Parse flags in case -h is specified.
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "")
 	fs.Usage = func() { fmt.Fprintln(cmd.Stderr, versionUsage) }
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
+	if *asJSON {
+		return json.NewEncoder(cmd.Stdout).Encode(struct {
+			Version   string `json:"version"`
+			Branch    string `json:"branch"`
+			Commit    string `json:"commit"`
+			BuildTime string `json:"buildTime"`
+			GoVersion string `json:"goVersion"`
+		}{version, branch, commit, buildTime, runtime.Version()})
+	}
+
 	// Print version info.
 	fmt.Fprintf(cmd.Stdout, "InfluxDB v%s (git: %s %s)\n", version, branch, commit)
 
@@ -168,5 +190,9 @@ func (cmd *VersionCommand) Run(args ...string) error {
 
 var versionUsage = `Displays the InfluxDB version, build branch and git commit hash.
 
-Usage: influxd version
+Usage: influxd version [flags]
+
+    -json
+            Print the version, branch, commit, build time and Go version
+            as a JSON object.
 `
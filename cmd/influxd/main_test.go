@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"testing"
+)
+
+func TestVersionCommand_JSON(t *testing.T) {
+	var stdout bytes.Buffer
+	cmd := NewVersionCommand()
+	cmd.Stdout = &stdout
+	if err := cmd.Run("-json"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %s\n%s", err, stdout.String())
+	}
+	// Without linker flags the build information is "unknown".
+	exp := map[string]string{
+		"version":   "unknown",
+		"branch":    "unknown",
+		"commit":    "unknown",
+		"buildTime": "unknown",
+		"goVersion": runtime.Version(),
+	}
+	if len(got) != len(exp) {
+		t.Fatalf("got fields %v, exp %v", got, exp)
+	}
+	for k, v := range exp {
+		if got[k] != v {
+			t.Errorf("%s: got %q, exp %q", k, got[k], v)
+		}
+	}
+}
@@ -48,10 +48,12 @@ type Command struct {
 
 	Logger      *zap.Logger
 	atomicLevel zap.AtomicLevel
+	stopLogger  func() error // flushes and stops buffered logging, set by Run
 
 	Server *Server
 
-	watcher *fsnotify.Watcher
+	watcher       *fsnotify.Watcher
+	noConfigWatch bool
 
 	// How to get environment variables. Normally set to os.Getenv, except for tests.
 	Getenv func(string) string
@@ -78,26 +80,41 @@ func (cmd *Command) Run(args ...string) error {
 		return err
 	}
 
+	cmd.noConfigWatch = options.NoConfigWatch
 	config, err := cmd.ParseConfig(options.GetConfigPath())
 	if err != nil {
 		return fmt.Errorf("parse config: %s", err)
 	}
 
 	// Apply any environment variables on top of the parsed config
-	if err := config.ApplyEnvOverrides(cmd.Getenv); err != nil {
+	if err := config.ApplyEnvOverrides(cmd.Getenv, options.EnvPrefix); err != nil {
 		return fmt.Errorf("apply env config: %v", err)
 	}
 
+	if options.ReadOnly {
+		config.ReadOnly = true
+	}
+	if options.MaxProcs > 0 {
+		config.MaxProcs = options.MaxProcs
+	}
+
 	// Validate the configuration.
 	if err := config.Validate(); err != nil {
 		return fmt.Errorf("%s. To generate a valid configuration file run `influxd config > influxdb.generated.conf`", err)
 	}
 
 	var logErr error
-	if cmd.Logger, logErr = config.Logging.NewLogger(&cmd.atomicLevel); logErr != nil {
+	if cmd.Logger, cmd.stopLogger, logErr = config.Logging.NewLogger(&cmd.atomicLevel); logErr != nil {
 		return fmt.Errorf("unable to configure logger: %w", logErr)
 	}
 
+	// Check the environment before opening the server.
+	if !options.SkipPreflight {
+		if err := cmd.preflight(config); err != nil {
+			return err
+		}
+	}
+
 	// Attempt to run pprof on :6060 before startup if debug pprof enabled.
 	if config.HTTPD.DebugPprofEnabled {
 		runtime.SetBlockProfileRate(int(1 * time.Second))
@@ -109,7 +126,7 @@ func (cmd *Command) Run(args ...string) error {
 	}
 
 	// Print sweet InfluxDB logo.
-	if !config.Logging.SuppressLogo && logger.IsTerminal(cmd.Stdout) {
+	if !config.Logging.SuppressLogo && !config.Logging.Discard() && logger.IsTerminal(cmd.Stdout) {
 		fmt.Fprint(cmd.Stdout, logo)
 	}
 
@@ -118,10 +135,20 @@ func (cmd *Command) Run(args ...string) error {
 		zap.String("version", cmd.Version),
 		zap.String("branch", cmd.Branch),
 		zap.String("commit", cmd.Commit))
+	detectedProcs := runtime.GOMAXPROCS(0)
+	if config.MaxProcs > 0 && config.MaxProcs < detectedProcs {
+		runtime.GOMAXPROCS(config.MaxProcs)
+	}
 	cmd.Logger.Info("Go runtime",
 		zap.String("version", runtime.Version()),
-		zap.Int("maxprocs", runtime.GOMAXPROCS(0)))
+		zap.Int("maxprocs", runtime.GOMAXPROCS(0)),
+		zap.Int("detected_maxprocs", detectedProcs))
+	cmd.Logger.Info("Environment overrides", zap.String("prefix", EnvPrefix(options.EnvPrefix)))
+	cmd.logLimits(config)
 	log.Printf("InfluxDB starting, pid: %d\n", os.Getpid())
+	if config.ReadOnly {
+		cmd.Logger.Warn("InfluxDB is in READ-ONLY mode: writes and statements that write will be rejected, and input listeners and continuous queries are disabled")
+	}
 
 	if config.HTTPD.PprofEnabled {
 		// Turn on block and mutex profiling.
@@ -169,11 +196,18 @@ func (cmd *Command) Close() error {
 	defer close(cmd.Closed)
 	defer cmd.removePIDFile()
 	close(cmd.closing)
-	cmd.watcher.Close()
+	if cmd.watcher != nil {
+		cmd.watcher.Close()
+	}
+	var err error
 	if cmd.Server != nil {
-		return cmd.Server.Close()
+		err = cmd.Server.Close()
 	}
-	return nil
+	// Flush the buffered log entries once the server no longer logs.
+	if cmd.stopLogger != nil {
+		cmd.stopLogger()
+	}
+	return err
 }
 
 func (cmd *Command) monitorServerErrors() {
@@ -206,6 +240,11 @@ func (cmd *Command) ParseFlags(args ...string) (Options, error) {
 	_ = fs.String("hostname", "", "")
 	fs.StringVar(&options.CPUProfile, "cpuprofile", "", "")
 	fs.StringVar(&options.MemProfile, "memprofile", "", "")
+	fs.BoolVar(&options.ReadOnly, "read-only", false, "")
+	fs.BoolVar(&options.SkipPreflight, "skip-preflight", false, "")
+	fs.IntVar(&options.MaxProcs, "max-procs", 0, "")
+	fs.StringVar(&options.EnvPrefix, "env-prefix", DefaultEnvPrefix, "")
+	fs.BoolVar(&options.NoConfigWatch, "no-config-watch", false, "")
 	fs.Usage = func() { fmt.Fprintln(cmd.Stderr, usage) }
 	if err := fs.Parse(args); err != nil {
 		return Options{}, err
@@ -250,9 +289,22 @@ func (cmd *Command) ParseConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if cmd.noConfigWatch || config.NoConfigWatch {
+		cmd.Logger.Info("Configuration file watcher disabled")
+		return config, nil
+	}
+	if err := cmd.watchConfig(path); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// watchConfig reloads the configuration of the server when the file at path
+// is written.
+func (cmd *Command) watchConfig(path string) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	cmd.watcher = watcher
 
@@ -287,11 +339,7 @@ func (cmd *Command) ParseConfig(path string) (*Config, error) {
 			}
 		}
 	}()
-	if err := cmd.watcher.Add(path); err != nil {
-		return nil, err
-	}
-
-	return config, nil
+	return cmd.watcher.Add(path)
 }
 
 const usage = `Runs the InfluxDB server.
@@ -310,14 +358,33 @@ Usage: influxd run [flags]
     -cpuprofile <path>
             Write CPU profiling information to a file.
     -memprofile <path>
-            Write memory usage information to a file.`
+            Write memory usage information to a file.
+    -read-only
+            Reject writes while still serving queries.
+    -skip-preflight
+            Skip the startup checks of directory permissions and the open
+            file limit.
+    -max-procs <n>
+            Cap GOMAXPROCS at n. Overrides max-procs in the configuration.
+    -env-prefix <prefix>
+            Read configuration overrides from environment variables starting
+            with prefix, such as INST1_HTTP_BIND_ADDRESS for a prefix of
+            INST1_. Defaults to INFLUXDB_.
+    -no-config-watch
+            Do not watch the configuration file and reload it when it is
+            written, for example in immutable deployments.`
 
 // Options represents the command line options that can be parsed.
 type Options struct {
-	ConfigPath string
-	PIDFile    string
-	CPUProfile string
-	MemProfile string
+	ConfigPath    string
+	PIDFile       string
+	CPUProfile    string
+	MemProfile    string
+	ReadOnly      bool
+	SkipPreflight bool
+	MaxProcs      int
+	EnvPrefix     string
+	NoConfigWatch bool
 }
 
 // GetConfigPath returns the config path from the options.
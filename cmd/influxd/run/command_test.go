@@ -62,3 +62,57 @@ func TestCommand_PIDFile(t *testing.T) {
 		t.Fatal("expected pid file to be removed")
 	}
 }
+
+func TestCommand_NoConfigWatch(t *testing.T) {
+	tmpdir := t.TempDir()
+	path := filepath.Join(tmpdir, "influxdb.conf")
+	if err := os.WriteFile(path, []byte("reporting-disabled = true\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []string
+	}{
+		{name: "flag", args: []string{"-config", path, "-no-config-watch"}},
+		// Without a config file no watcher is created either.
+		{name: "no config file", args: []string{"-config", os.DevNull}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := run.NewCommand()
+			cmd.Getenv = testGetenv(t.TempDir())
+			if err := cmd.Run(tt.args...); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			// Close must not touch the watcher that was never created.
+			go cmd.Close()
+			select {
+			case <-time.After(5 * time.Second):
+				t.Fatal("unexpected timeout")
+			case <-cmd.Closed:
+			}
+		})
+	}
+}
+
+// testGetenv returns a Getenv function placing the data of a test server in
+// tmpdir and binding it to random ports.
+func testGetenv(tmpdir string) func(string) string {
+	return func(key string) string {
+		switch key {
+		case "INFLUXDB_DATA_DIR":
+			return filepath.Join(tmpdir, "data")
+		case "INFLUXDB_META_DIR":
+			return filepath.Join(tmpdir, "meta")
+		case "INFLUXDB_DATA_WAL_DIR":
+			return filepath.Join(tmpdir, "wal")
+		case "INFLUXDB_BIND_ADDRESS", "INFLUXDB_HTTP_BIND_ADDRESS":
+			return "127.0.0.1:0"
+		case "INFLUXDB_REPORTING_DISABLED":
+			return "true"
+		default:
+			return os.Getenv(key)
+		}
+	}
+}
@@ -61,6 +61,17 @@ type Config struct {
 	// BindAddress is the address that all TCP services use (Raft, Snapshot, Cluster, etc.)
 	BindAddress string `toml:"bind-address"`
 
+	// ReadOnly rejects writes, and the statements that write, while still
+	// serving queries.
+	ReadOnly bool `toml:"read-only"`
+
+	// MaxProcs caps GOMAXPROCS. Zero keeps the value detected by the runtime.
+	MaxProcs int `toml:"max-procs"`
+
+	// NoConfigWatch disables reloading the configuration when its file is
+	// written.
+	NoConfigWatch bool `toml:"no-config-watch"`
+
 	// TLS provides configuration options for all https endpoints.
 	TLS tlsconfig.Config `toml:"tls"`
 }
@@ -164,6 +175,10 @@ func (c *Config) DumpToml(outputSuffix string) error {
 
 // Validate returns an error if the config is invalid.
 func (c *Config) Validate() error {
+	if c.MaxProcs < 0 {
+		return fmt.Errorf("max-procs must not be negative: %d", c.MaxProcs)
+	}
+
 	if err := c.Meta.Validate(); err != nil {
 		return err
 	}
@@ -172,6 +187,10 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	if err := c.validateDirs(); err != nil {
+		return err
+	}
+
 	if err := c.Monitor.Validate(); err != nil {
 		return err
 	}
@@ -211,9 +230,79 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// validateDirs returns an error if any two of the meta, data and WAL
+// directories are the same or one contains the other, as the files of each
+// would be mistaken for those of the other. Paths are compared absolute, with
+// symlinks resolved.
+func (c *Config) validateDirs() error {
+	dirs := []struct{ name, path string }{
+		{"[meta] dir", c.Meta.Dir},
+		{"[data] dir", c.Data.Dir},
+		{"[data] wal-dir", c.Data.WALDir},
+	}
+	resolved := make([]string, len(dirs))
+	for i, d := range dirs {
+		resolved[i] = resolveDir(d.path)
+	}
+	for i := range dirs {
+		for j := i + 1; j < len(dirs); j++ {
+			if dirsOverlap(resolved[i], resolved[j]) {
+				return fmt.Errorf("%s %q and %s %q overlap: the meta, data and wal directories must be separate",
+					dirs[i].name, dirs[i].path, dirs[j].name, dirs[j].path)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveDir returns path made absolute, with the symlinks of its longest
+// existing part resolved, as the directories are only created at startup.
+func resolveDir(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	for dir, rest := path, ""; ; {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+// dirsOverlap reports whether a and b are the same directory or one contains
+// the other.
+func dirsOverlap(a, b string) bool {
+	inside := func(dir, path string) bool {
+		rel, err := filepath.Rel(dir, path)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	return inside(a, b) || inside(b, a)
+}
+
+// DefaultEnvPrefix is the prefix of the environment variables that override
+// the configuration when no other prefix is given.
+const DefaultEnvPrefix = "INFLUXDB_"
+
 // ApplyEnvOverrides apply the environment configuration on top of the config.
-func (c *Config) ApplyEnvOverrides(getenv func(string) string) error {
-	return itoml.ApplyEnvOverrides(getenv, "INFLUXDB", c)
+// Only variables starting with prefix are used, so that a prefix of "INST1_"
+// reads the bind address from INST1_HTTP_BIND_ADDRESS. An empty prefix means
+// DefaultEnvPrefix.
+func (c *Config) ApplyEnvOverrides(getenv func(string) string, prefix string) error {
+	return itoml.ApplyEnvOverrides(getenv, strings.TrimSuffix(EnvPrefix(prefix), "_"), c)
+}
+
+// EnvPrefix returns prefix normalized to end in an underscore, or
+// DefaultEnvPrefix if prefix is empty.
+func EnvPrefix(prefix string) string {
+	if prefix == "" {
+		return DefaultEnvPrefix
+	}
+	return strings.TrimSuffix(prefix, "_") + "_"
 }
 
 // Diagnostics returns a diagnostics representation of Config.
@@ -30,6 +30,7 @@ func (cmd *PrintConfigCommand) Run(args ...string) error {
 	// Parse command flags.
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
 	configPath := fs.String("config", "", "")
+	envPrefix := fs.String("env-prefix", DefaultEnvPrefix, "")
 	fs.Usage = func() { fmt.Fprintln(cmd.Stderr, printConfigUsage) }
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -43,7 +44,7 @@ func (cmd *PrintConfigCommand) Run(args ...string) error {
 	}
 
 	// Apply any environment variables on top of the parsed config
-	if err := config.ApplyEnvOverrides(os.Getenv); err != nil {
+	if err := config.ApplyEnvOverrides(os.Getenv, *envPrefix); err != nil {
 		return fmt.Errorf("apply env config: %v", err)
 	}
 
@@ -89,4 +90,7 @@ Usage: influxd config [flags]
             is present at any of these locations.
             Disable the automatic loading of a configuration file using
             the null device (such as /dev/null).
+    -env-prefix <prefix>
+            Apply overrides from environment variables starting with prefix.
+            Defaults to INFLUXDB_.
 `
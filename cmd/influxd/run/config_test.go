@@ -5,6 +5,8 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -193,7 +195,7 @@ min-version = "tls1.0"
 		return ""
 	}
 
-	if err := c.ApplyEnvOverrides(getenv); err != nil {
+	if err := c.ApplyEnvOverrides(getenv, ""); err != nil {
 		t.Fatalf("failed to apply env overrides: %v", err)
 	}
 
@@ -242,6 +244,32 @@ min-version = "tls1.0"
 	}
 }
 
+func TestConfig_Parse_EnvOverridePrefix(t *testing.T) {
+	getenv := func(s string) string {
+		switch s {
+		case "INST1_HTTP_BIND_ADDRESS":
+			return ":9086"
+		case "INFLUXDB_HTTP_BIND_ADDRESS":
+			return ":8086"
+		}
+		return ""
+	}
+
+	for _, prefix := range []string{"INST1_", "INST1"} {
+		c := run.NewConfig()
+		if err := c.ApplyEnvOverrides(getenv, prefix); err != nil {
+			t.Fatalf("failed to apply env overrides: %v", err)
+		}
+		if c.HTTPD.BindAddress != ":9086" {
+			t.Fatalf("prefix %q: unexpected http bind address: %s", prefix, c.HTTPD.BindAddress)
+		}
+	}
+
+	if got := run.EnvPrefix(""); got != run.DefaultEnvPrefix {
+		t.Fatalf("unexpected default prefix: %s", got)
+	}
+}
+
 func TestConfig_ValidateNoServiceConfigured(t *testing.T) {
 	var c run.Config
 	if _, err := toml.Decode(`
@@ -279,6 +307,68 @@ enabled = false
 	}
 }
 
+func TestConfig_ValidateMaxProcs(t *testing.T) {
+	c := run.NewConfig()
+	if _, err := toml.Decode(`
+max-procs = -1
+
+[meta]
+dir = "foo"
+
+[data]
+dir = "foo"
+wal-dir = "foo"
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("got nil, expected error")
+	}
+}
+
+func TestConfig_ValidateDirs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "data"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "data"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		meta, data, wal string
+		conflict        string
+	}{
+		{meta: "meta", data: "data", wal: "wal"},
+		{meta: "meta", data: "data", wal: "data-wal"},
+		{meta: "meta", data: "data", wal: "data", conflict: `[data] dir "DIR/data" and [data] wal-dir "DIR/data"`},
+		{meta: "meta", data: "data", wal: "data/wal", conflict: `[data] dir "DIR/data" and [data] wal-dir "DIR/data/wal"`},
+		{meta: "data/../wal", data: "data", wal: "wal", conflict: `[meta] dir "DIR/data/../wal" and [data] wal-dir "DIR/wal"`},
+		{meta: "meta", data: "data", wal: "link/wal", conflict: `[data] dir "DIR/data" and [data] wal-dir "DIR/link/wal"`},
+	} {
+		c := run.NewConfig()
+		c.Meta.Dir = filepath.Join(dir, tt.meta)
+		c.Data.Dir = filepath.Join(dir, tt.data)
+		c.Data.WALDir = filepath.Join(dir, tt.wal)
+		// filepath.Join cleans the paths, so spell out the one with "..".
+		if strings.Contains(tt.meta, "..") {
+			c.Meta.Dir = dir + "/" + tt.meta
+		}
+
+		err := c.Validate()
+		if tt.conflict == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected error: %s", tt, err)
+			}
+			continue
+		}
+		if exp := strings.ReplaceAll(tt.conflict, "DIR", dir); err == nil || !strings.Contains(err.Error(), exp) {
+			t.Errorf("%+v: got %v, exp an error listing %s", tt, err, exp)
+		}
+	}
+}
+
 func TestConfig_DeprecatedOptions(t *testing.T) {
 	// Parse configuration.
 	var c run.Config
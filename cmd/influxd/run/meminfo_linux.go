@@ -0,0 +1,47 @@
+package run
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemMemory returns the total and available memory in bytes as reported
+// by /proc/meminfo.
+func systemMemory() (total, available uint64, ok bool) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	var found int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Lines have the form "MemTotal:       16314552 kB".
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		var dst *uint64
+		switch fields[0] {
+		case "MemTotal:":
+			dst = &total
+		case "MemAvailable:":
+			dst = &available
+		default:
+			continue
+		}
+		n, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			n *= 1024
+		}
+		*dst = n
+		found++
+	}
+	return total, available, found == 2 && scanner.Err() == nil
+}
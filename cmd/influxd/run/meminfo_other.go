@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package run
+
+// systemMemory is only implemented on Linux.
+func systemMemory() (total, available uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,67 @@
+package run
+
+import (
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// minOpenFiles is the open file limit below which a warning is logged. Each
+// shard keeps its TSM, index and WAL files open, so low limits fail at runtime.
+const minOpenFiles = 65536
+
+// preflight checks the environment the server needs before it is opened so
+// problems are reported clearly instead of as errors from deep in Open.
+// Unwritable directories are errors; a low open file limit is a warning.
+func (cmd *Command) preflight(config *Config) error {
+	dirs := []struct {
+		name, path string
+	}{
+		{"meta", config.Meta.Dir},
+		{"data", config.Data.Dir},
+		{"wal", config.Data.WALDir},
+	}
+	for _, d := range dirs {
+		if err := checkDirWritable(d.path); err != nil {
+			return fmt.Errorf("preflight: %s directory %q is not writable: %s. Fix the permissions or run with -skip-preflight to bypass this check", d.name, d.path, err)
+		}
+	}
+
+	if limit, ok := openFileLimit(); ok && limit < minOpenFiles {
+		cmd.Logger.Warn("Open file limit is low; raise it with ulimit -n or LimitNOFILE to avoid 'too many open files' errors",
+			zap.Uint64("limit", limit),
+			zap.Uint64("recommended", minOpenFiles))
+	}
+	return nil
+}
+
+// logLimits logs the resource limits of the process next to the configured
+// cache sizes to help diagnose "too many open files" and out of memory
+// crashes. Limits that cannot be read on this platform are left out.
+func (cmd *Command) logLimits(config *Config) {
+	fields := []zap.Field{
+		zap.Uint64("cache_max_memory_size", uint64(config.Data.CacheMaxMemorySize)),
+		zap.Uint64("cache_snapshot_memory_size", uint64(config.Data.CacheSnapshotMemorySize)),
+	}
+	if soft, hard, ok := openFileLimits(); ok {
+		fields = append(fields, zap.Uint64("open_files_soft_limit", soft), zap.Uint64("open_files_hard_limit", hard))
+	}
+	if total, available, ok := systemMemory(); ok {
+		fields = append(fields, zap.Uint64("memory_total", total), zap.Uint64("memory_available", available))
+	}
+	cmd.Logger.Info("Resource limits", fields...)
+}
+
+// checkDirWritable creates dir if needed and verifies a file can be created in it.
+func checkDirWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".preflight-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
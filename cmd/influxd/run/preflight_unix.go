@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package run
+
+import "syscall"
+
+// openFileLimit returns the soft limit on open files for the process.
+func openFileLimit() (uint64, bool) {
+	soft, _, ok := openFileLimits()
+	return soft, ok
+}
+
+// openFileLimits returns the soft and hard limits on open files.
+func openFileLimits() (soft, hard uint64, ok bool) {
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, 0, false
+	}
+	return uint64(rlim.Cur), uint64(rlim.Max), true
+}
@@ -0,0 +1,11 @@
+package run
+
+// openFileLimit is not available on Windows.
+func openFileLimit() (uint64, bool) {
+	return 0, false
+}
+
+// openFileLimits is not available on Windows.
+func openFileLimits() (soft, hard uint64, ok bool) {
+	return 0, 0, false
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/influxdata/influxdb/logger"
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/monitor"
+	"github.com/influxdata/influxdb/pkg/pool"
 	"github.com/influxdata/influxdb/query"
 	"github.com/influxdata/influxdb/services/collectd"
 	"github.com/influxdata/influxdb/services/continuous_querier"
@@ -65,6 +66,9 @@ type Server struct {
 
 	Logger *zap.Logger
 
+	// stopAccessLogger flushes and stops the access logger of the HTTP service.
+	stopAccessLogger func() error
+
 	MetaClient *meta.Client
 
 	TSDBStore     *tsdb.Store
@@ -210,6 +214,7 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 		MaxSelectPointN:     c.Coordinator.MaxSelectPointN,
 		MaxSelectSeriesN:    c.Coordinator.MaxSelectSeriesN,
 		MaxSelectBucketsN:   c.Coordinator.MaxSelectBucketsN,
+		ReadOnly:            c.ReadOnly,
 	}
 	s.QueryExecutor.TaskManager.QueryTimeout = time.Duration(c.Coordinator.QueryTimeout)
 	s.QueryExecutor.TaskManager.LogQueriesAfter = time.Duration(c.Coordinator.LogQueriesAfter)
@@ -247,7 +252,17 @@ func (s *Server) appendSnapshotterService() {
 	s.SnapshotterService = srv
 }
 
+// reloadDrainTimeout is how long ReloadConfig waits for the tasks of
+// pkg/pool to finish before applying the new configuration anyway.
+const reloadDrainTimeout = 10 * time.Second
+
+// ReloadConfig replaces the configuration. The worker pool stops accepting
+// tasks and the running ones are drained first, so that no task runs
+// against the old settings once the new ones apply.
 func (s *Server) ReloadConfig(c *Config) {
+	if err := pool.Drain(reloadDrainTimeout); err != nil {
+		s.Logger.Warn("Applying the reloaded configuration with pool tasks still running", zap.Error(err))
+	}
 	s.config = c
 }
 
@@ -285,14 +300,16 @@ func (s *Server) appendHTTPDService(c httpd.Config) {
 	srv.Handler.PointsWriter = s.PointsWriter
 	srv.Handler.Version = s.buildInfo.Version
 	srv.Handler.BuildType = "OSS"
+	srv.Handler.ReadOnly = s.config.ReadOnly
 	ss := storage.NewStore(s.TSDBStore, s.MetaClient)
 	srv.Handler.Store = ss
 	if s.config.HTTPD.FluxEnabled {
 		srv.Handler.Controller = control.NewController(s.MetaClient, reads.NewReader(ss), authorizer, c.AuthEnabled, s.Logger)
 	}
 
-	if logger, err := s.config.Logging.NewAccessLogger(); err == nil {
+	if logger, stop, err := s.config.Logging.NewAccessLogger(); err == nil {
 		srv.Handler.AccessLogger = logger
+		s.stopAccessLogger = stop
 	}
 
 	s.Services = append(s.Services, srv)
@@ -394,24 +411,34 @@ func (s *Server) Open() error {
 	s.appendMonitorService()
 	s.appendPrecreatorService(s.config.Precreator)
 	s.appendSnapshotterService()
-	s.appendContinuousQueryService(s.config.ContinuousQuery)
+	if s.config.ReadOnly {
+		// Continuous queries write their results.
+		s.Logger.Info("Continuous queries disabled in read-only mode")
+	} else {
+		s.appendContinuousQueryService(s.config.ContinuousQuery)
+	}
 	s.appendHTTPDService(s.config.HTTPD)
 	s.appendRetentionPolicyService(s.config.Retention)
-	for _, i := range s.config.GraphiteInputs {
-		if err := s.appendGraphiteService(i); err != nil {
-			return err
+	if s.config.ReadOnly {
+		// The input listeners only accept writes.
+		s.Logger.Info("Input listeners disabled in read-only mode")
+	} else {
+		for _, i := range s.config.GraphiteInputs {
+			if err := s.appendGraphiteService(i); err != nil {
+				return err
+			}
 		}
-	}
-	for _, i := range s.config.CollectdInputs {
-		s.appendCollectdService(i)
-	}
-	for _, i := range s.config.OpenTSDBInputs {
-		if err := s.appendOpenTSDBService(i); err != nil {
-			return err
+		for _, i := range s.config.CollectdInputs {
+			s.appendCollectdService(i)
+		}
+		for _, i := range s.config.OpenTSDBInputs {
+			if err := s.appendOpenTSDBService(i); err != nil {
+				return err
+			}
+		}
+		for _, i := range s.config.UDPInputs {
+			s.appendUDPService(i)
 		}
-	}
-	for _, i := range s.config.UDPInputs {
-		s.appendUDPService(i)
 	}
 
 	s.Subscriber.MetaClient = s.MetaClient
@@ -477,6 +504,11 @@ func (s *Server) Close() error {
 		service.Close()
 	}
 
+	// The HTTP service is closed, so nothing logs to the access log anymore.
+	if s.stopAccessLogger != nil {
+		s.stopAccessLogger()
+	}
+
 	s.config.deregisterDiagnostics(s.Monitor)
 
 	if s.PointsWriter != nil {
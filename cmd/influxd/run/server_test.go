@@ -0,0 +1,40 @@
+package run_test
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/cmd/influxd/run"
+	"github.com/influxdata/influxdb/pkg/pool"
+)
+
+func TestServer_ReloadConfig_DrainsPool(t *testing.T) {
+	tmpdir := t.TempDir()
+	c := run.NewConfig()
+	c.Meta.Dir = filepath.Join(tmpdir, "meta")
+	c.Data.Dir = filepath.Join(tmpdir, "data")
+	c.Data.WALDir = filepath.Join(tmpdir, "wal")
+	s, err := run.NewServer(c, &run.BuildInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	var finished int32
+	if err := pool.Submit(func() {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	// The reload waits for the running task before applying the new config.
+	s.ReloadConfig(run.NewConfig())
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Fatal("ReloadConfig returned before the running pool task")
+	}
+}
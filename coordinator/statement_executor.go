@@ -26,6 +26,10 @@ import (
 // when a database has not been provided.
 var ErrDatabaseNameRequired = errors.New("database name required")
 
+// ErrReadOnly is returned when executing statements that write while the
+// server is in read-only mode.
+var ErrReadOnly = errors.New("writes are disabled: the server is in read-only mode")
+
 type pointsWriter interface {
 	WritePointsInto(*IntoWriteRequest) error
 }
@@ -58,10 +62,18 @@ type StatementExecutor struct {
 	MaxSelectPointN   int
 	MaxSelectSeriesN  int
 	MaxSelectBucketsN int
+
+	// Reject statements that write data or metadata, such as SELECT INTO
+	// and CREATE DATABASE, with ErrReadOnly.
+	ReadOnly bool
 }
 
 // ExecuteStatement executes the given statement with the given execution context.
 func (e *StatementExecutor) ExecuteStatement(ctx *query.ExecutionContext, stmt influxql.Statement) error {
+	if e.ReadOnly && isWriteStatement(stmt) {
+		return ErrReadOnly
+	}
+
 	// Select statements are handled separately so that they can be streamed.
 	if stmt, ok := stmt.(*influxql.SelectStatement); ok {
 		return e.executeSelectStatement(ctx, stmt)
@@ -1233,6 +1245,36 @@ func (e *StatementExecutor) writeInto(w pointsWriter, stmt *influxql.SelectState
 	return int64(len(points)), nil
 }
 
+// isWriteStatement returns true if stmt writes data or metadata.
+func isWriteStatement(stmt influxql.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *influxql.SelectStatement:
+		return stmt.Target != nil
+	case *influxql.AlterRetentionPolicyStatement,
+		*influxql.CreateContinuousQueryStatement,
+		*influxql.CreateDatabaseStatement,
+		*influxql.CreateRetentionPolicyStatement,
+		*influxql.CreateSubscriptionStatement,
+		*influxql.CreateUserStatement,
+		*influxql.DeleteSeriesStatement,
+		*influxql.DropContinuousQueryStatement,
+		*influxql.DropDatabaseStatement,
+		*influxql.DropMeasurementStatement,
+		*influxql.DropSeriesStatement,
+		*influxql.DropRetentionPolicyStatement,
+		*influxql.DropShardStatement,
+		*influxql.DropSubscriptionStatement,
+		*influxql.DropUserStatement,
+		*influxql.GrantStatement,
+		*influxql.GrantAdminStatement,
+		*influxql.RevokeStatement,
+		*influxql.RevokeAdminStatement,
+		*influxql.SetPasswordUserStatement:
+		return true
+	}
+	return false
+}
+
 var errNoDatabaseInTarget = errors.New("no database in target")
 
 // convertRowToPoints will convert a query result Row into Points that can be written back in.
@@ -232,6 +232,34 @@ func TestStatementExecutor_ExecuteQuery_WriteInto(t *testing.T) {
 	}
 }
 
+// Ensure statements that write are rejected in read-only mode.
+func TestStatementExecutor_ExecuteQuery_ReadOnly(t *testing.T) {
+	e := DefaultQueryExecutor()
+	e.StatementExecutor.ReadOnly = true
+	e.StatementExecutor.PointsWriter = writePointsIntoFunc(func(req *coordinator.IntoWriteRequest) error {
+		t.Fatal("unexpected write")
+		return nil
+	})
+	e.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		t.Fatal("unexpected database creation")
+		return nil, nil
+	}
+	for _, q := range []string{
+		`SELECT value INTO cpu_copy FROM cpu`,
+		`CREATE DATABASE db1`,
+		`DROP SERIES FROM cpu`,
+		`CREATE USER bob WITH PASSWORD 'secret'`,
+	} {
+		if a := ReadAllResults(e.ExecuteQuery(q, "db0", 0)); len(a) != 1 || a[0].Err != coordinator.ErrReadOnly {
+			t.Errorf("%s: unexpected results: %s", q, spew.Sdump(a))
+		}
+	}
+
+	if a := ReadAllResults(e.ExecuteQuery(`SHOW RETENTION POLICIES ON db0`, "db0", 0)); len(a) != 1 || a[0].Err != nil {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+}
+
 func TestStatementExecutor_NormalizeStatement(t *testing.T) {
 
 	testCases := []struct {
@@ -0,0 +1,71 @@
+package v8
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// checkpointSyncInterval is how often the checkpoint file is fsync'd.
+const checkpointSyncInterval = time.Second
+
+// checkpoint records how far an import got so it can be resumed. The size and
+// modification time of the input file guard against resuming a different file.
+type checkpoint struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+	Line    int    `json:"line"` // Last line whose points were written.
+}
+
+// loadCheckpoint reads the checkpoint at path and validates it against the
+// input file. A missing checkpoint resumes from the start of the file.
+func loadCheckpoint(path string, input os.FileInfo) (checkpoint, error) {
+	cp := checkpoint{Size: input.Size(), ModTime: input.ModTime().UnixNano()}
+
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	} else if err != nil {
+		return cp, err
+	}
+
+	var saved checkpoint
+	if err := json.Unmarshal(buf, &saved); err != nil {
+		return cp, fmt.Errorf("invalid checkpoint %s: %s", path, err)
+	}
+	if saved.Size != cp.Size || saved.ModTime != cp.ModTime {
+		return cp, fmt.Errorf("checkpoint %s does not match input file %s; remove it to start over", path, saved.Path)
+	}
+	return saved, nil
+}
+
+// save writes the checkpoint to path, replacing it atomically. The data is
+// fsync'd when sync is true.
+func (cp checkpoint) save(path string, sync bool) error {
+	buf, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if sync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,66 @@
+package v8
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "import.txt")
+	if err := os.WriteFile(input, []byte("# DML\ncpu value=1 1\ncpu value=2 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "import.checkpoint")
+
+	// Without a checkpoint the import starts from the beginning.
+	cp, err := loadCheckpoint(path, fi)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if cp.Line != 0 {
+		t.Fatalf("got line %d, exp 0", cp.Line)
+	}
+
+	cp.Path = input
+	cp.Line = 2
+	if err := cp.save(path, true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The same input file resumes after the saved line.
+	got, err := loadCheckpoint(path, fi)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if got != cp {
+		t.Fatalf("got checkpoint %+v, exp %+v", got, cp)
+	}
+
+	// A changed input file is rejected rather than skipping the wrong lines.
+	if err := os.WriteFile(input, []byte("# DML\ncpu value=3 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(input, time.Now(), fi.ModTime().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err = os.Stat(input); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCheckpoint(path, fi); err == nil || !strings.Contains(err.Error(), "does not match input file") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// So is a checkpoint that is not valid JSON.
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCheckpoint(path, fi); err == nil || !strings.Contains(err.Error(), "invalid checkpoint") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
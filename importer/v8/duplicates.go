@@ -0,0 +1,49 @@
+package v8
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// duplicates detects points in the import file that share a series and
+// timestamp with an earlier point, which the server would silently merge.
+// Points without a timestamp are stamped by the server and never collide.
+type duplicates struct {
+	precision string
+	seen      map[string]int // Line of the first point by database, retention policy, series and time.
+	count     int
+}
+
+func newDuplicates(precision string) *duplicates {
+	return &duplicates{precision: precision, seen: make(map[string]int)}
+}
+
+// check records the points of line and returns the line of an earlier point
+// with the same series and timestamp, or 0 if there is none. Lines that do
+// not parse are left for the server to reject.
+func (d *duplicates) check(database, retentionPolicy, line string, lineNum int) int {
+	points, err := models.ParsePointsWithPrecision([]byte(line), time.Time{}, d.precision)
+	if err != nil {
+		return 0
+	}
+
+	first := 0
+	for _, p := range points {
+		if p.Time().IsZero() {
+			continue
+		}
+		key := strings.Join([]string{database, retentionPolicy, string(p.Key()), strconv.FormatInt(p.UnixNano(), 10)}, "\x00")
+		if prev, ok := d.seen[key]; ok {
+			d.count++
+			if first == 0 {
+				first = prev
+			}
+			continue
+		}
+		d.seen[key] = lineNum
+	}
+	return first
+}
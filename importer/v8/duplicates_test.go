@@ -0,0 +1,57 @@
+package v8
+
+import "testing"
+
+func TestDuplicates_Check(t *testing.T) {
+	d := newDuplicates("s")
+	// Each entry is checked as the line numbered after its position, from 1.
+	for n, tt := range []struct {
+		db, rp string
+		line   string
+		exp    int
+	}{
+		{db: "db0", rp: "rp0", line: "cpu,host=a value=1 10", exp: 0},
+		// Same series and timestamp as line 1, whatever the fields.
+		{db: "db0", rp: "rp0", line: "cpu,host=a other=2 10", exp: 1},
+		// Tags are part of the series, whatever their order.
+		{db: "db0", rp: "rp0", line: "cpu,host=b value=1 10", exp: 0},
+		{db: "db0", rp: "rp0", line: "cpu,region=x,host=a value=1 10", exp: 0},
+		{db: "db0", rp: "rp0", line: "cpu,host=a,region=x value=1 10", exp: 4},
+		// Another timestamp, database or retention policy is another point.
+		{db: "db0", rp: "rp0", line: "cpu,host=a value=1 11", exp: 0},
+		{db: "db1", rp: "rp0", line: "cpu,host=a value=1 10", exp: 0},
+		{db: "db0", rp: "rp1", line: "cpu,host=a value=1 10", exp: 0},
+		// Points without a timestamp never collide and bad lines are skipped.
+		{db: "db0", rp: "rp0", line: "mem value=1", exp: 0},
+		{db: "db0", rp: "rp0", line: "mem value=1", exp: 0},
+		{db: "db0", rp: "rp0", line: "mem value=", exp: 0},
+		// Later duplicates report the first line, not the previous duplicate.
+		{db: "db0", rp: "rp0", line: "cpu,host=a value=3 10", exp: 1},
+		{db: "db0", rp: "rp0", line: "cpu,host=a value=2 11", exp: 6},
+	} {
+		if got := d.check(tt.db, tt.rp, tt.line, n+1); got != tt.exp {
+			t.Errorf("line %d: check(%q) = %d, exp %d", n+1, tt.line, got, tt.exp)
+		}
+	}
+	if d.count != 4 {
+		t.Fatalf("got %d duplicates, exp 4", d.count)
+	}
+}
+
+func TestDuplicates_Check_Precision(t *testing.T) {
+	// Timestamps are read at the import precision, here milliseconds.
+	d := newDuplicates("ms")
+	if got := d.check("db0", "rp0", "cpu value=1 1000", 1); got != 0 {
+		t.Fatalf("got %d, exp 0", got)
+	}
+	if got := d.check("db0", "rp0", "cpu value=1 1001", 2); got != 0 {
+		t.Fatalf("got %d, exp 0", got)
+	}
+	// Several points on one line are each checked.
+	if got := d.check("db0", "rp0", "cpu value=2 1001\ncpu value=2 1000", 3); got != 2 {
+		t.Fatalf("got %d, exp 2", got)
+	}
+	if d.count != 2 {
+		t.Fatalf("got %d duplicates, exp 2", d.count)
+	}
+}
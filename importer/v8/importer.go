@@ -9,26 +9,43 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/influxdata/influxdb/client"
+	"github.com/influxdata/influxdb/pkg/pool"
+	"github.com/influxdata/influxql"
 )
 
+// batchSize is the default number of points written per request.
 const batchSize = 5000
 
+// maxInflightBytes limits the size of the batches being written concurrently.
+const maxInflightBytes = 64 << 20
+
 // Config is the config used to initialize a Importer importer
 type Config struct {
 	Path       string // Path to import data.
 	Version    string
-	Compressed bool // Whether import data is gzipped.
-	PPS        int  // points per second importer imports with.
+	Compressed bool   // Whether import data is gzipped.
+	PPS        int    // points per second importer imports with.
+	BatchSize  int    // Number of points written per request. Defaults to 5000.
+	Checkpoint string // Path of the file used to resume an interrupted import.
+	Workers    int    // Number of batches written concurrently.
+
+	// ReportDuplicates logs points with the same series and timestamp as an
+	// earlier point in the file. It keeps every key in memory, so it is off
+	// by default.
+	ReportDuplicates bool
+
+	CreateDatabase bool // Whether to create each context database before writing to it.
 
 	client.Config
 }
 
 // NewConfig returns an initialized *Config
 func NewConfig() Config {
-	return Config{Config: client.NewConfig()}
+	return Config{BatchSize: batchSize, Config: client.NewConfig()}
 }
 
 // Importer is the importer used for importing 0.8 data
@@ -38,6 +55,18 @@ type Importer struct {
 	retentionPolicy       string
 	config                Config
 	batch                 []string
+	batchStart            int // Line number of the first point in batch.
+	batchEnd              int // Line number of the last point in batch.
+	batchSeq              int
+	line                  int // Line number of the last line read.
+	failedBatch           [2]int
+	failedErr             error // Error of the first failed batch.
+	checkpoint            checkpoint
+	resumeLine            int // Lines up to and including this one were already imported.
+	lastSync              time.Time
+	written               map[int]int // Line ranges of written batches by sequence.
+	checkpointSeq         int         // Sequence of the next batch the checkpoint waits for.
+	duplicates            *duplicates // Set when duplicate points are reported.
 	totalInserts          int
 	failedInserts         int
 	totalCommands         int
@@ -46,6 +75,13 @@ type Importer struct {
 	lastWrite             time.Time
 	throttle              *time.Ticker
 
+	// Concurrent writes.
+	mu            sync.Mutex
+	cond          *sync.Cond
+	wg            sync.WaitGroup
+	inflight      int
+	inflightBytes int
+
 	stderrLogger *log.Logger
 	stdoutLogger *log.Logger
 }
@@ -53,12 +89,21 @@ type Importer struct {
 // NewImporter will return an intialized Importer struct
 func NewImporter(config Config) *Importer {
 	config.UserAgent = fmt.Sprintf("influxDB importer/%s", config.Version)
-	return &Importer{
+	if config.BatchSize <= 0 {
+		config.BatchSize = batchSize
+	}
+	i := &Importer{
 		config:       config,
-		batch:        make([]string, 0, batchSize),
+		batch:        make([]string, 0, config.BatchSize),
+		written:      make(map[int]int),
 		stdoutLogger: log.New(os.Stdout, "", log.LstdFlags),
 		stderrLogger: log.New(os.Stderr, "", log.LstdFlags),
 	}
+	if config.ReportDuplicates {
+		i.duplicates = newDuplicates(config.Precision)
+	}
+	i.cond = sync.NewCond(&i.mu)
+	return i
 }
 
 // Import processes the specified file in the Config and writes the data to the databases in chunks specified by batchSize
@@ -84,6 +129,9 @@ func (i *Importer) Import() error {
 			i.stdoutLogger.Printf("Processed %d inserts\n", i.totalInserts)
 			i.stdoutLogger.Printf("Failed %d inserts\n", i.failedInserts)
 		}
+		if i.duplicates != nil {
+			i.stdoutLogger.Printf("Found %d duplicate points\n", i.duplicates.count)
+		}
 	}()
 
 	// Open the file
@@ -93,6 +141,23 @@ func (i *Importer) Import() error {
 	}
 	defer f.Close()
 
+	if i.config.Checkpoint != "" {
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		cp, err := loadCheckpoint(i.config.Checkpoint, fi)
+		if err != nil {
+			return err
+		}
+		cp.Path = i.config.Path
+		i.checkpoint = cp
+		i.resumeLine = cp.Line
+		if i.resumeLine > 0 {
+			i.stdoutLogger.Printf("Resuming import after line %d\n", i.resumeLine)
+		}
+	}
+
 	var r io.Reader
 
 	// If gzipped, wrap in a gzip reader
@@ -125,8 +190,11 @@ func (i *Importer) Import() error {
 	// Prime the last write
 	i.lastWrite = time.Now()
 
-	// Process the DML
-	if err := i.processDML(scanner); err != nil {
+	// Process the DML and wait for any concurrent writes to finish.
+	err = i.processDML(scanner)
+	i.wg.Wait()
+	if err != nil {
+		i.saveCheckpoint(true)
 		return fmt.Errorf("reading standard input: %s", err)
 	}
 
@@ -138,7 +206,15 @@ func (i *Importer) Import() error {
 			plural = "s were"
 		}
 
-		return fmt.Errorf("%d point%s not inserted", i.failedInserts, plural)
+		i.saveCheckpoint(true)
+		return fmt.Errorf("%d point%s not inserted; the first failed batch covered lines %d-%d: %s", i.failedInserts, plural, i.failedBatch[0], i.failedBatch[1], i.failedErr)
+	}
+
+	// The import is complete so there is nothing left to resume.
+	if i.config.Checkpoint != "" {
+		if err := os.Remove(i.config.Checkpoint); err != nil && !os.IsNotExist(err) {
+			i.stderrLogger.Printf("error removing checkpoint: %s\n", err)
+		}
 	}
 
 	return nil
@@ -147,6 +223,9 @@ func (i *Importer) Import() error {
 func (i *Importer) processDDL(scanner *bufio.Reader) error {
 	for {
 		line, err := scanner.ReadString(byte('\n'))
+		if err == nil {
+			i.line++
+		}
 		if err != nil && err != io.EOF {
 			return err
 		} else if err == io.EOF {
@@ -159,8 +238,8 @@ func (i *Importer) processDDL(scanner *bufio.Reader) error {
 		if strings.HasPrefix(line, "#") {
 			continue
 		}
-		// Skip blank lines
-		if strings.TrimSpace(line) == "" {
+		// Skip blank lines and commands that were run by a previous import.
+		if strings.TrimSpace(line) == "" || i.line <= i.resumeLine {
 			continue
 		}
 		i.queryExecutor(line)
@@ -171,6 +250,9 @@ func (i *Importer) processDML(scanner *bufio.Reader) error {
 	i.startTime = time.Now()
 	for {
 		line, err := scanner.ReadString(byte('\n'))
+		if err == nil {
+			i.line++
+		}
 		if err != nil && err != io.EOF {
 			return err
 		} else if err == io.EOF {
@@ -178,9 +260,18 @@ func (i *Importer) processDML(scanner *bufio.Reader) error {
 			i.batchWrite()
 			return nil
 		}
+		// Stop reading after a failed concurrent write so the first error is
+		// surfaced. Batches already in flight are allowed to finish.
+		if i.config.Workers > 1 && i.failed() {
+			i.stderrLogger.Println("stopping import after a failed batch")
+			return nil
+		}
 		if strings.HasPrefix(line, "# CONTEXT-DATABASE:") {
 			i.batchWrite()
 			i.database = strings.TrimSpace(strings.Split(line, ":")[1])
+			if i.config.CreateDatabase {
+				i.execute(fmt.Sprintf("CREATE DATABASE %s", influxql.QuoteIdent(i.database)))
+			}
 		}
 		if strings.HasPrefix(line, "# CONTEXT-RETENTION-POLICY:") {
 			i.batchWrite()
@@ -189,10 +280,15 @@ func (i *Importer) processDML(scanner *bufio.Reader) error {
 		if strings.HasPrefix(line, "#") {
 			continue
 		}
-		// Skip blank lines
-		if strings.TrimSpace(line) == "" {
+		// Skip blank lines and points that were written by a previous import.
+		if strings.TrimSpace(line) == "" || i.line <= i.resumeLine {
 			continue
 		}
+		if i.duplicates != nil {
+			if first := i.duplicates.check(i.database, i.retentionPolicy, line, i.line); first > 0 {
+				i.stderrLogger.Printf("duplicate point at line %d overwrites line %d: %s\n", i.line, first, strings.TrimSpace(line))
+			}
+		}
 		i.batchAccumulator(line)
 	}
 }
@@ -213,9 +309,27 @@ func (i *Importer) queryExecutor(command string) {
 	i.execute(command)
 }
 
+// saveCheckpoint records the import progress if a checkpoint file is configured.
+func (i *Importer) saveCheckpoint(sync bool) {
+	if i.config.Checkpoint == "" {
+		return
+	}
+	if err := i.checkpoint.save(i.config.Checkpoint, sync); err != nil {
+		i.stderrLogger.Printf("error saving checkpoint: %s\n", err)
+		return
+	}
+	if sync {
+		i.lastSync = time.Now()
+	}
+}
+
 func (i *Importer) batchAccumulator(line string) {
+	if len(i.batch) == 0 {
+		i.batchStart = i.line
+	}
 	i.batch = append(i.batch, line)
-	if len(i.batch) == batchSize {
+	i.batchEnd = i.line
+	if len(i.batch) >= i.config.BatchSize {
 		i.batchWrite()
 	}
 }
@@ -251,19 +365,101 @@ func (i *Importer) batchWrite() {
 		return
 	}
 
-	_, e := i.client.WriteLineProtocol(strings.Join(i.batch, "\n"), i.database, i.retentionPolicy, i.config.Precision, i.config.WriteConsistency)
-	if e != nil {
-		i.stderrLogger.Println("error writing batch: ", e)
-		i.stderrLogger.Println(strings.Join(i.batch, "\n"))
-		i.failedInserts += len(i.batch)
+	b := writeBatch{
+		data:            strings.Join(i.batch, "\n"),
+		points:          len(i.batch),
+		start:           i.batchStart,
+		end:             i.batchEnd,
+		seq:             i.batchSeq,
+		database:        i.database,
+		retentionPolicy: i.retentionPolicy,
+	}
+	i.batchSeq++
+	if i.config.Workers > 1 {
+		i.submit(b)
 	} else {
-		i.totalInserts += len(i.batch)
+		i.write(b)
 	}
 	i.throttlePointsWritten = 0
 	i.lastWrite = time.Now()
 
-	// Clear the batch and record the number of processed points.
+	// Clear the batch.
 	i.batch = i.batch[:0]
+}
+
+// writeBatch is a batch of points ready to be written.
+type writeBatch struct {
+	data            string
+	points          int
+	start, end      int // Line range of the points.
+	seq             int // Order in which the batch was read.
+	database        string
+	retentionPolicy string
+}
+
+// submit writes b on the shared worker pool once the number of batches and
+// bytes in flight allow it.
+func (i *Importer) submit(b writeBatch) {
+	size := len(b.data)
+
+	i.mu.Lock()
+	for i.inflight >= i.config.Workers || (i.inflight > 0 && i.inflightBytes+size > maxInflightBytes) {
+		i.cond.Wait()
+	}
+	i.inflight++
+	i.inflightBytes += size
+	i.mu.Unlock()
+
+	i.wg.Add(1)
+	task := func() {
+		defer i.wg.Done()
+		i.write(b)
+
+		i.mu.Lock()
+		i.inflight--
+		i.inflightBytes -= size
+		i.cond.Broadcast()
+		i.mu.Unlock()
+	}
+	if err := pool.Submit(task); err != nil {
+		// Fall back to writing in this goroutine.
+		task()
+	}
+}
+
+// write sends b to the server and records the result.
+func (i *Importer) write(b writeBatch) {
+	_, e := i.client.WriteLineProtocol(b.data, b.database, b.retentionPolicy, i.config.Precision, i.config.WriteConsistency)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if e != nil {
+		i.stderrLogger.Printf("error writing batch of %d points (lines %d-%d): %s\n", b.points, b.start, b.end, e)
+		i.stderrLogger.Println(b.data)
+		if i.failedInserts == 0 {
+			i.failedBatch = [2]int{b.start, b.end}
+			i.failedErr = e
+		}
+		i.failedInserts += b.points
+	} else {
+		i.stdoutLogger.Printf("Wrote batch of %d points (lines %d-%d)\n", b.points, b.start, b.end)
+		i.totalInserts += b.points
+
+		// Only advance the checkpoint over batches that were all written so a
+		// resumed import retries the first failed batch.
+		i.written[b.seq] = b.end
+		advanced := false
+		for end, ok := i.written[i.checkpointSeq]; ok; end, ok = i.written[i.checkpointSeq] {
+			delete(i.written, i.checkpointSeq)
+			i.checkpointSeq++
+			i.checkpoint.Line = end
+			advanced = true
+		}
+		if advanced {
+			i.saveCheckpoint(time.Since(i.lastSync) >= checkpointSyncInterval)
+		}
+	}
+
 	// Give some status feedback every 100000 lines processed
 	processed := i.totalInserts + i.failedInserts
 	if processed%100000 == 0 {
@@ -272,3 +468,10 @@ func (i *Importer) batchWrite() {
 		i.stdoutLogger.Printf("Processed %d lines.  Time elapsed: %s.  Points per second (PPS): %d", processed, since.String(), int64(pps))
 	}
 }
+
+// failed returns true if any batch failed to write.
+func (i *Importer) failed() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.failedInserts > 0
+}
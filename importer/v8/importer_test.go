@@ -0,0 +1,199 @@
+package v8
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestImporter_BatchSize(t *testing.T) {
+	var mu sync.Mutex
+	var writes [][]string
+	ts := newTestServer(t, func(body string) error {
+		mu.Lock()
+		writes = append(writes, bodyLines(body))
+		mu.Unlock()
+		if strings.Contains(body, "value=3") {
+			return errors.New("write failed")
+		}
+		return nil
+	})
+
+	// Lines 1-4 are DDL and context, the points are on lines 5-9.
+	i := newTestImporter(t, ts, writeImportFile(t,
+		"cpu value=1 1",
+		"cpu value=2 2",
+		"cpu value=3 3",
+		"cpu value=4 4",
+		"cpu value=5 5",
+	))
+	i.config.BatchSize = 2
+
+	err := i.Import()
+	if err == nil || !strings.Contains(err.Error(), "the first failed batch covered lines 7-8") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp := [][]string{
+		{"cpu value=1 1", "cpu value=2 2"},
+		{"cpu value=3 3", "cpu value=4 4"},
+		{"cpu value=5 5"},
+	}
+	if !reflect.DeepEqual(writes, exp) {
+		t.Fatalf("unexpected writes:\ngot: %q\nexp: %q", writes, exp)
+	}
+	if i.totalInserts != 3 || i.failedInserts != 2 {
+		t.Fatalf("got %d inserts and %d failed, exp 3 and 2", i.totalInserts, i.failedInserts)
+	}
+}
+
+func TestImporter_Workers_Checkpoint(t *testing.T) {
+	// The first two batches wait for the third, so they finish after it, and
+	// the second fails.
+	third := make(chan struct{})
+	var once sync.Once
+	ts := newTestServer(t, func(body string) error {
+		switch {
+		case strings.Contains(body, "value=3"):
+			once.Do(func() { close(third) })
+		case strings.Contains(body, "value=1"), strings.Contains(body, "value=2"):
+			select {
+			case <-third:
+			case <-time.After(5 * time.Second):
+				return errors.New("third batch not written concurrently")
+			}
+			if strings.Contains(body, "value=2") {
+				return errors.New("write failed")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil
+	})
+
+	path := writeImportFile(t,
+		"cpu value=1 1",
+		"cpu value=2 2",
+		"cpu value=3 3",
+		"cpu value=4 4",
+	)
+	checkpointPath := filepath.Join(t.TempDir(), "import.checkpoint")
+	i := newTestImporter(t, ts, path)
+	i.config.BatchSize = 1
+	i.config.Workers = 3
+	i.config.Checkpoint = checkpointPath
+	if err := i.Import(); err == nil || !strings.Contains(err.Error(), "lines 6-6") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The checkpoint stops before the failed batch even though the batch
+	// after it was written.
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := loadCheckpoint(checkpointPath, fi)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if cp.Line != 5 {
+		t.Fatalf("got checkpoint line %d, exp 5", cp.Line)
+	}
+
+	// Resuming writes the points from the failed batch on.
+	var mu sync.Mutex
+	var writes []string
+	ts = newTestServer(t, func(body string) error {
+		mu.Lock()
+		writes = append(writes, bodyLines(body)...)
+		mu.Unlock()
+		return nil
+	})
+	i = newTestImporter(t, ts, path)
+	i.config.BatchSize = 1
+	i.config.Workers = 3
+	i.config.Checkpoint = checkpointPath
+	if err := i.Import(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sort.Strings(writes)
+	if exp := []string{"cpu value=2 2", "cpu value=3 3", "cpu value=4 4"}; !reflect.DeepEqual(writes, exp) {
+		t.Fatalf("unexpected writes:\ngot: %q\nexp: %q", writes, exp)
+	}
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the checkpoint to be removed: %v", err)
+	}
+}
+
+// newTestServer returns a server answering pings and queries, and passing the
+// body of each write to write. An error fails the write.
+func newTestServer(t *testing.T, write func(body string) error) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write":
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := write(string(body)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "/query":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"results":[{}]}`)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// newTestImporter returns an importer of the file at path writing to ts, with
+// its output discarded.
+func newTestImporter(t *testing.T, ts *httptest.Server, path string) *Importer {
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := NewConfig()
+	config.Path = path
+	config.URL = *u
+	i := NewImporter(config)
+	i.stdoutLogger = log.New(io.Discard, "", 0)
+	i.stderrLogger = log.New(io.Discard, "", 0)
+	return i
+}
+
+// writeImportFile writes an import file creating db0 and holding points, one
+// per line, from line 5 on, and returns its path.
+func writeImportFile(t *testing.T, points ...string) string {
+	path := filepath.Join(t.TempDir(), "import.txt")
+	data := "# DDL\nCREATE DATABASE db0\n# DML\n# CONTEXT-DATABASE: db0\n" + strings.Join(points, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// bodyLines returns the non-empty lines of a write body.
+func bodyLines(body string) []string {
+	var lines []string
+	for _, l := range strings.Split(body, "\n") {
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
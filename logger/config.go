@@ -25,6 +25,15 @@ type Config struct {
 	Compress     bool          `toml:"compress"`
 	SuppressLogo bool          `toml:"suppress-logo"`
 	Access       AccessConfig  `toml:"access"`
+
+	// AsyncBufferSize is the size of a buffer log entries are written to
+	// instead of directly to the log file, so that logging does not block on
+	// I/O. Zero writes every entry synchronously.
+	AsyncBufferSize toml.Size `toml:"async-buffer-size"`
+
+	// ResourceAttributes are added to every entry under "resource" when
+	// Format is "otlp", e.g. service.name.
+	ResourceAttributes map[string]string `toml:"resource-attributes"`
 }
 
 // NewConfig returns a new instance of Config with defaults.
@@ -15,6 +15,15 @@ import (
 // TimeFormat represents the logger time format.
 const TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
 
+// DiscardFormat is the logging format that drops every entry without
+// encoding it. It is intended for benchmarking where logging overhead
+// would otherwise skew the results.
+const DiscardFormat = "discard"
+
+// AsyncFlushInterval is how often buffered log entries are written out when
+// AsyncBufferSize is set, if the buffer does not fill up first.
+const AsyncFlushInterval = time.Second
+
 // New creates a new zap.Logger.
 func New(w io.Writer) *zap.Logger {
 	config := NewConfig()
@@ -24,6 +33,10 @@ func New(w io.Writer) *zap.Logger {
 
 // New creates a new zap.Logger from config settings.
 func (c *Config) New(defaultOutput io.Writer) (*zap.Logger, error) {
+	if c.Discard() {
+		return zap.New(zapcore.NewNopCore()), nil
+	}
+
 	w := defaultOutput
 	format := c.Format
 	if format == "console" {
@@ -41,7 +54,7 @@ func (c *Config) New(defaultOutput io.Writer) (*zap.Logger, error) {
 		}
 	}
 
-	encoder, err := newEncoder(format)
+	encoder, err := c.newEncoder(format)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +65,15 @@ func (c *Config) New(defaultOutput io.Writer) (*zap.Logger, error) {
 	), zap.Fields(zap.String("log_id", nextID()))), nil
 }
 
-func (c *Config) NewLogger(atomicLevel *zap.AtomicLevel) (*zap.Logger, error) {
+// NewLogger creates a zap.Logger writing to the configured log file. The
+// returned stop function flushes the entries buffered when AsyncBufferSize is
+// set and stops the goroutine writing them; it is a no-op otherwise.
+func (c *Config) NewLogger(atomicLevel *zap.AtomicLevel) (*zap.Logger, func() error, error) {
+	if c.Discard() {
+		atomicLevel.SetLevel(c.Level)
+		return zap.New(zapcore.NewNopCore()), noStop, nil
+	}
+
 	maxSize := int(c.MaxSize)
 	if maxSize < 1024*1024 {
 		maxSize = 1
@@ -72,18 +93,31 @@ func (c *Config) NewLogger(atomicLevel *zap.AtomicLevel) (*zap.Logger, error) {
 		Compress:   c.Compress,
 	}
 
-	encoder, err := newEncoder(c.Format)
+	encoder, err := c.newEncoder(c.Format)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	atomicLevel.SetLevel(c.Level)
-	core := zapcore.NewCore(encoder, zapcore.AddSync(lumberJackLogger), atomicLevel)
-	return zap.New(core, zap.AddCaller(), zap.Development()), nil
+	ws := c.writeSyncer(lumberJackLogger)
+	stop := noStop
+	if b, ok := ws.(*zapcore.BufferedWriteSyncer); ok {
+		stop = b.Stop
+	}
+	core := zapcore.NewCore(encoder, ws, atomicLevel)
+	return zap.New(core, zap.AddCaller(), zap.Development()), stop, nil
 }
 
-func (c *Config) NewAccessLogger() (*zap.Logger, error) {
+// noStop is the stop function of a logger writing synchronously.
+func noStop() error { return nil }
+
+// NewAccessLogger creates a zap.Logger writing to the configured access log
+// file. Its stop function is the one of NewLogger.
+func (c *Config) NewAccessLogger() (*zap.Logger, func() error, error) {
 	if !c.Access.Enabled {
-		return nil, fmt.Errorf("access logger is not enabled")
+		return nil, nil, fmt.Errorf("access logger is not enabled")
+	}
+	if c.Discard() {
+		return zap.New(zapcore.NewNopCore()), noStop, nil
 	}
 	maxSize := int(c.Access.MaxSize)
 	if maxSize == 0 {
@@ -110,18 +144,44 @@ func (c *Config) NewAccessLogger() (*zap.Logger, error) {
 		Compress:   c.Access.Compress,
 	}
 
-	encoder, err := newEncoder(c.Format)
+	encoder, err := c.newEncoder(c.Format)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	ws := c.writeSyncer(lumberJackLogger)
+	stop := noStop
+	if b, ok := ws.(*zapcore.BufferedWriteSyncer); ok {
+		stop = b.Stop
 	}
 
 	return zap.New(
-		zapcore.NewCore(encoder, zapcore.AddSync(lumberJackLogger), c.Access.Level),
+		zapcore.NewCore(encoder, ws, c.Access.Level),
 		zap.AddCaller(),
-		zap.Development()), nil
+		zap.Development()), stop, nil
+}
+
+// Discard returns true if the logger is configured to drop all entries.
+func (c *Config) Discard() bool {
+	return c.Format == DiscardFormat
+}
+
+// writeSyncer returns the write-syncer for a log file. With AsyncBufferSize
+// set, entries are buffered and written by a background goroutine when the
+// buffer fills or every AsyncFlushInterval, and Sync on the logger flushes
+// them.
+func (c *Config) writeSyncer(w io.Writer) zapcore.WriteSyncer {
+	ws := zapcore.AddSync(w)
+	if c.AsyncBufferSize <= 0 {
+		return ws
+	}
+	return &zapcore.BufferedWriteSyncer{
+		WS:            ws,
+		Size:          int(c.AsyncBufferSize),
+		FlushInterval: AsyncFlushInterval,
+	}
 }
 
-func newEncoder(format string) (zapcore.Encoder, error) {
+func (c *Config) newEncoder(format string) (zapcore.Encoder, error) {
 	config := newEncoderConfig()
 	switch format {
 	case "json":
@@ -130,6 +190,8 @@ func newEncoder(format string) (zapcore.Encoder, error) {
 		return zapcore.NewConsoleEncoder(config), nil
 	case "logfmt":
 		return zaplogfmt.NewEncoder(config), nil
+	case OTLPFormat:
+		return newOTLPEncoder(c.ResourceAttributes), nil
 	default:
 		return nil, fmt.Errorf("unknown logging format: %s", format)
 	}
@@ -0,0 +1,68 @@
+package logger_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/toml"
+	"go.uber.org/zap"
+)
+
+func TestConfig_NewLogger_AsyncBuffer(t *testing.T) {
+	config := logger.NewConfig()
+	config.FileName = filepath.Join(t.TempDir(), "influxd.log")
+	config.AsyncBufferSize = toml.Size(1 << 20)
+	level := zap.NewAtomicLevel()
+	log, stop, err := config.NewLogger(&level)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log.Info("buffered")
+	if buf, _ := os.ReadFile(config.FileName); len(buf) > 0 {
+		t.Fatalf("entry written before the buffer was flushed: %s", buf)
+	}
+
+	// Stopping flushes the entries still buffered.
+	if err := stop(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	buf, err := os.ReadFile(config.FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(buf), `"msg":"buffered"`) {
+		t.Fatalf("buffered entry not flushed: %s", buf)
+	}
+}
+
+func TestConfig_NewAccessLogger_AsyncBuffer(t *testing.T) {
+	config := logger.NewConfig()
+	config.Access.Enabled = true
+	config.Access.FileName = filepath.Join(t.TempDir(), "access.log")
+	config.AsyncBufferSize = toml.Size(1 << 20)
+	log, stop, err := config.NewAccessLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log.Info("buffered")
+	if buf, _ := os.ReadFile(config.Access.FileName); len(buf) > 0 {
+		t.Fatalf("entry written before the buffer was flushed: %s", buf)
+	}
+
+	// Stopping flushes the entries still buffered.
+	if err := stop(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	buf, err := os.ReadFile(config.Access.FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(buf), `"msg":"buffered"`) {
+		t.Fatalf("buffered entry not flushed: %s", buf)
+	}
+}
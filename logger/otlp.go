@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"sort"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// OTLPFormat is the logging format that writes JSON with the field names an
+// OpenTelemetry collector expects: timestamp, severity, severity_text and
+// body, with the configured resource attributes under resource.
+const OTLPFormat = "otlp"
+
+// otlpSeverity maps zap levels to OpenTelemetry severity numbers.
+var otlpSeverity = map[zapcore.Level]int64{
+	zapcore.DebugLevel:  5,  // DEBUG
+	zapcore.InfoLevel:   9,  // INFO
+	zapcore.WarnLevel:   13, // WARN
+	zapcore.ErrorLevel:  17, // ERROR
+	zapcore.DPanicLevel: 18, // ERROR2
+	zapcore.PanicLevel:  21, // FATAL
+	zapcore.FatalLevel:  21, // FATAL
+}
+
+// otlpEncoder adds the severity text of each entry to the JSON encoder, which
+// has a single level key holding the severity number.
+type otlpEncoder struct {
+	zapcore.Encoder
+}
+
+func newOTLPEncoder(resource map[string]string) zapcore.Encoder {
+	config := newEncoderConfig()
+	config.TimeKey = "timestamp"
+	config.LevelKey = "severity"
+	config.MessageKey = "body"
+	config.EncodeTime = func(ts time.Time, encoder zapcore.PrimitiveArrayEncoder) {
+		encoder.AppendInt64(ts.UnixNano())
+	}
+	config.EncodeLevel = func(l zapcore.Level, encoder zapcore.PrimitiveArrayEncoder) {
+		encoder.AppendInt64(otlpSeverity[l])
+	}
+
+	enc := zapcore.NewJSONEncoder(config)
+	if len(resource) > 0 {
+		_ = enc.AddObject("resource", otlpResource(resource))
+	}
+	return &otlpEncoder{Encoder: enc}
+}
+
+func (e *otlpEncoder) Clone() zapcore.Encoder {
+	return &otlpEncoder{Encoder: e.Encoder.Clone()}
+}
+
+func (e *otlpEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	fields = append(fields[:len(fields):len(fields)], zapcore.Field{
+		Key:    "severity_text",
+		Type:   zapcore.StringType,
+		String: ent.Level.CapitalString(),
+	})
+	return e.Encoder.EncodeEntry(ent, fields)
+}
+
+// otlpResource encodes the resource attributes in key order.
+type otlpResource map[string]string
+
+func (r otlpResource) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		enc.AddString(k, r[k])
+	}
+	return nil
+}
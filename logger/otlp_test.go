@@ -0,0 +1,101 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestOTLPFormat(t *testing.T) {
+	var buf bytes.Buffer
+	config := logger.NewConfig()
+	config.Format = logger.OTLPFormat
+	config.Level = zapcore.DebugLevel
+	config.ResourceAttributes = map[string]string{
+		"service.name":        "influxd",
+		"service.instance.id": "node0",
+	}
+	log, err := config.New(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().UnixNano()
+	log.Info("starting", zap.String("db", "db0"))
+	// With clones the encoder, which must keep the resource and severity text.
+	log.With(zap.String("trace_id", "abc")).Warn("slow")
+	log.Debug("debug")
+	log.Error("failed")
+	log.DPanic("bug")
+	after := time.Now().UnixNano()
+
+	type entry struct {
+		Timestamp    int64             `json:"timestamp"`
+		Severity     int64             `json:"severity"`
+		SeverityText string            `json:"severity_text"`
+		Body         string            `json:"body"`
+		Resource     map[string]string `json:"resource"`
+		DB           string            `json:"db"`
+		TraceID      string            `json:"trace_id"`
+	}
+	var entries []entry
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("invalid JSON: %s", err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, exp 5", len(entries))
+	}
+
+	for i, exp := range []entry{
+		{Severity: 9, SeverityText: "INFO", Body: "starting", DB: "db0"},
+		{Severity: 13, SeverityText: "WARN", Body: "slow", TraceID: "abc"},
+		{Severity: 5, SeverityText: "DEBUG", Body: "debug"},
+		{Severity: 17, SeverityText: "ERROR", Body: "failed"},
+		{Severity: 18, SeverityText: "DPANIC", Body: "bug"},
+	} {
+		got := entries[i]
+		if got.Timestamp < before || got.Timestamp > after {
+			t.Errorf("entry %d: timestamp %d not in nanoseconds between %d and %d", i, got.Timestamp, before, after)
+		}
+		if !reflect.DeepEqual(got.Resource, config.ResourceAttributes) {
+			t.Errorf("entry %d: got resource %v, exp %v", i, got.Resource, config.ResourceAttributes)
+		}
+		got.Timestamp, got.Resource = 0, nil
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("entry %d: got %+v, exp %+v", i, got, exp)
+		}
+	}
+}
+
+func TestOTLPFormat_NoResource(t *testing.T) {
+	var buf bytes.Buffer
+	config := logger.NewConfig()
+	config.Format = logger.OTLPFormat
+	log, err := config.New(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Info("starting")
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	if _, ok := m["resource"]; ok {
+		t.Fatalf("unexpected resource: %v", m["resource"])
+	}
+	if m["body"] != "starting" || m["severity_text"] != "INFO" {
+		t.Fatalf("unexpected entry: %v", m)
+	}
+}
@@ -0,0 +1,97 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// drain holds back Submit while Drain waits for the tasks already submitted.
+// inflight counts the tasks accepted by Submit, queued or running, until they
+// return. ctx is the context the tasks of SubmitWithTimeout start with; Drain
+// cancels it and installs a new one when it reopens the pool.
+var drain struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	draining bool
+	inflight int
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// drainMu serializes the calls to Drain.
+var drainMu sync.Mutex
+
+func init() {
+	drain.cond = sync.NewCond(&drain.mu)
+	drain.ctx, drain.cancel = context.WithCancel(context.Background())
+}
+
+// Drain stops the default pool from accepting tasks, cancels the contexts of
+// the tasks of SubmitWithTimeout and waits for every submitted task, queued
+// or running, to return, for at most timeout. The pool then accepts tasks
+// again, whether or not it drained in time. Submit blocks meanwhile rather
+// than fail, so no task is lost. It is meant for a change, such as a
+// configuration reload, that running tasks must not outlive. An error is
+// returned if tasks are still running at the timeout.
+func Drain(timeout time.Duration) error {
+	drainMu.Lock()
+	defer drainMu.Unlock()
+
+	drain.mu.Lock()
+	drain.draining = true
+	drain.cancel()
+	drain.mu.Unlock()
+
+	defer func() {
+		drain.mu.Lock()
+		drain.draining = false
+		drain.ctx, drain.cancel = context.WithCancel(context.Background())
+		drain.cond.Broadcast()
+		drain.mu.Unlock()
+	}()
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	for {
+		drain.mu.Lock()
+		n := drain.inflight
+		drain.mu.Unlock()
+		if n == 0 {
+			slog.Info("pool drained", "elapsed", time.Since(start))
+			return nil
+		}
+		if time.Now().After(deadline) {
+			slog.Warn("pool drain timed out", "timeout", timeout, "remaining", n)
+			return fmt.Errorf("pool drain timed out after %s with %d tasks remaining", timeout, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// admit waits until the pool is not draining and counts a task as in flight.
+func admit() {
+	drain.mu.Lock()
+	defer drain.mu.Unlock()
+	for drain.draining {
+		drain.cond.Wait()
+	}
+	drain.inflight++
+}
+
+// release counts a task admitted by admit as returned.
+func release() {
+	drain.mu.Lock()
+	defer drain.mu.Unlock()
+	drain.inflight--
+}
+
+// drainContext returns the context Drain cancels. A task starting while the
+// pool drains gets a canceled one.
+func drainContext() context.Context {
+	drain.mu.Lock()
+	defer drain.mu.Unlock()
+	return drain.ctx
+}
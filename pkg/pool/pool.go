@@ -1,27 +1,363 @@
 package pool
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/panjf2000/ants/v2"
 )
 
 var ErrPoolNotInit = errors.New("DefaultPool not init yet")
 
+// ErrPoolSaturated is returned by Submit when every worker is busy, the
+// pending queue is full and the policy is PolicyError.
+var ErrPoolSaturated = errors.New("pool saturated: every worker is busy and the pending queue is full")
+
+// DefaultSize is the number of workers of the default pool unless Init sets
+// another.
+const DefaultSize = 100
+
+// Policy is what Submit does with a task when every worker is busy and the
+// pending queue is full.
+type Policy string
+
+const (
+	// PolicyBlock blocks Submit until the queue has room.
+	PolicyBlock Policy = "block"
+	// PolicyDropOldest drops the oldest pending task to queue the new one.
+	PolicyDropOldest Policy = "drop-oldest"
+	// PolicyError makes Submit return ErrPoolSaturated.
+	PolicyError Policy = "error"
+)
+
+// Config is the configuration of the default pool.
+type Config struct {
+	// Size is the number of workers. Zero means DefaultSize.
+	Size int
+
+	// MaxPending is the number of tasks that wait for a worker when every
+	// worker is busy. Zero keeps no queue of its own, and Submit blocks until
+	// a worker is free, as many callers as there are.
+	MaxPending int
+
+	// Policy applies to tasks submitted when the queue is full. The empty
+	// policy is PolicyBlock.
+	Policy Policy
+
+	// MinIdle is the number of workers started by Init, so that the first
+	// tasks do not wait for their goroutines to be created. Idle workers are
+	// then kept rather than stopped after a while, so the pool holds on to
+	// every worker it starts. Zero starts workers as tasks need them.
+	MinIdle int
+}
+
 var defaultPool *ants.Pool
 
+// panics is the number of submitted tasks that panicked, and busy the number
+// of workers running a task. timeouts is the number of tasks of
+// SubmitWithTimeout that ran past their deadline, and overdue the number of
+// them still running.
+var panics, busy, timeouts, overdue int64
+
+// queue holds the tasks waiting for a worker with MaxPending set. Each worker
+// handed a task by Submit keeps running the queued tasks until the queue is
+// empty, and active counts these workers.
+var queue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	config  Config
+	tasks   []func()
+	active  int
+	dropped int64
+}
+
 func init() {
-	var err error
-	defaultPool, err = ants.NewPool(100)
-	if err != nil {
+	queue.cond = sync.NewCond(&queue.mu)
+	if err := Init(Config{}); err != nil {
 		panic(err)
 	}
 }
 
+// Init replaces the default pool with one of the given configuration. It is
+// meant to be called at startup, before tasks are submitted.
+func Init(config Config) error {
+	if config.Size < 0 {
+		return fmt.Errorf("pool size must not be negative: %d", config.Size)
+	}
+	if config.MaxPending < 0 {
+		return fmt.Errorf("pool max pending must not be negative: %d", config.MaxPending)
+	}
+	if config.Size == 0 {
+		config.Size = DefaultSize
+	}
+	if config.MinIdle < 0 || config.MinIdle > config.Size {
+		return fmt.Errorf("pool min idle must be between 0 and the size %d: %d", config.Size, config.MinIdle)
+	}
+	switch config.Policy {
+	case "":
+		config.Policy = PolicyBlock
+	case PolicyBlock, PolicyDropOldest, PolicyError:
+	default:
+		return fmt.Errorf("unknown pool policy %q: use block, drop-oldest or error", config.Policy)
+	}
+
+	p, err := ants.NewPool(config.Size, ants.WithPanicHandler(handlePanic), ants.WithDisablePurge(config.MinIdle > 0))
+	if err != nil {
+		return err
+	}
+	if err := warm(p, config.MinIdle); err != nil {
+		p.Release()
+		return err
+	}
+	queue.mu.Lock()
+	queue.config = config
+	queue.mu.Unlock()
+	if defaultPool != nil {
+		defaultPool.Release()
+	}
+	defaultPool = p
+	return nil
+}
+
+// warm starts n workers of p, which stay idle once they return. Each task
+// waits for the others to start, so that every one runs on its own worker.
+func warm(p *ants.Pool, n int) error {
+	var started sync.WaitGroup
+	started.Add(n)
+	for i := 0; i < n; i++ {
+		if err := p.Submit(func() {
+			started.Done()
+			started.Wait()
+		}); err != nil {
+			return err
+		}
+	}
+	started.Wait()
+	return nil
+}
+
+// Resize changes the number of workers of the default pool while it runs.
+// Growing it starts tasks blocked in Submit or queued right away. Shrinking
+// it lets the running tasks finish, and the workers above the new size stop
+// once they are done.
+func Resize(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("pool size must be positive: %d", n)
+	}
+	old := defaultPool.Cap()
+	defaultPool.Tune(n)
+	slog.Info("pool resized", "old", old, "new", n)
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	for queue.active < n && len(queue.tasks) > 0 {
+		task := queue.tasks[0]
+		queue.tasks[0] = nil
+		queue.tasks = queue.tasks[1:]
+		queue.active++
+		if err := defaultPool.Submit(func() { runBusy(func() { work(task) }) }); err != nil {
+			queue.active--
+			return err
+		}
+	}
+	queue.cond.Broadcast()
+	return nil
+}
+
+// handlePanic logs the value and stack of a task that panicked, which the
+// worker has recovered from, so that the task does not disappear silently.
+func handlePanic(v interface{}) {
+	atomic.AddInt64(&panics, 1)
+	slog.Error("pool task panicked", "panic", v, "stack", string(debug.Stack()))
+}
+
+// Submit runs task on a worker of the default pool. While Drain runs, it
+// blocks until the pool accepts tasks again.
 func Submit(task func()) error {
-	if defaultPool.Waiting() > 0 {
-		slog.Info("pool submit task", "cap", defaultPool.Cap(), "waiting", defaultPool.Waiting(), "running", defaultPool.Running())
+	admit()
+	tracked := func() {
+		defer release()
+		task()
+	}
+
+	queue.mu.Lock()
+	config := queue.config
+	queue.mu.Unlock()
+	var err error
+	if config.MaxPending == 0 {
+		if defaultPool.Waiting() > 0 {
+			slog.Info("pool submit task", "cap", defaultPool.Cap(), "waiting", defaultPool.Waiting(), "running", defaultPool.Running())
+		}
+		err = defaultPool.Submit(func() { runBusy(tracked) })
+	} else {
+		err = submitQueued(tracked)
+	}
+	if err != nil {
+		release()
+	}
+	return err
+}
+
+// SubmitWithTimeout submits a task that is passed a context canceled d after
+// the task starts, or by Drain. The task must return once the context is
+// done, as it cannot be stopped otherwise; a task still running past its
+// deadline is logged and counted, so that a stuck worker can be told from an
+// idle one.
+func SubmitWithTimeout(d time.Duration, task func(ctx context.Context)) error {
+	return Submit(func() {
+		ctx, cancel := context.WithTimeout(drainContext(), d)
+		defer cancel()
+		// late is set by the timer if it fires before the task returns,
+		// which then reports the task as finished late.
+		var mu sync.Mutex
+		var finished, late bool
+		start := time.Now()
+		timer := time.AfterFunc(d, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if finished {
+				return
+			}
+			late = true
+			atomic.AddInt64(&timeouts, 1)
+			atomic.AddInt64(&overdue, 1)
+			slog.Warn("pool task still running past its deadline", "timeout", d)
+		})
+		defer func() {
+			timer.Stop()
+			mu.Lock()
+			defer mu.Unlock()
+			finished = true
+			if late {
+				atomic.AddInt64(&overdue, -1)
+				slog.Warn("pool task finished past its deadline", "timeout", d, "elapsed", time.Since(start))
+			}
+		}()
+		task(ctx)
+	})
+}
+
+// runBusy runs task, counting its worker as busy meanwhile.
+func runBusy(task func()) {
+	atomic.AddInt64(&busy, 1)
+	defer atomic.AddInt64(&busy, -1)
+	task()
+}
+
+// submitQueued hands task to a worker, or queues it when every worker is
+// busy, applying the policy when the queue is full.
+func submitQueued(task func()) error {
+	queue.mu.Lock()
+	for {
+		if queue.active < defaultPool.Cap() {
+			queue.active++
+			queue.mu.Unlock()
+			if err := defaultPool.Submit(func() { runBusy(func() { work(task) }) }); err != nil {
+				queue.mu.Lock()
+				queue.active--
+				queue.cond.Broadcast()
+				queue.mu.Unlock()
+				return err
+			}
+			return nil
+		}
+		if len(queue.tasks) < queue.config.MaxPending {
+			queue.tasks = append(queue.tasks, task)
+			queue.mu.Unlock()
+			return nil
+		}
+
+		switch queue.config.Policy {
+		case PolicyError:
+			queue.mu.Unlock()
+			return ErrPoolSaturated
+		case PolicyDropOldest:
+			queue.tasks[0] = nil
+			queue.tasks = append(queue.tasks[1:], task)
+			queue.dropped++
+			queue.mu.Unlock()
+			// The dropped task will not run to count itself as returned.
+			release()
+			slog.Warn("pool queue full, dropped the oldest pending task", "cap", defaultPool.Cap(), "pending", queue.config.MaxPending)
+			return nil
+		default:
+			queue.cond.Wait()
+		}
+	}
+}
+
+// work runs task and then the queued tasks until the queue is empty, or until
+// the pool has been shrunk below the number of workers running the queue.
+func work(task func()) {
+	for {
+		run(task)
+		queue.mu.Lock()
+		if len(queue.tasks) == 0 || queue.active > defaultPool.Cap() {
+			queue.active--
+			queue.cond.Broadcast()
+			queue.mu.Unlock()
+			return
+		}
+		task = queue.tasks[0]
+		queue.tasks[0] = nil
+		queue.tasks = queue.tasks[1:]
+		queue.cond.Broadcast()
+		queue.mu.Unlock()
+	}
+}
+
+// run runs a queued task, recovering from a panic so that the worker goes on
+// with the queue.
+func run(task func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			handlePanic(v)
+		}
+	}()
+	task()
+}
+
+// Statistics is a snapshot of the state of the default pool.
+type Statistics struct {
+	Cap     int   // maximum number of workers
+	Running int   // number of workers started, busy or idle
+	Idle    int   // number of workers waiting for a task
+	Waiting int   // number of Submit calls blocked waiting for a worker
+	Pending int   // number of tasks in the queue, with MaxPending set
+	Dropped int64 // number of pending tasks dropped by PolicyDropOldest
+	Panics  int64 // number of tasks that panicked since the process started
+
+	Timeouts int64 // number of tasks of SubmitWithTimeout that ran past their deadline
+	Overdue  int   // number of tasks of SubmitWithTimeout running past their deadline
+}
+
+// Stats returns the current state of the default pool.
+func Stats() Statistics {
+	queue.mu.Lock()
+	pending, dropped := len(queue.tasks), queue.dropped
+	queue.mu.Unlock()
+	running := defaultPool.Running()
+	idle := running - int(atomic.LoadInt64(&busy))
+	if idle < 0 {
+		// The counts are read at slightly different times.
+		idle = 0
+	}
+	return Statistics{
+		Cap:     defaultPool.Cap(),
+		Running: running,
+		Idle:    idle,
+		Waiting: defaultPool.Waiting(),
+		Pending: pending,
+		Dropped: dropped,
+		Panics:  atomic.LoadInt64(&panics),
+
+		Timeouts: atomic.LoadInt64(&timeouts),
+		Overdue:  int(atomic.LoadInt64(&overdue)),
 	}
-	return defaultPool.Submit(task)
 }
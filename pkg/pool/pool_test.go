@@ -0,0 +1,338 @@
+package pool_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/pkg/pool"
+)
+
+func TestSubmit_Panic(t *testing.T) {
+	before := pool.Stats().Panics
+	done := make(chan struct{})
+	if err := pool.Submit(func() {
+		defer close(done)
+		panic("boom")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	// The panic handler runs after the deferred close.
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Panics == before {
+		if time.Now().After(deadline) {
+			t.Fatal("panic was not counted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The worker recovered and the pool still runs tasks.
+	ran := make(chan struct{})
+	if err := pool.Submit(func() { close(ran) }); err != nil {
+		t.Fatal(err)
+	}
+	<-ran
+}
+
+// saturate initializes a pool of one worker and one pending task with the
+// given policy, and occupies the worker and the queue. The worker runs the
+// pending task once release is closed.
+func saturate(t *testing.T, policy pool.Policy) (release chan struct{}, pendingRan chan struct{}) {
+	t.Helper()
+	if err := pool.Init(pool.Config{Size: 1, MaxPending: 1, Policy: policy}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Init(pool.Config{}) })
+
+	release, pendingRan = make(chan struct{}), make(chan struct{})
+	if err := pool.Submit(func() { <-release }); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Submit(func() { close(pendingRan) }); err != nil {
+		t.Fatal(err)
+	}
+	if got := pool.Stats().Pending; got != 1 {
+		t.Fatalf("got %d pending tasks, exp 1", got)
+	}
+	return release, pendingRan
+}
+
+func TestSubmit_PolicyError(t *testing.T) {
+	release, pendingRan := saturate(t, pool.PolicyError)
+	if err := pool.Submit(func() {}); err != pool.ErrPoolSaturated {
+		t.Fatalf("got %v, exp %v", err, pool.ErrPoolSaturated)
+	}
+	close(release)
+	<-pendingRan
+}
+
+func TestSubmit_PolicyDropOldest(t *testing.T) {
+	dropped := pool.Stats().Dropped
+	release, pendingRan := saturate(t, pool.PolicyDropOldest)
+	newest := make(chan struct{})
+	if err := pool.Submit(func() { close(newest) }); err != nil {
+		t.Fatal(err)
+	}
+	if got := pool.Stats().Dropped; got != dropped+1 {
+		t.Fatalf("got %d dropped tasks, exp %d", got, dropped+1)
+	}
+	close(release)
+	<-newest
+	select {
+	case <-pendingRan:
+		t.Fatal("the oldest pending task ran")
+	default:
+	}
+}
+
+func TestSubmit_PolicyBlock(t *testing.T) {
+	release, pendingRan := saturate(t, pool.PolicyBlock)
+	submitted := make(chan error)
+	go func() { submitted <- pool.Submit(func() {}) }()
+	select {
+	case err := <-submitted:
+		t.Fatalf("Submit returned %v with the queue full", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-submitted; err != nil {
+		t.Fatal(err)
+	}
+	<-pendingRan
+}
+
+func TestInit_Invalid(t *testing.T) {
+	for _, config := range []pool.Config{
+		{Size: -1},
+		{MaxPending: -1},
+		{Policy: "drop-newest"},
+		{Size: 2, MinIdle: 3},
+	} {
+		if err := pool.Init(config); err == nil {
+			t.Errorf("expected an error for %+v", config)
+		}
+	}
+}
+
+func TestInit_MinIdle(t *testing.T) {
+	if err := pool.Init(pool.Config{Size: 4, MinIdle: 3}); err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Init(pool.Config{})
+	if stats := pool.Stats(); stats.Running != 3 || stats.Idle != 3 {
+		t.Fatalf("got %d workers, %d idle, exp 3 and 3", stats.Running, stats.Idle)
+	}
+
+	release, started := make(chan struct{}), make(chan struct{})
+	if err := pool.Submit(func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if stats := pool.Stats(); stats.Running != 3 || stats.Idle != 2 {
+		t.Fatalf("got %d workers, %d idle, exp 3 and 2", stats.Running, stats.Idle)
+	}
+	close(release)
+
+	// Without MinIdle workers are started as tasks need them.
+	if err := pool.Init(pool.Config{Size: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if stats := pool.Stats(); stats.Running != 0 || stats.Idle != 0 {
+		t.Fatalf("got %d workers, %d idle, exp none", stats.Running, stats.Idle)
+	}
+}
+
+func TestSubmitWithTimeout(t *testing.T) {
+	timeouts := pool.Stats().Timeouts
+
+	// A task honoring its context returns at the deadline.
+	done := make(chan error)
+	if err := pool.SubmitWithTimeout(10*time.Millisecond, func(ctx context.Context) {
+		<-ctx.Done()
+		done <- ctx.Err()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != context.DeadlineExceeded {
+		t.Fatalf("got %v, exp %v", err, context.DeadlineExceeded)
+	}
+
+	// A task ignoring it is reported as overdue until it returns.
+	release, returned := make(chan struct{}), make(chan struct{})
+	if err := pool.SubmitWithTimeout(time.Millisecond, func(ctx context.Context) {
+		defer close(returned)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Overdue != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("stuck task not reported as overdue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-returned
+	for pool.Stats().Overdue != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("returned task still reported as overdue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// The first task may or may not have returned before the deadline.
+	if got := pool.Stats().Timeouts; got != timeouts+1 && got != timeouts+2 {
+		t.Fatalf("got %d timeouts, exp %d or %d", got, timeouts+1, timeouts+2)
+	}
+}
+
+func TestResize(t *testing.T) {
+	for _, maxPending := range []int{0, 10} {
+		if err := pool.Init(pool.Config{Size: 1, MaxPending: maxPending}); err != nil {
+			t.Fatal(err)
+		}
+
+		// One task runs and three wait, blocked in Submit or queued.
+		release, started, finished := make(chan struct{}), make(chan struct{}, 4), make(chan struct{}, 4)
+		task := func() {
+			started <- struct{}{}
+			<-release
+			finished <- struct{}{}
+		}
+		for i := 0; i < 4; i++ {
+			go pool.Submit(task)
+		}
+		<-started
+		select {
+		case <-started:
+			t.Fatalf("max pending %d: a second task started with one worker", maxPending)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		// Growing the pool starts the waiting tasks.
+		if err := pool.Resize(4); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			<-started
+		}
+
+		// Shrinking it lets the running tasks finish.
+		if err := pool.Resize(2); err != nil {
+			t.Fatal(err)
+		}
+		if got := pool.Stats().Cap; got != 2 {
+			t.Fatalf("got a capacity of %d, exp 2", got)
+		}
+		close(release)
+		for i := 0; i < 4; i++ {
+			<-finished
+		}
+
+		// The pool runs at most two tasks from now on.
+		release = make(chan struct{})
+		for i := 0; i < 3; i++ {
+			go pool.Submit(task)
+		}
+		<-started
+		<-started
+		select {
+		case <-started:
+			t.Fatalf("max pending %d: a third task started with two workers", maxPending)
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(release)
+		<-started
+		for i := 0; i < 3; i++ {
+			<-finished
+		}
+	}
+	pool.Init(pool.Config{})
+
+	if err := pool.Resize(0); err == nil {
+		t.Fatal("expected an error resizing to 0")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	// A task honoring its context is canceled by Drain, which returns once
+	// the task has.
+	started := make(chan struct{})
+	var returned int32
+	if err := pool.SubmitWithTimeout(time.Hour, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&returned, 1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := pool.Drain(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&returned) != 1 {
+		t.Fatal("Drain returned before the task")
+	}
+
+	// Tasks started after Drain are passed a context that is not canceled.
+	ctxErr := make(chan error)
+	if err := pool.SubmitWithTimeout(time.Hour, func(ctx context.Context) { ctxErr <- ctx.Err() }); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-ctxErr; err != nil {
+		t.Fatalf("task started after Drain got a done context: %v", err)
+	}
+
+	// Tasks of Submit, which take no context, are waited for too.
+	var slow int32
+	if err := pool.Submit(func() {
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&slow, 1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Drain(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&slow) != 1 {
+		t.Fatal("Drain returned before the task of Submit")
+	}
+
+	// A task ignoring its context makes Drain time out. Tasks submitted
+	// meanwhile wait for Drain to return and then run.
+	release := make(chan struct{})
+	defer close(release)
+	blocked := make(chan struct{})
+	if err := pool.SubmitWithTimeout(time.Hour, func(ctx context.Context) {
+		close(blocked)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-blocked
+	drained := make(chan error, 1)
+	go func() { drained <- pool.Drain(100 * time.Millisecond) }()
+	time.Sleep(20 * time.Millisecond)
+	ran := make(chan struct{})
+	go pool.Submit(func() { close(ran) })
+	select {
+	case <-ran:
+		t.Fatal("task submitted while draining ran before Drain returned")
+	case err := <-drained:
+		if err == nil {
+			t.Fatal("expected Drain to time out")
+		}
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("task submitted while draining did not run once Drain returned")
+	}
+}
@@ -61,6 +61,9 @@ const (
 	MaxDebugRequestsInterval = 6 * time.Hour
 )
 
+// errReadOnly is returned for writes while the server is in read-only mode.
+const errReadOnly = "writes are disabled: the server is in read-only mode"
+
 // AuthenticationMethod defines the type of authentication used.
 type AuthenticationMethod int
 
@@ -139,6 +142,7 @@ type Handler struct {
 	registered       bool
 
 	Config           *Config
+	ReadOnly         bool // reject writes with 503 Service Unavailable
 	AccessLogger     *zap.Logger
 	Logger           *zap.Logger
 	CLFLogger        *log.Logger
@@ -887,6 +891,11 @@ func (h *Handler) serveWrite(database, retentionPolicy, precision string, w http
 	}(time.Now())
 	h.requestTracker.Add(r, user)
 
+	if h.ReadOnly {
+		h.httpError(w, errReadOnly, http.StatusServiceUnavailable)
+		return
+	}
+
 	if database == "" {
 		h.httpError(w, "database is required", http.StatusBadRequest)
 		return
@@ -1055,7 +1064,7 @@ func (h *Handler) servePing(w http.ResponseWriter, r *http.Request) {
 
 	if verbose != "" && verbose != "0" && verbose != "false" {
 		h.writeHeader(w, http.StatusOK)
-		b, _ := json.Marshal(map[string]string{"version": h.Version})
+		b, _ := json.Marshal(map[string]interface{}{"version": h.Version, "read_only": h.ReadOnly})
 		w.Write(b)
 	} else {
 		h.writeHeader(w, http.StatusNoContent)
@@ -1064,12 +1073,17 @@ func (h *Handler) servePing(w http.ResponseWriter, r *http.Request) {
 
 // serveHealth maps v2 health endpoint to ping endpoint
 func (h *Handler) serveHealth(w http.ResponseWriter, r *http.Request) {
+	message := "ready for queries and writes"
+	if h.ReadOnly {
+		message = "ready for queries; writes are disabled in read-only mode"
+	}
 	resp := map[string]interface{}{
-		"name":    "influxdb",
-		"message": "ready for queries and writes",
-		"status":  "pass",
-		"checks":  []string{},
-		"version": h.Version,
+		"name":      "influxdb",
+		"message":   message,
+		"status":    "pass",
+		"checks":    []string{},
+		"version":   h.Version,
+		"read_only": h.ReadOnly,
 	}
 	b, _ := json.Marshal(resp)
 	h.writeHeader(w, http.StatusOK)
@@ -1125,6 +1139,11 @@ func (h *Handler) servePromWrite(w http.ResponseWriter, r *http.Request, user me
 	}(time.Now())
 	h.requestTracker.Add(r, user)
 
+	if h.ReadOnly {
+		h.httpError(w, errReadOnly, http.StatusServiceUnavailable)
+		return
+	}
+
 	database := r.URL.Query().Get("db")
 	if database == "" {
 		h.httpError(w, "database is required", http.StatusBadRequest)
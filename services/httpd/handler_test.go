@@ -1591,6 +1591,29 @@ func TestHandler_Write_EntityTooLarge_ContentLength(t *testing.T) {
 	}
 }
 
+// Ensure the handler rejects writes in read-only mode while serving health checks.
+func TestHandler_Write_ReadOnly(t *testing.T) {
+	h := NewHandler(false)
+	h.Handler.ReadOnly = true
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		return &meta.DatabaseInfo{}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo", strings.NewReader("cpu value=1")))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("GET", "/health", nil))
+	var got map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, got["read_only"], true, "invalid read_only")
+}
+
 func TestHandler_Write_SuppressLog(t *testing.T) {
 	var buf bytes.Buffer
 	c := httpd.NewConfig()